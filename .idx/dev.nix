@@ -10,7 +10,7 @@
   env = {
     MONGO_URL = "";
     MONGO_NAME = "test2";
-    JWT_SECRET = "test";
+    JWT_ISSUER = "go-fiber-api";
     PORT = "4000";
     MINIO_ACCESS_KEY = "al8KsxHAbLtfNVsX";
     MINIO_SECRET_KEY = "noWZ40KlvEcioZcPhLmMZFcPSkdeuX0K";
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GetEnvInt đọc biến môi trường kiểu số nguyên, trả về giá trị mặc định nếu không có hoặc sai định dạng
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvList đọc biến môi trường dạng danh sách phân cách bằng dấu phẩy, bỏ phần tử rỗng
+func GetEnvList(key string) []string {
+	list := []string{}
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// APIKeyController quản lý API key cho tích hợp máy - máy (script kế toán, website)
+type APIKeyController struct {
+	repo *repositories.APIKeyRepository
+}
+
+func NewAPIKeyController(repo *repositories.APIKeyRepository) *APIKeyController {
+	return &APIKeyController{repo: repo}
+}
+
+// List liệt kê API key (không có giá trị khoá)
+//
+// @route GET /api/api-keys
+func (ctrl *APIKeyController) List(c *fiber.Ctx) error {
+	keys, err := ctrl.repo.List(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get API keys failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "API keys", Data: keys})
+}
+
+// Create tạo API key và trả về giá trị khoá. Khoá chỉ hiển thị một lần, client gửi qua header X-API-Key.
+// Không cấp được quyền quản trị tài khoản (models.APIKeyDeniedPermissions) hay quyền mà vai trò người tạo không có (403).
+// Khoá bị thu hồi khi người tạo bị vô hiệu hoá.
+//
+// @route POST /api/api-keys
+// @body { "name": "Script kế toán", "permissions": ["invoice.read"], "allowedIps": ["203.0.113.10", "10.0.0.0/24"], "expiresAt": "2026-12-31T00:00:00Z" }
+func (ctrl *APIKeyController) Create(c *fiber.Ctx) error {
+	var body struct {
+		Name        string     `json:"name"`
+		Permissions []string   `json:"permissions"`
+		AllowedIPs  []string   `json:"allowedIps"`
+		ExpiresAt   *time.Time `json:"expiresAt"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "name is required", Data: nil})
+	}
+	if len(body.Permissions) == 0 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "permissions is required", Data: nil})
+	}
+	granted, err := callerPermissions(c)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create API key failed", Data: nil})
+	}
+	for _, p := range body.Permissions {
+		if !models.IsPermission(p) {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Unknown permission " + p, Data: nil})
+		}
+		for _, denied := range models.APIKeyDeniedPermissions {
+			if p == denied {
+				return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Permission " + p + " cannot be granted to an API key", Data: nil})
+			}
+		}
+		if !granted[p] {
+			return c.Status(403).JSON(models.APIResponse{Status: "error", Message: "Forbidden: you do not have permission " + p, Data: nil})
+		}
+	}
+	allowedIPs := []string{}
+	for _, ip := range body.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid IP or CIDR " + ip, Data: nil})
+		}
+		allowedIPs = append(allowedIPs, ip)
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "expiresAt must be in the future", Data: nil})
+	}
+	secret, _, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create API key failed", Data: nil})
+	}
+	raw := models.APIKeyPrefix + secret
+	key, err := ctrl.repo.Create(c.Context(), models.APIKey{
+		Name:        strings.TrimSpace(body.Name),
+		Prefix:      raw[:len(models.APIKeyPrefix)+6],
+		KeyHash:     utils.HashToken(raw), // Hash cả tiền tố, khớp với giá trị client gửi trong header
+		Permissions: body.Permissions,
+		AllowedIPs:  allowedIPs,
+		ExpiresAt:   body.ExpiresAt,
+		CreatedBy:   middleware.CurrentUserID(c),
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create API key failed", Data: nil})
+	}
+	audit(c, models.AuditAPIKeyCreate, key.ID.Hex(), nil, key)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "API key created", Data: fiber.Map{
+		"apiKey": key,
+		"key":    raw,
+	}})
+}
+
+// Revoke thu hồi API key, khoá không dùng được nữa ngay lập tức
+//
+// @route DELETE /api/api-keys/:id
+func (ctrl *APIKeyController) Revoke(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := ctrl.repo.Revoke(c.Context(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "API key not found or already revoked", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Revoke API key failed", Data: nil})
+	}
+	audit(c, models.AuditAPIKeyRevoke, id, nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "API key revoked", Data: nil})
+}
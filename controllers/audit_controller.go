@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+)
+
+// AuditController tra cứu và kiểm tra nhật ký thao tác (collection audit_logs)
+type AuditController struct {
+	repo *repositories.AuditRepository
+}
+
+func NewAuditController(repo *repositories.AuditRepository) *AuditController {
+	return &AuditController{repo: repo}
+}
+
+// List tra cứu nhật ký thao tác, mới nhất trước
+//
+// @route GET /api/audit-logs?actorId=&action=product.update&targetType=product&targetId=&from=01/07/2025&to=31/07/2025&page=1&limit=20
+func (ctrl *AuditController) List(c *fiber.Ctx) error {
+	page := int64(c.QueryInt("page", 1))
+	limit := int64(c.QueryInt("limit", 20))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	filter := repositories.AuditFilter{
+		ActorID:    c.Query("actorId"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+	}
+	loc := time.FixedZone("GMT+7", 7*3600)
+	if from := c.Query("from"); from != "" {
+		t, err := time.ParseInLocation("02/01/2006", from, loc)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid date format (dd/mm/yyyy)", Data: nil})
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.ParseInLocation("02/01/2006", to, loc)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid date format (dd/mm/yyyy)", Data: nil})
+		}
+		t = t.Add(24*time.Hour - time.Millisecond)
+		filter.To = &t
+	}
+	logs, total, err := ctrl.repo.List(c.Context(), filter, page, limit)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get audit logs failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Audit logs", Data: fiber.Map{
+		"logs":  logs,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	}})
+}
+
+// Verify kiểm tra chuỗi hash của toàn bộ nhật ký, trả về seq của mục đầu tiên bị sửa / xoá nếu có
+//
+// @route GET /api/audit-logs/verify
+func (ctrl *AuditController) Verify(c *fiber.Ctx) error {
+	result, err := ctrl.repo.Verify(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Verify audit logs failed", Data: nil})
+	}
+	message := "Audit log chain is intact"
+	if !result.Valid {
+		message = fmt.Sprintf("Audit log chain is broken at seq %d", result.BrokenAt)
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: message, Data: result})
+}
+
+// audit ghi một mục nhật ký thao tác cho request hiện tại: người thực hiện lấy từ JWT / API key, kèm IP và request ID.
+// before, after là ảnh chụp đối tượng trước và sau khi thay đổi (nil nếu không có), không được chứa hash mật khẩu.
+// Thao tác đã thực hiện nên không hoàn tác được: lỗi ghi nhật ký thì mục được ghi đầy đủ vào log server
+// và request trả về 500 (xem middleware.AuditTrail).
+func audit(c *fiber.Ctx, action, targetID string, before, after interface{}) {
+	entry := models.NewAuditLog(action, targetID)
+	if claims := middleware.Claims(c); claims != nil {
+		entry.ActorID, entry.ActorRole, entry.ActorMethod = claims.UserID, claims.Role, claims.Method
+	}
+	entry.IP = c.IP()
+	entry.RequestID, _ = c.Locals("requestid").(string)
+	entry.Before, entry.After = auditSnapshot(before), auditSnapshot(after)
+	repo := middleware.AuditLog(c)
+	if repo == nil {
+		log.Printf("❌ Route %s không có middleware.AuditTrail, bỏ qua nhật ký %s %s\n", c.Path(), action, targetID)
+		return
+	}
+	if err := repo.Append(c.Context(), entry); err != nil {
+		data, _ := json.Marshal(entry)
+		log.Printf("❌ Không ghi được nhật ký thao tác: %v, mục: %s\n", err, data)
+		middleware.MarkAuditFailed(c)
+	}
+}
+
+// auditSnapshot chuyển đối tượng thành JSON để lưu vào nhật ký; nil -> không lưu
+func auditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
@@ -1,13 +1,53 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
 	"go-fiber-api/models"
 	"go-fiber-api/repositories"
 	"go-fiber-api/utils"
 )
 
-func Login(c *fiber.Ctx) error {
+// mfaChallengeTTL là thời gian nhập mã 2FA sau khi đúng mật khẩu
+const mfaChallengeTTL = 5 * time.Minute
+
+// AuthController xử lý đăng nhập, làm mới token và đăng xuất.
+// Access token sống ngắn (accessTTL); refresh token xoay vòng mỗi lần dùng và hết hạn sau refreshTTL không dùng.
+type AuthController struct {
+	keys       *utils.KeyRing
+	sessions   *repositories.SessionRepository
+	attempts   *repositories.LoginAttemptRepository
+	mfa        *MFAController
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewAuthController(
+	keys *utils.KeyRing,
+	sessions *repositories.SessionRepository,
+	attempts *repositories.LoginAttemptRepository,
+	mfa *MFAController,
+	accessTTL, refreshTTL time.Duration,
+) *AuthController {
+	return &AuthController{keys: keys, sessions: sessions, attempts: attempts, mfa: mfa, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// Login kiểm tra tài khoản, mở phiên mới và trả về access token + refresh token.
+// Sai nhiều lần theo username hoặc IP -> phải chờ tăng dần (429 kèm Retry-After); sai LOGIN_MAX_FAILURES lần
+// liên tiếp theo username -> khoá tài khoản LOGIN_LOCK_MINUTES phút (423). Mọi lần đăng nhập đều được ghi nhật ký.
+//
+// Người dùng đã bật 2FA (hoặc vai trò bắt buộc 2FA) chỉ nhận challengeToken, hoàn tất đăng nhập qua POST /login/2fa.
+// Bộ đếm đăng nhập sai chỉ được xoá khi qua được bước 2FA, để đúng mật khẩu không mở thêm lượt đoán mã.
+//
+// @route POST /login
+// @body {"username": "admin", "password": "admin123"}
+func (ctrl *AuthController) Login(c *fiber.Ctx) error {
 	var input struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -20,22 +60,442 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
+	attempt := models.LoginAttempt{Username: input.Username, IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+
+	// Giữ lượt thử trước bcrypt để request bị chặn không tốn CPU và request song song không vượt ngưỡng
+	if blocked, err := ctrl.reserve(c, attempt); blocked {
+		return err
+	}
+
 	user, err := repositories.FindUserByUsername(input.Username)
-	if err != nil || !utils.CheckPasswordHash(input.Password, user.Password) {
+	hash := dummyPasswordHash()
+	if user != nil {
+		attempt.UserID = user.ID.Hex()
+		hash = user.Password
+	}
+	// Username không tồn tại vẫn so sánh với hash giả để thời gian phản hồi không lộ username
+	if !utils.CheckPasswordHash(input.Password, hash) || err != nil {
+		attempt.Reason = models.LoginInvalidCredentials
+		ctrl.log(c, attempt)
 		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
 			Status:  "error",
 			Message: "Invalid credentials",
 			Data:    nil,
 		})
 	}
-	token, _ := utils.GenerateJWT(user.ID, user.Role)
-	return c.JSON(models.APIResponse{
-		Status:  "success",
-		Message: "Login successful",
-		Data: fiber.Map{
-			"id":    user.ID,
-			"role":  user.Role,
-			"token": token,
-		},
-	})
+	if err := ctrl.attempts.Release(c.Context(), input.Username, attempt.IP); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if rejected, err := ctrl.checkAccount(c, attempt, user); rejected {
+		return err
+	}
+
+	required, mfa, err := ctrl.mfa.state(c.Context(), user.ID.Hex(), user.Role)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	enabled := mfa != nil && mfa.Enabled
+	if enabled || required {
+		attempt.Reason = models.LoginMFAChallenge
+		ctrl.log(c, attempt)
+		challenge, err := ctrl.keys.GenerateChallenge(user.ID, user.Role, mfaChallengeTTL)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Token generation failed", Data: nil})
+		}
+		return c.JSON(models.APIResponse{Status: "success", Message: "Two-factor authentication required", Data: fiber.Map{
+			"mfaRequired":    true,
+			"enrollRequired": !enabled, // Vai trò bắt buộc 2FA nhưng chưa đăng ký: gọi /login/2fa/setup trước
+			"challengeToken": challenge,
+			"expiresIn":      int(mfaChallengeTTL.Seconds()),
+		}})
+	}
+	return ctrl.complete(c, attempt, user, input.Password, nil)
+}
+
+// LoginMFASetup đăng ký 2FA ngay trong bước đăng nhập, cho người dùng thuộc vai trò bắt buộc 2FA nhưng chưa đăng ký.
+// Trả về khoá và provisioning URI; sau đó gửi mã đầu tiên tới POST /login/2fa để kích hoạt và đăng nhập.
+//
+// @route POST /login/2fa/setup
+// @body {"challengeToken": "..."}
+func (ctrl *AuthController) LoginMFASetup(c *fiber.Ctx) error {
+	var body struct {
+		ChallengeToken string `json:"challengeToken"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "challengeToken is required", Data: nil})
+	}
+	user, err := ctrl.challengeUser(body.ChallengeToken)
+	if err != nil {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Invalid or expired challenge token", Data: nil})
+	}
+	return ctrl.mfa.setup(c, user)
+}
+
+// LoginMFA là bước thứ hai của đăng nhập: kiểm tra mã TOTP hoặc mã dự phòng rồi mở phiên.
+// Người dùng đang đăng ký (enrollRequired) gửi mã đầu tiên để kích hoạt 2FA, response kèm mã dự phòng.
+// Sai mã được tính như đăng nhập sai (chờ tăng dần, khoá tài khoản).
+//
+// @route POST /login/2fa
+// @body {"challengeToken": "...", "code": "123456"}
+func (ctrl *AuthController) LoginMFA(c *fiber.Ctx) error {
+	var body struct {
+		ChallengeToken string `json:"challengeToken"`
+		Code           string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" || body.Code == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "challengeToken and code are required", Data: nil})
+	}
+	user, err := ctrl.challengeUser(body.ChallengeToken)
+	if err != nil {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Invalid or expired challenge token", Data: nil})
+	}
+	attempt := models.LoginAttempt{Username: user.Username, UserID: user.ID.Hex(), IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+	if rejected, err := ctrl.checkAccount(c, attempt, user); rejected {
+		return err
+	}
+
+	_, mfa, err := ctrl.mfa.state(c.Context(), user.ID.Hex(), user.Role)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if mfa == nil {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication is not set up, call /login/2fa/setup first", Data: nil})
+	}
+	if blocked, err := ctrl.reserve(c, attempt); blocked {
+		return err
+	}
+	var ok bool
+	var backupCodes []string
+	if mfa.Enabled {
+		ok, err = ctrl.mfa.verify(c.Context(), mfa, body.Code)
+	} else {
+		backupCodes, ok, err = ctrl.mfa.enable(c.Context(), user.ID.Hex(), body.Code)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if !ok {
+		attempt.Reason = models.LoginInvalidMFACode
+		ctrl.log(c, attempt)
+		return invalidMFACode(c, nil)
+	}
+	if err := ctrl.attempts.Release(c.Context(), user.Username, attempt.IP); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	var extra fiber.Map
+	if backupCodes != nil {
+		extra = fiber.Map{"backupCodes": backupCodes}
+	}
+	return ctrl.complete(c, attempt, user, "", extra)
+}
+
+// complete ghi nhận đăng nhập thành công, xoá bộ đếm sai và mở phiên mới. password là mật khẩu vừa nhập đúng,
+// dùng để băm lại hash cũ sau khi phiên đã mở; bước 2FA không có mật khẩu nên truyền "" (băm lại khi đổi mật khẩu).
+func (ctrl *AuthController) complete(c *fiber.Ctx, attempt models.LoginAttempt, user *models.User, password string, extra fiber.Map) error {
+	attempt.Success, attempt.Reason = true, models.LoginSuccess
+	ctrl.log(c, attempt)
+	if err := ctrl.attempts.RecordSuccess(c.Context(), user.Username); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	ctrl.touch(user)
+
+	refreshToken, hash, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	session, err := ctrl.sessions.Create(c.Context(), models.Session{
+		UserID:    user.ID.Hex(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+		Method:    models.AuthPassword,
+	}, hash, ctrl.refreshTTL)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if password != "" {
+		rehash(user, "password", password, user.Password)
+	}
+	return ctrl.issue(c, "Login successful", user, session.ID.Hex(), refreshToken, extra)
+}
+
+// checkAccount từ chối tài khoản đã bị vô hiệu hoá (403) hoặc mật khẩu tạm đã hết hạn (401), gọi sau khi
+// đã kiểm tra mật khẩu / PIN để không lộ trạng thái tài khoản cho người không biết mật khẩu
+func (ctrl *AuthController) checkAccount(c *fiber.Ctx, attempt models.LoginAttempt, user *models.User) (bool, error) {
+	if user.Disabled {
+		attempt.Reason = models.LoginDisabled
+		ctrl.log(c, attempt)
+		return true, c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Account is disabled", Data: nil})
+	}
+	if user.PasswordExpiresAt != nil && time.Now().After(*user.PasswordExpiresAt) {
+		attempt.Reason = models.LoginPasswordExpired
+		ctrl.log(c, attempt)
+		return true, c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{Status: "error", Message: "Temporary password has expired, ask an administrator to reset it", Data: nil})
+	}
+	return false, nil
+}
+
+// touch ghi thời điểm đăng nhập gần nhất; lỗi không chặn đăng nhập
+func (ctrl *AuthController) touch(user *models.User) {
+	if err := repositories.TouchLastLogin(user.ID); err != nil {
+		log.Printf("⚠️ Không ghi được lần đăng nhập gần nhất của %s: %v\n", user.Username, err)
+	}
+}
+
+// rehash băm lại mật khẩu / PIN (field "password" / "pin") vừa nhập đúng nếu hash đang lưu dùng thuật toán
+// hoặc tham số yếu hơn cấu hình hiện tại. Chỉ gọi khi đăng nhập đã thành công. Lỗi chỉ ghi log, không chặn đăng nhập.
+func rehash(user *models.User, field, secret, hash string) {
+	if !utils.PasswordNeedsRehash(hash) {
+		return
+	}
+	newHash, err := utils.HashPassword(secret)
+	if err == nil {
+		err = repositories.RehashUserSecret(user.ID, field, hash, newHash)
+	}
+	if err != nil {
+		log.Printf("⚠️ Không băm lại được %s của %s: %v\n", field, user.Username, err)
+	}
+}
+
+// reserve giữ một lượt thử cho username và IP; trả về true (kèm response 423 / 429 đã ghi) nếu đang phải chờ
+// sau nhiều lần sai. Lượt đã giữ được tính là một lần sai cho tới khi gọi attempts.Release.
+func (ctrl *AuthController) reserve(c *fiber.Ctx, attempt models.LoginAttempt) (bool, error) {
+	until, locked, err := ctrl.attempts.Reserve(c.Context(), attempt.Username, attempt.IP)
+	return ctrl.reject(c, attempt, until, locked, err)
+}
+
+// dummyPasswordHash là hash của một mật khẩu ngẫu nhiên theo cấu hình hiện tại, dùng khi không tìm thấy username
+var dummyPasswordHash = sync.OnceValue(func() string {
+	secret, _, err := utils.NewSecretToken()
+	if err == nil {
+		var hash string
+		if hash, err = utils.HashPassword(secret); err == nil {
+			return hash
+		}
+	}
+	log.Fatalf("❌ Không tạo được hash giả cho đăng nhập: %v", err)
+	return ""
+})
+
+// reject ghi response 423 (locked) / 429 nếu until chưa qua; dùng chung cho đăng nhập bằng mật khẩu và PIN
+func (ctrl *AuthController) reject(c *fiber.Ctx, attempt models.LoginAttempt, until time.Time, locked bool, err error) (bool, error) {
+	if err != nil {
+		return true, c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if until.IsZero() {
+		return false, nil
+	}
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	if locked {
+		attempt.Reason = models.LoginLocked
+		ctrl.log(c, attempt)
+		message := "Account is temporarily locked"
+		if attempt.Method == models.AuthPIN {
+			message = "PIN login is temporarily locked, log in with your password"
+		}
+		return true, c.Status(fiber.StatusLocked).JSON(models.APIResponse{Status: "error", Message: message, Data: fiber.Map{"retryAfter": retryAfter}})
+	}
+	attempt.Reason = models.LoginThrottled
+	ctrl.log(c, attempt)
+	return true, c.Status(fiber.StatusTooManyRequests).JSON(models.APIResponse{Status: "error", Message: "Too many failed login attempts, try again later", Data: fiber.Map{"retryAfter": retryAfter}})
+}
+
+// challengeUser xác thực challenge token của bước 2FA và đọc lại người dùng từ DB
+func (ctrl *AuthController) challengeUser(challengeToken string) (*models.User, error) {
+	token, err := ctrl.keys.Parse(challengeToken, utils.TokenMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.FindUserByID(token.Claims.(*utils.TokenClaims).UserID)
+}
+
+// Refresh đổi refresh token lấy cặp token mới. Refresh token cũ hết hiệu lực ngay;
+// gửi lại refresh token đã dùng -> thu hồi cả phiên (mọi token của phiên), client phải đăng nhập lại.
+// Vai trò trong access token mới được đọc lại từ DB.
+//
+// @route POST /refresh
+// @body {"refreshToken": "..."}
+func (ctrl *AuthController) Refresh(c *fiber.Ctx) error {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "refreshToken is required", Data: nil})
+	}
+
+	refreshToken, hash, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Refresh failed", Data: nil})
+	}
+	session, err := ctrl.sessions.Rotate(c.Context(), utils.HashToken(body.RefreshToken), hash, ctrl.refreshTTL)
+	if errors.Is(err, repositories.ErrRefreshTokenReused) {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Refresh token reuse detected, session revoked", Data: nil})
+	}
+	if errors.Is(err, repositories.ErrInvalidRefreshToken) {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Invalid or expired refresh token", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Refresh failed", Data: nil})
+	}
+
+	user, err := repositories.FindUserByID(session.UserID)
+	if err != nil || user.Disabled {
+		_ = ctrl.sessions.RevokeUser(c.Context(), session.UserID, models.RevokeUserDisabled)
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "User not found or disabled", Data: nil})
+	}
+	// Vai trò vừa được bật bắt buộc 2FA: phiên của người chưa đăng ký không được gia hạn
+	required, mfa, err := ctrl.mfa.state(c.Context(), user.ID.Hex(), user.Role)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Refresh failed", Data: nil})
+	}
+	if required && (mfa == nil || !mfa.Enabled) {
+		_ = ctrl.sessions.RevokeUser(c.Context(), user.ID.Hex(), models.RevokeMFARequired)
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication required, please log in again", Data: nil})
+	}
+	return ctrl.issue(c, "Token refreshed", user, session.ID.Hex(), refreshToken, nil)
+}
+
+// Logout thu hồi phiên hiện tại; ?all=true thu hồi mọi phiên của người dùng (đăng xuất mọi thiết bị).
+// API key không có phiên -> 400, thu hồi khoá qua DELETE /api/api-keys/:id.
+//
+// @route POST /logout
+func (ctrl *AuthController) Logout(c *fiber.Ctx) error {
+	if middleware.CurrentAPIKey(c) != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "API keys have no session", Data: nil})
+	}
+	userID := middleware.CurrentUserID(c)
+	var err error
+	if c.QueryBool("all") {
+		err = ctrl.sessions.RevokeUser(c.Context(), userID, models.RevokeLogoutAll)
+	} else {
+		err = ctrl.sessions.Revoke(c.Context(), userID, middleware.CurrentSessionID(c), models.RevokeLogout)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Logout failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Logged out", Data: nil})
+}
+
+// Unlock mở khoá tài khoản bị khoá do đăng nhập sai nhiều lần
+//
+// @route POST /api/users/:id/unlock
+func (ctrl *AuthController) Unlock(c *fiber.Ctx) error {
+	user, err := repositories.FindUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if err := ctrl.attempts.Unlock(c.Context(), user.Username); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unlock failed", Data: nil})
+	}
+	audit(c, models.AuditUserUnlock, user.ID.Hex(), nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "User unlocked", Data: nil})
+}
+
+// LoginAttempts xem nhật ký đăng nhập, mới nhất trước
+//
+// @route GET /api/users/login-attempts?username=admin&ip=1.2.3.4&page=1&limit=20
+func (ctrl *AuthController) LoginAttempts(c *fiber.Ctx) error {
+	page := int64(c.QueryInt("page", 1))
+	limit := int64(c.QueryInt("limit", 20))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	attempts, total, err := ctrl.attempts.List(c.Context(), c.Query("username"), c.Query("ip"), page, limit)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get login attempts failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Login attempts", Data: fiber.Map{
+		"attempts": attempts,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+	}})
+}
+
+// log ghi nhật ký đăng nhập; lỗi ghi nhật ký không chặn đăng nhập
+func (ctrl *AuthController) log(c *fiber.Ctx, attempt models.LoginAttempt) {
+	if err := ctrl.attempts.Log(c.Context(), attempt); err != nil {
+		log.Printf("⚠️ Không ghi được nhật ký đăng nhập: %v\n", err)
+	}
+}
+
+// JWKS trả về public key xác thực access token (JSON Web Key Set) cho các dịch vụ nội bộ khác
+//
+// @route GET /.well-known/jwks.json
+func (ctrl *AuthController) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(fiber.Map{"keys": ctrl.keys.JWKS()})
+}
+
+// Me trả về thông tin người dùng hiện tại, quyền thực tế của phiên và cách đăng nhập
+//
+// @route GET /api/me
+func (ctrl *AuthController) Me(c *fiber.Ctx) error {
+	claims := middleware.Claims(c)
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		return c.JSON(models.APIResponse{Status: "success", Message: "Current API key", Data: fiber.Map{
+			"apiKey":      key,
+			"permissions": key.Permissions,
+			"method":      claims.Method,
+		}})
+	}
+	user, err := repositories.FindUserByID(claims.UserID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	permissions, err := ctrl.mfa.roles.Permissions(c.Context(), user.Role)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get profile failed", Data: nil})
+	}
+	if claims.Method == models.AuthPIN {
+		permissions = pinPermissions(permissions)
+	}
+	user.Password = ""
+	return c.JSON(models.APIResponse{Status: "success", Message: "Current user", Data: fiber.Map{
+		"user":        user,
+		"permissions": permissions,
+		"method":      claims.Method,
+		"sessionId":   claims.SessionID,
+	}})
+}
+
+// Sessions liệt kê phiên đăng nhập còn hiệu lực của người dùng hiện tại
+//
+// @route GET /api/sessions
+func (ctrl *AuthController) Sessions(c *fiber.Ctx) error {
+	sessions, err := ctrl.sessions.ListActive(c.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get sessions failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Sessions", Data: sessions})
+}
+
+// issue trả về access token mới và refresh token của phiên; extra là các trường trả thêm (mã dự phòng 2FA)
+func (ctrl *AuthController) issue(c *fiber.Ctx, message string, user *models.User, sessionID, refreshToken string, extra fiber.Map) error {
+	token, err := ctrl.keys.GenerateJWT(utils.TokenClaims{
+		UserID:             user.ID.Hex(),
+		Role:               user.Role,
+		SessionID:          sessionID,
+		Method:             models.AuthPassword,
+		MustChangePassword: user.MustChangePassword,
+	}, ctrl.accessTTL)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Token generation failed", Data: nil})
+	}
+	data := fiber.Map{
+		"id":                 user.ID,
+		"role":               user.Role,
+		"token":              token,
+		"expiresIn":          int(ctrl.accessTTL.Seconds()),
+		"refreshToken":       refreshToken,
+		"sessionId":          sessionID,
+		"mustChangePassword": user.MustChangePassword, // true -> chỉ gọi được PUT /api/users/password và GET /api/me
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: message, Data: data})
 }
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeviceController quản lý máy POS được phép đăng nhập bằng PIN
+type DeviceController struct {
+	repo     *repositories.DeviceRepository
+	sessions *repositories.SessionRepository
+}
+
+func NewDeviceController(repo *repositories.DeviceRepository, sessions *repositories.SessionRepository) *DeviceController {
+	return &DeviceController{repo: repo, sessions: sessions}
+}
+
+// List liệt kê máy POS đã đăng ký
+//
+// @route GET /api/devices
+func (ctrl *DeviceController) List(c *fiber.Ctx) error {
+	devices, err := ctrl.repo.List(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get devices failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Devices", Data: devices})
+}
+
+// Create đăng ký máy POS và trả về device token. Token chỉ hiển thị một lần, nhập vào cấu hình của máy POS
+// (gửi qua header X-Device-Token khi đăng nhập bằng PIN).
+//
+// @route POST /api/devices
+// @body { "name": "Quầy 1" }
+func (ctrl *DeviceController) Create(c *fiber.Ctx) error {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "name is required", Data: nil})
+	}
+	token, hash, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create device failed", Data: nil})
+	}
+	device, err := ctrl.repo.Create(c.Context(), models.Device{
+		Name:      strings.TrimSpace(body.Name),
+		TokenHash: hash,
+		CreatedBy: middleware.CurrentUserID(c),
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create device failed", Data: nil})
+	}
+	audit(c, models.AuditDeviceCreate, device.ID.Hex(), nil, device)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Device registered", Data: fiber.Map{
+		"device":      device,
+		"deviceToken": token,
+	}})
+}
+
+// Revoke thu hồi máy POS (mất máy, thanh lý) và đăng xuất mọi phiên PIN trên máy đó
+//
+// @route DELETE /api/devices/:id
+func (ctrl *DeviceController) Revoke(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := ctrl.repo.Revoke(c.Context(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Device not found or already revoked", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Revoke device failed", Data: nil})
+	}
+	if err := ctrl.sessions.RevokeDevice(c.Context(), id, models.RevokeDeviceRevoked); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke device sessions", Data: nil})
+	}
+	audit(c, models.AuditDeviceRevoke, id, nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Device revoked", Data: nil})
+}
@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/einvoice"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// EInvoiceController lập, ký số hóa đơn điện tử và gửi nhà cung cấp hóa đơn điện tử
+type EInvoiceController struct {
+	repo       *repositories.EInvoiceRepository
+	invoices   *repositories.InvoiceRepository
+	settings   *repositories.StoreSettingRepository
+	quotations *repositories.QuotationRepository
+	signer     *einvoice.Signer // nil nếu chưa cấu hình chứng thư số
+	submitter  *einvoice.Submitter
+}
+
+func NewEInvoiceController(
+	repo *repositories.EInvoiceRepository,
+	invoices *repositories.InvoiceRepository,
+	settings *repositories.StoreSettingRepository,
+	quotations *repositories.QuotationRepository,
+	signer *einvoice.Signer,
+	submitter *einvoice.Submitter,
+) *EInvoiceController {
+	return &EInvoiceController{repo: repo, invoices: invoices, settings: settings, quotations: quotations, signer: signer, submitter: submitter}
+}
+
+// Issue lập hóa đơn điện tử cho hóa đơn đã hoàn tất: dựng XML theo định dạng Tổng cục Thuế, kiểm tra cấu trúc,
+// cấp số theo ký hiệu, ký số bằng chứng thư cấu hình trong EINVOICE_CERT_FILE/EINVOICE_KEY_FILE, lưu XML đã ký
+// rồi gửi ngay nhà cung cấp. Gửi lỗi tạm thời -> trả 201 với submissionStatus = pending, worker tự gửi lại.
+// Không gửi buyer và hóa đơn chuyển từ báo giá -> lấy thông tin khách hàng của báo giá.
+//
+// @route POST /api/invoices/:id/einvoice
+//
+//	@body {
+//	  "buyer": {
+//	    "name": "Công ty TNHH ABC",
+//	    "taxCode": "0101234567",
+//	    "address": "1 Tràng Tiền, Hà Nội",
+//	    "email": "ketoan@abc.vn",
+//	    "buyerName": "Nguyễn Văn A"
+//	  }
+//	}
+//
+// XML không hợp lệ -> 422 kèm danh sách lỗi.
+func (ctrl *EInvoiceController) Issue(c *fiber.Ctx) error {
+	var body struct {
+		Buyer *models.EInvoiceBuyer `json:"buyer"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+		}
+	}
+	if ctrl.signer == nil {
+		return c.Status(503).JSON(models.APIResponse{Status: "error", Message: "E-invoice signing certificate is not configured", Data: nil})
+	}
+
+	invoice, err := ctrl.invoices.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Invoice not found", Data: nil})
+	}
+	if invoice.Status != "" && invoice.Status != models.InvoiceStatusCompleted {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Only completed invoices can be issued as e-invoices", Data: nil})
+	}
+	// Hóa đơn điện tử đã huỷ thì được lập lại bản mới
+	if existing, err := ctrl.repo.FindByInvoice(c.Context(), invoice.ID); err == nil {
+		if existing.SubmissionStatus != models.EInvoiceCancelled {
+			return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "E-invoice already issued", Data: existing})
+		}
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Issue failed", Data: nil})
+	}
+
+	buyer := ctrl.buyerOf(c, invoice, body.Buyer)
+	doc, err := ctrl.prepare(c, invoice, buyer, nil)
+	if err != nil {
+		return invalidEInvoice(c, err)
+	}
+	created, err := ctrl.store(c, invoice, buyer, doc, func(record *models.EInvoice) {
+		record.Active = true
+		record.PendingAction = models.EInvoiceActionIssue
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "E-invoice already issued", Data: nil})
+	}
+	if err != nil {
+		return invalidEInvoice(c, err)
+	}
+	audit(c, models.AuditEInvoiceIssue, created.ID.Hex(), nil, created)
+	return ctrl.submitted(c, 201, created.ID, "E-invoice issued")
+}
+
+// Replace lập hóa đơn điện tử thay thế cho hóa đơn điện tử đã phát hành có sai sót (số mới, XML ghi thông tin
+// hóa đơn bị thay thế) và gửi nhà cung cấp. Bản cũ chỉ hết hiệu lực khi nhà cung cấp nhận bản thay thế.
+// Không gửi buyer -> giữ thông tin người mua của bản cũ.
+//
+// @route POST /api/invoices/:id/einvoice/replace
+// @body {"reason": "Sai mã số thuế người mua", "buyer": {"name": "Công ty TNHH ABC", "taxCode": "0101234568"}}
+func (ctrl *EInvoiceController) Replace(c *fiber.Ctx) error {
+	var body struct {
+		Reason string                `json:"reason"`
+		Buyer  *models.EInvoiceBuyer `json:"buyer"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Reason is required", Data: nil})
+	}
+	if ctrl.signer == nil {
+		return c.Status(503).JSON(models.APIResponse{Status: "error", Message: "E-invoice signing certificate is not configured", Data: nil})
+	}
+
+	invoice, err := ctrl.invoices.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Invoice not found", Data: nil})
+	}
+	original, err := ctrl.repo.FindByInvoice(c.Context(), invoice.ID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+	if !original.Active || original.SubmissionStatus != models.EInvoiceIssued || original.PendingAction != "" {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Only issued e-invoices without pending actions can be replaced", Data: original})
+	}
+
+	buyer := original.Buyer
+	if body.Buyer != nil {
+		buyer = *body.Buyer
+	}
+	doc, err := ctrl.prepare(c, invoice, buyer, &einvoice.Reference{
+		TemplateCode: original.TemplateCode,
+		Series:       original.Series,
+		Number:       original.Number,
+		IssueDate:    original.IssuedAt,
+		Note:         body.Reason,
+	})
+	if err != nil {
+		return invalidEInvoice(c, err)
+	}
+	created, err := ctrl.store(c, invoice, buyer, doc, func(record *models.EInvoice) {
+		record.ReplacesID = &original.ID
+		record.Reason = body.Reason
+		record.PendingAction = models.EInvoiceActionReplace
+	})
+	if err != nil {
+		return invalidEInvoice(c, err)
+	}
+	audit(c, models.AuditEInvoiceReplace, created.ID.Hex(), original, created)
+	return ctrl.submitted(c, 201, created.ID, "Replacement e-invoice issued")
+}
+
+// Cancel huỷ hóa đơn điện tử mới nhất của hóa đơn. Bản đã phát hành được gửi yêu cầu huỷ tới nhà cung cấp;
+// bản chưa phát hành được (đang chờ gửi hoặc bị từ chối) chỉ huỷ trong hệ thống.
+//
+// @route POST /api/invoices/:id/einvoice/cancel
+// @body {"reason": "Khách huỷ giao dịch"}
+func (ctrl *EInvoiceController) Cancel(c *fiber.Ctx) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Reason is required", Data: nil})
+	}
+	record, err := ctrl.find(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+
+	switch record.SubmissionStatus {
+	case models.EInvoicePending, models.EInvoiceFailed:
+		err = ctrl.repo.CancelLocal(c.Context(), record.ID, body.Reason)
+	case models.EInvoiceIssued:
+		err = ctrl.repo.RequestCancel(c.Context(), record.ID, body.Reason)
+	default:
+		err = mongo.ErrNoDocuments
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "E-invoice cannot be cancelled in its current state", Data: record})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Cancel failed", Data: nil})
+	}
+	audit(c, models.AuditEInvoiceCancel, record.ID.Hex(), record, fiber.Map{"reason": body.Reason})
+	return ctrl.submitted(c, 200, record.ID, "E-invoice cancelled")
+}
+
+// Retry gửi lại thao tác đã dừng gửi lại (bị nhà cung cấp từ chối hoặc hết EINVOICE_MAX_ATTEMPTS lần)
+//
+// @route POST /api/invoices/:id/einvoice/retry
+func (ctrl *EInvoiceController) Retry(c *fiber.Ctx) error {
+	record, err := ctrl.find(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+	err = ctrl.repo.Retry(c.Context(), record.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "E-invoice has no stopped submission to retry", Data: record})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Retry failed", Data: nil})
+	}
+	audit(c, models.AuditEInvoiceRetry, record.ID.Hex(), record, nil)
+	return ctrl.submitted(c, 200, record.ID, "E-invoice resubmitted")
+}
+
+// Status tra cứu trạng thái hóa đơn điện tử phía nhà cung cấp và cập nhật vào hệ thống
+//
+// @route GET /api/invoices/:id/einvoice/status
+func (ctrl *EInvoiceController) Status(c *fiber.Ctx) error {
+	record, err := ctrl.find(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+	if record.ProviderRef == "" {
+		return c.JSON(models.APIResponse{Status: "success", Message: "E-invoice not yet issued by provider", Data: record})
+	}
+	result, err := ctrl.submitter.Provider().Status(c.Context(), record.ProviderRef)
+	if err != nil {
+		return c.Status(502).JSON(models.APIResponse{Status: "error", Message: "Provider status lookup failed: " + err.Error(), Data: nil})
+	}
+	if result.Status != record.SubmissionStatus {
+		active := result.Status == einvoice.StatusIssued
+		if err := ctrl.repo.SyncStatus(c.Context(), record.ID, result.Status, active); err != nil {
+			return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Status update failed", Data: nil})
+		}
+		record.SubmissionStatus, record.Active = result.Status, active
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "E-invoice status", Data: record})
+}
+
+// prepare dựng và kiểm tra XML hóa đơn điện tử theo lược đồ, chưa cấp số
+func (ctrl *EInvoiceController) prepare(c *fiber.Ctx, invoice *models.Invoice, buyer models.EInvoiceBuyer, replaces *einvoice.Reference) (*einvoice.Document, error) {
+	setting, err := ctrl.settings.Get(c.Context())
+	if err != nil {
+		return nil, &einvoice.ValidationError{Problems: []string{"store settings are not configured"}}
+	}
+	// Kiểm tra với số tạm, số thật được cấp khi lưu
+	doc, err := einvoice.Build(*invoice, *setting, buyer, 1)
+	if err != nil {
+		return nil, &einvoice.ValidationError{Problems: []string{err.Error()}}
+	}
+	doc.Replaces = replaces
+	if err := einvoice.Validate(doc.XML(), false); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// maxNumberAttempts là số lần thử lấy số mới khi bị request khác lấy mất số
+const maxNumberAttempts = 5
+
+// store cấp số, ký và lưu hóa đơn điện tử; fill điền các trường riêng của từng thao tác trước khi lưu.
+// Số hóa đơn = số lớn nhất đã lưu của mẫu số + ký hiệu cộng 1 và chỉ thành số đã dùng khi bản ghi được lưu
+// (unique index templateCode + series + number), nên lỗi kiểm tra, ký hay lưu không làm nhảy số. Hai request
+// lấy cùng một số thì request lưu sau bị trùng khoá và lấy lại số mới.
+func (ctrl *EInvoiceController) store(c *fiber.Ctx, invoice *models.Invoice, buyer models.EInvoiceBuyer, doc *einvoice.Document, fill func(*models.EInvoice)) (*models.EInvoice, error) {
+	for attempt := 0; attempt < maxNumberAttempts; attempt++ {
+		last, err := ctrl.repo.LastNumber(c.Context(), doc.TemplateCode, doc.Series)
+		if err != nil {
+			return nil, err
+		}
+		doc.Number = last + 1
+		signed, err := ctrl.signer.Sign(doc.XML())
+		if err != nil {
+			return nil, err
+		}
+		if err := einvoice.Validate(signed, true); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		record := &models.EInvoice{
+			InvoiceID:        invoice.ID,
+			InvoiceCode:      invoice.Code,
+			TemplateCode:     doc.TemplateCode,
+			Series:           doc.Series,
+			Number:           doc.Number,
+			IssuedAt:         doc.IssueDate,
+			Buyer:            buyer,
+			TotalAmount:      doc.TotalAmount,
+			TotalTax:         doc.TotalTax,
+			TotalPayment:     doc.TotalPayment,
+			SignedBy:         ctrl.signer.Subject(),
+			XML:              string(signed),
+			CreatedBy:        middleware.CurrentUserID(c),
+			Provider:         ctrl.submitter.Provider().Name(),
+			SubmissionStatus: models.EInvoicePending,
+			NextAttemptAt:    &now,
+			Submissions:      []models.EInvoiceSubmission{},
+		}
+		fill(record)
+		created, err := ctrl.repo.Create(c.Context(), *record)
+		if !mongo.IsDuplicateKeyError(err) {
+			return created, err
+		}
+		// Trùng khoá không phải do số hóa đơn (vd. hóa đơn đã có bản đang hiệu lực) thì trả lỗi cho caller
+		taken, findErr := ctrl.repo.NumberTaken(c.Context(), doc.TemplateCode, doc.Series, doc.Number)
+		if findErr != nil {
+			return nil, findErr
+		}
+		if !taken {
+			return nil, err
+		}
+	}
+	return nil, errors.New("could not allocate an e-invoice number")
+}
+
+// submitted gửi ngay thao tác đang chờ rồi trả về bản ghi sau khi gửi
+func (ctrl *EInvoiceController) submitted(c *fiber.Ctx, status int, id primitive.ObjectID, message string) error {
+	if err := ctrl.submitter.Process(c.Context(), id); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Submission failed", Data: nil})
+	}
+	record, err := ctrl.repo.FindByID(c.Context(), id)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Submission failed", Data: nil})
+	}
+	return c.Status(status).JSON(models.APIResponse{Status: "success", Message: message, Data: record})
+}
+
+// buyerOf lấy thông tin người mua từ body, hoặc từ báo giá gốc nếu không gửi
+func (ctrl *EInvoiceController) buyerOf(c *fiber.Ctx, invoice *models.Invoice, buyer *models.EInvoiceBuyer) models.EInvoiceBuyer {
+	if buyer != nil {
+		return *buyer
+	}
+	if invoice.QuotationID != nil {
+		if quotation, err := ctrl.quotations.FindByID(c.Context(), invoice.QuotationID.Hex()); err == nil {
+			return models.EInvoiceBuyer{
+				Name:    quotation.CustomerName,
+				TaxCode: quotation.CustomerTaxCode,
+				Address: quotation.CustomerAddress,
+				Phone:   quotation.CustomerPhone,
+				Email:   quotation.CustomerEmail,
+			}
+		}
+	}
+	return models.EInvoiceBuyer{}
+}
+
+// invalidEInvoice trả 422 kèm danh sách lỗi cấu trúc
+func invalidEInvoice(c *fiber.Ctx, err error) error {
+	var invalid *einvoice.ValidationError
+	if errors.As(err, &invalid) {
+		return c.Status(422).JSON(models.APIResponse{Status: "error", Message: "E-invoice validation failed", Data: invalid.Problems})
+	}
+	log.Printf("⚠️ Lập hóa đơn điện tử lỗi: %v\n", err)
+	return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Issue failed", Data: nil})
+}
+
+// Get trả về thông tin hóa đơn điện tử của hóa đơn (không kèm XML)
+//
+// @route GET /api/invoices/:id/einvoice
+func (ctrl *EInvoiceController) Get(c *fiber.Ctx) error {
+	record, err := ctrl.find(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "E-invoice", Data: record})
+}
+
+// XML tải XML hóa đơn điện tử đã ký
+//
+// @route GET /api/invoices/:id/einvoice/xml
+func (ctrl *EInvoiceController) XML(c *fiber.Ctx) error {
+	record, err := ctrl.find(c)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "E-invoice not found", Data: nil})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	c.Attachment(record.Series + "-" + record.InvoiceCode + ".xml")
+	return c.SendString(record.XML)
+}
+
+func (ctrl *EInvoiceController) find(c *fiber.Ctx) (*models.EInvoice, error) {
+	invoice, err := ctrl.invoices.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	return ctrl.repo.FindByInvoice(c.Context(), invoice.ID)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+)
+
+// HeldOrderController xử lý giỏ hàng tạm giữ tại quầy thu ngân
+type HeldOrderController struct {
+	repo     *repositories.HeldOrderRepository
+	invoices *InvoiceController
+}
+
+func NewHeldOrderController(repo *repositories.HeldOrderRepository, invoices *InvoiceController) *HeldOrderController {
+	return &HeldOrderController{repo: repo, invoices: invoices}
+}
+
+// Create tạm giữ giỏ hàng hiện tại
+//
+// @route POST /api/held-orders
+//
+//	@body {
+//	  "label": "Bàn 3",
+//	  "items": [{ "productId": "xxx", "name": "Áo thun", "quantity": 1, "price": 120000 }],
+//	  "note": "",
+//	  "paymentMethod": "card",
+//	  "discount": 10000
+//	}
+func (ctrl *HeldOrderController) Create(c *fiber.Ctx) error {
+	var order models.HeldOrder
+	if err := c.BodyParser(&order); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if len(order.Items) == 0 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Cart is empty", Data: nil})
+	}
+	if order.PaymentMethod != "" && !slices.Contains(models.PaymentMethods, order.PaymentMethod) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid payment method", Data: nil})
+	}
+	if order.Discount < 0 || order.Discount > models.InvoiceTotal(order.Items) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Discount must be between 0 and the invoice total", Data: nil})
+	}
+	order.CreatedBy = middleware.CurrentUserID(c)
+
+	created, err := ctrl.repo.Create(c.Context(), order)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create failed", Data: nil})
+	}
+	audit(c, models.AuditHeldOrderCreate, created.ID.Hex(), nil, created)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Order held", Data: created})
+}
+
+// List trả về danh sách giỏ đang tạm giữ
+//
+// @route GET /api/held-orders?mine=true
+func (ctrl *HeldOrderController) List(c *fiber.Ctx) error {
+	createdBy := ""
+	if c.QueryBool("mine") {
+		createdBy = middleware.CurrentUserID(c)
+	}
+	orders, err := ctrl.repo.List(c.Context(), createdBy)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Held orders", Data: orders})
+}
+
+// Get xem chi tiết giỏ tạm giữ (không lấy ra khỏi danh sách)
+//
+// @route GET /api/held-orders/:id
+func (ctrl *HeldOrderController) Get(c *fiber.Ctx) error {
+	order, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Held order not found", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Held order", Data: order})
+}
+
+// Resume lấy giỏ ra khỏi danh sách tạm giữ để tiếp tục bán trên quầy
+//
+// @route POST /api/held-orders/:id/resume
+func (ctrl *HeldOrderController) Resume(c *fiber.Ctx) error {
+	order, err := ctrl.repo.Take(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Held order not found", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Held order resumed", Data: order})
+}
+
+// Discard huỷ giỏ tạm giữ
+//
+// @route DELETE /api/held-orders/:id
+func (ctrl *HeldOrderController) Discard(c *fiber.Ctx) error {
+	order, err := ctrl.repo.Take(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Held order not found", Data: nil})
+	}
+	audit(c, models.AuditHeldOrderDiscard, order.ID.Hex(), order, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Held order discarded", Data: nil})
+}
+
+// Convert chuyển giỏ tạm giữ thành hóa đơn (cùng luồng tạo với POST /api/invoices).
+// paymentMethod, discount trong body (tùy chọn) ghi đè giá trị đã lưu khi tạm giữ.
+//
+// @route POST /api/held-orders/:id/convert
+// @body { "paymentMethod": "transfer", "discount": 0 }
+func (ctrl *HeldOrderController) Convert(c *fiber.Ctx) error {
+	payment, err := parsePayment(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	order, err := ctrl.repo.Take(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Held order not found", Data: nil})
+	}
+
+	draft := models.Invoice{Items: order.Items, Note: order.Note, PaymentMethod: order.PaymentMethod, Discount: order.Discount}
+	payment.apply(&draft)
+	invoice, err := ctrl.invoices.createInvoice(c, draft)
+	if err != nil {
+		// Trả giỏ về danh sách để thu ngân thử lại
+		_ = ctrl.repo.Restore(c.Context(), *order)
+		return createFailed(c, err)
+	}
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Invoice created", Data: invoice})
+}
@@ -1,19 +1,96 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
 	"go-fiber-api/models"
 	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"slices"
 	"strings"
 	"time"
 )
 
 type InvoiceController struct {
-	repo *repositories.InvoiceRepository
+	repo      *repositories.InvoiceRepository
+	revisions *repositories.InvoiceRevisionRepository
+	shifts    *repositories.ShiftRepository
+	einvoices *repositories.EInvoiceRepository
 }
 
-func NewInvoiceController(repo *repositories.InvoiceRepository) *InvoiceController {
-	return &InvoiceController{repo: repo}
+func NewInvoiceController(repo *repositories.InvoiceRepository, revisions *repositories.InvoiceRevisionRepository, shifts *repositories.ShiftRepository, einvoices *repositories.EInvoiceRepository) *InvoiceController {
+	return &InvoiceController{repo: repo, revisions: revisions, shifts: shifts, einvoices: einvoices}
+}
+
+// invalidInvoiceError là lỗi dữ liệu hóa đơn không hợp lệ (trả 400 thay vì 500)
+type invalidInvoiceError struct{ message string }
+
+func (e invalidInvoiceError) Error() string { return e.message }
+
+// errNoOpenShift là lỗi tạo hóa đơn khi người bán chưa mở ca
+var errNoOpenShift = errors.New("no open shift")
+
+// paymentInput là phương thức thanh toán và giảm giá gửi kèm khi chuyển giỏ tạm giữ / báo giá thành hóa đơn
+type paymentInput struct {
+	PaymentMethod string   `json:"paymentMethod"`
+	Discount      *float64 `json:"discount"`
+}
+
+// parsePayment đọc paymentInput từ body; body rỗng -> không ghi đè gì
+func parsePayment(c *fiber.Ctx) (paymentInput, error) {
+	var in paymentInput
+	if len(c.Body()) == 0 {
+		return in, nil
+	}
+	err := c.BodyParser(&in)
+	return in, err
+}
+
+// apply ghi đè phương thức thanh toán, giảm giá lên invoice; trường không gửi thì giữ nguyên
+func (in paymentInput) apply(invoice *models.Invoice) {
+	if in.PaymentMethod != "" {
+		invoice.PaymentMethod = in.PaymentMethod
+	}
+	if in.Discount != nil {
+		invoice.Discount = *in.Discount
+	}
+}
+
+// checkNoEInvoice chặn sửa / huỷ / trả hàng khi hóa đơn còn hóa đơn điện tử chưa huỷ, vì XML đã phát hành
+// sẽ không còn khớp với hóa đơn; phải thay thế hoặc huỷ hóa đơn điện tử trước. Bị chặn -> đã ghi 409 và trả về false
+func (ctrl *InvoiceController) checkNoEInvoice(c *fiber.Ctx, invoiceID primitive.ObjectID) (bool, error) {
+	einvoice, err := ctrl.einvoices.FindByInvoice(c.Context(), invoiceID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return true, nil
+	}
+	if err != nil {
+		return false, c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Error checking e-invoice", Data: nil})
+	}
+	if einvoice.SubmissionStatus == models.EInvoiceCancelled {
+		return true, nil
+	}
+	return false, c.Status(409).JSON(models.APIResponse{
+		Status:  "error",
+		Message: "Invoice has an e-invoice, replace or cancel the e-invoice first",
+		Data:    einvoice,
+	})
+}
+
+// createFailed trả response lỗi cho createInvoice: 400 nếu dữ liệu không hợp lệ, 409 nếu chưa mở ca, ngược lại 500
+func createFailed(c *fiber.Ctx, err error) error {
+	var invalid invalidInvoiceError
+	if errors.As(err, &invalid) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: invalid.message, Data: nil})
+	}
+	if errors.Is(err, errNoOpenShift) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Open a shift before creating invoices", Data: nil})
+	}
+	return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create failed", Data: nil})
 }
 
 // Create tạo hóa đơn mới
@@ -26,8 +103,13 @@ func NewInvoiceController(repo *repositories.InvoiceRepository) *InvoiceControll
 //	  "items": [
 //	    { "productId": "xxx", "name": "Áo sơ mi", "quantity": 2, "price": 150000 }
 //	  ],
-//	  "note": "Khách mua online"
+//	  "note": "Khách mua online",
+//	  "paymentMethod": "cash",
+//	  "discount": 20000
 //	}
+//
+// paymentMethod: cash (mặc định) | card | transfer | ewallet. Hóa đơn được gán vào ca đang mở của người tạo.
+// Người tạo (createdBy, createdByName) luôn lấy từ JWT, bỏ qua giá trị gửi trong body.
 func (ctrl *InvoiceController) Create(c *fiber.Ctx) error {
 	var invoice models.Invoice
 	if err := c.BodyParser(&invoice); err != nil {
@@ -38,13 +120,9 @@ func (ctrl *InvoiceController) Create(c *fiber.Ctx) error {
 		})
 	}
 
-	createdInvoice, err := ctrl.repo.Create(c.Context(), invoice)
+	createdInvoice, err := ctrl.createInvoice(c, invoice)
 	if err != nil {
-		return c.Status(500).JSON(models.APIResponse{
-			Status:  "error",
-			Message: "Create failed",
-			Data:    nil,
-		})
+		return createFailed(c, err)
 	}
 
 	return c.Status(201).JSON(models.APIResponse{
@@ -54,24 +132,82 @@ func (ctrl *InvoiceController) Create(c *fiber.Ctx) error {
 	})
 }
 
-// Delete xoá một hoặc nhiều hóa đơn theo ID
+// createInvoice là luồng tạo hóa đơn dùng chung cho POST /api/invoices, đồng bộ offline và các chức năng chuyển thành hóa đơn.
+// Ghi nhận người tạo từ JWT, kiểm tra phương thức thanh toán, giảm giá và gán hóa đơn vào ca đang mở của người tạo.
+// Người tạo chưa mở ca -> errNoOpenShift, trừ hóa đơn đồng bộ offline (có clientId) và hóa đơn tạo bằng API key.
+func (ctrl *InvoiceController) createInvoice(c *fiber.Ctx, invoice models.Invoice) (*models.Invoice, error) {
+	invoice.CreatedBy, invoice.CreatedByName = middleware.CurrentUserID(c), ""
+	if user, err := repositories.FindUserByID(invoice.CreatedBy); err == nil {
+		invoice.CreatedByName = user.Username
+	}
+
+	if invoice.PaymentMethod == "" {
+		invoice.PaymentMethod = models.PaymentCash
+	}
+	if !slices.Contains(models.PaymentMethods, invoice.PaymentMethod) {
+		return nil, invalidInvoiceError{"Invalid payment method"}
+	}
+	if invoice.Discount < 0 || invoice.Discount > models.InvoiceTotal(invoice.Items) {
+		return nil, invalidInvoiceError{"Discount must be between 0 and the invoice total"}
+	}
+
+	invoice.ShiftID = nil
+	shift, err := ctrl.shifts.AcquireOpen(c.Context(), invoice.CreatedBy)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	if shift != nil {
+		invoice.ShiftID = &shift.ID
+		defer ctrl.shifts.ReleaseWrite(context.Background(), shift.ID)
+	} else if invoice.ClientID == "" && middleware.CurrentAPIKey(c) == nil {
+		// Chỉ hóa đơn đồng bộ offline và hóa đơn do tích hợp (API key) tạo được phép không thuộc ca nào
+		return nil, errNoOpenShift
+	}
+	created, err := ctrl.repo.Create(c.Context(), invoice)
+	if err != nil {
+		return nil, err
+	}
+	audit(c, models.AuditInvoiceCreate, created.ID.Hex(), nil, created)
+	return created, nil
+}
+
+// Delete xoá hẳn một hoặc nhiều hóa đơn đã huỷ theo ID. Hóa đơn chưa huỷ hoặc đã lập hóa đơn điện tử
+// không xoá được (409) để giữ số chứng từ liên tục, doanh số của ca và liên kết với hóa đơn điện tử.
 //
 // @route  DELETE /api/invoices?id=66a1...,66a2...
 func (ctrl *InvoiceController) Delete(c *fiber.Ctx) error {
 	ids := strings.Split(c.Query("id"), ",")
+	deleted := map[string]*models.Invoice{}
+	for _, id := range ids {
+		invoice, err := ctrl.repo.FindByID(c.Context(), id)
+		if err != nil {
+			continue
+		}
+		if invoice.Status != models.InvoiceStatusVoid {
+			return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Only voided invoices can be deleted", Data: fiber.Map{"id": id}})
+		}
+		if _, err := ctrl.einvoices.FindByInvoice(c.Context(), invoice.ID); err == nil {
+			return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Invoices with an e-invoice cannot be deleted", Data: fiber.Map{"id": id}})
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Delete failed", Data: nil})
+		}
+		deleted[id] = invoice
+	}
 	if err := ctrl.repo.DeleteMany(c.Context(), ids); err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Delete failed", Data: nil})
 	}
+	for id, invoice := range deleted {
+		audit(c, models.AuditInvoiceDelete, id, invoice, nil)
+	}
 	return c.JSON(models.APIResponse{Status: "success", Message: "Invoices deleted", Data: nil})
 }
 
-// FilterByDate lọc hóa đơn theo khoảng ngày (tùy chọn), mã code (tùy chọn), phân trang + thống kê
+// FilterByDate lọc hóa đơn theo khoảng ngày, mã code, trạng thái (đều tùy chọn), phân trang + thống kê.
+// Thống kê (total, totalAmount, productStats) tính trên toàn bộ hóa đơn khớp bộ lọc, không chỉ trang hiện tại;
+// không lọc status thì totalAmount, productStats chỉ tính hóa đơn completed.
 //
-// @route  GET /api/invoices/filter?from=01/05/2025&to=31/05/2025&page=1&limit=10&code=HD20250610
+// @route  GET /api/invoices?from=01/05/2025&to=31/05/2025&page=1&limit=10&code=HD20250610&status=completed&createdBy=66a1...
 func (ctrl *InvoiceController) FilterByDate(c *fiber.Ctx) error {
-	fromStr := c.Query("from")
-	toStr := c.Query("to")
-	code := c.Query("code")
 	limitStr := c.Query("limit")
 
 	page := c.QueryInt("page", 1)
@@ -82,61 +218,22 @@ func (ctrl *InvoiceController) FilterByDate(c *fiber.Ctx) error {
 		limit = 0
 	}
 
-	var fromTime, toTime time.Time
-	filterByDate := fromStr != "" && toStr != ""
-	filterByCode := code != ""
-
-	if filterByDate {
-		var err1, err2 error
-		fromTime, err1 = time.ParseInLocation("02/01/2006", fromStr, time.FixedZone("GMT+7", 7*3600))
-		toTime, err2 = time.ParseInLocation("02/01/2006", toStr, time.FixedZone("GMT+7", 7*3600))
-		if err1 != nil || err2 != nil {
-			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid date format (dd/mm/yyyy)", Data: nil})
-		}
-		toTime = toTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	filter, err := parseInvoiceFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: err.Error(), Data: nil})
 	}
 
-	var (
-		invoices []models.Invoice
-		total    int64
-		err      error
-	)
-
-	switch {
-	case filterByDate && filterByCode:
-		invoices, total, err = ctrl.repo.ListByCodeAndDatePaginated(c.Context(), code, fromTime, toTime, int64(page), int64(limit))
-	case filterByDate:
-		invoices, total, err = ctrl.repo.ListByDateRangePaginated(c.Context(), fromTime, toTime, int64(page), int64(limit))
-	case filterByCode:
-		invoices, err = ctrl.repo.ListByCode(c.Context(), code)
-		total = int64(len(invoices))
-	default:
-		invoices, total, err = ctrl.repo.ListPaginated(c.Context(), int64(page), int64(limit))
+	invoices, err := ctrl.repo.List(c.Context(), filter, int64(page), int64(limit))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
 	}
-
+	stats, err := ctrl.repo.Stats(c.Context(), filter)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
 	}
-
-	// Thống kê sản phẩm trên kết quả trả về
-	type ProductStats struct {
-		Name     string  `json:"name"`
-		Quantity int     `json:"quantity"`
-		Revenue  float64 `json:"revenue"`
-	}
-	products := make(map[string]*ProductStats)
-	var totalAmount float64
-
-	for _, inv := range invoices {
-		for _, item := range inv.Items {
-			if _, ok := products[item.Name]; !ok {
-				products[item.Name] = &ProductStats{Name: item.Name}
-			}
-			stat := products[item.Name]
-			stat.Quantity += item.Quantity
-			stat.Revenue += float64(item.Quantity) * item.Price
-			totalAmount += float64(item.Quantity) * item.Price
-		}
+	total, err := ctrl.repo.Count(c.Context(), filter)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
 	}
 
 	return c.JSON(models.APIResponse{Status: "success", Message: "Filtered invoices", Data: fiber.Map{
@@ -144,12 +241,40 @@ func (ctrl *InvoiceController) FilterByDate(c *fiber.Ctx) error {
 		"page":         page,
 		"limit":        limit,
 		"total":        total,
-		"totalAmount":  totalAmount,
-		"productStats": products,
+		"totalAmount":  stats.TotalAmount,
+		"productStats": stats.Products,
 	}})
 }
 
+// parseInvoiceFilter đọc bộ lọc hóa đơn từ query: from, to (dd/mm/yyyy, giờ GMT+7), code, status, createdBy
+func parseInvoiceFilter(c *fiber.Ctx) (repositories.InvoiceFilter, error) {
+	filter := repositories.InvoiceFilter{
+		Code:      c.Query("code"),
+		Status:    c.Query("status"),
+		CreatedBy: c.Query("createdBy"),
+	}
+	switch filter.Status {
+	case "", models.InvoiceStatusCompleted, models.InvoiceStatusVoid, models.InvoiceStatusReturned:
+	default:
+		return filter, errors.New("Invalid status")
+	}
+
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if fromStr != "" && toStr != "" {
+		loc := time.FixedZone("GMT+7", 7*3600)
+		fromTime, err1 := time.ParseInLocation("02/01/2006", fromStr, loc)
+		toTime, err2 := time.ParseInLocation("02/01/2006", toStr, loc)
+		if err1 != nil || err2 != nil {
+			return filter, errors.New("Invalid date format (dd/mm/yyyy)")
+		}
+		toTime = toTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+		filter.From, filter.To = &fromTime, &toTime
+	}
+	return filter, nil
+}
+
 // Update cập nhật thông tin hóa đơn (sản phẩm, số lượng, cửa hàng, ghi chú)
+// Trạng thái trước khi sửa được lưu vào lịch sử revision kèm người sửa và lý do.
 //
 // @route PUT /api/invoices
 //
@@ -160,17 +285,27 @@ func (ctrl *InvoiceController) FilterByDate(c *fiber.Ctx) error {
 //	  "items": [
 //	    { "productId": "xxx", "name": "Áo thun", "quantity": 1, "price": 120000 }
 //	  ],
-//	  "note": "Cập nhật đơn hàng"
+//	  "note": "Cập nhật đơn hàng",
+//	  "reason": "Khách đổi size",
+//	  "version": 2
 //	}
+//
+// Có thể gửi version qua header If-Match thay cho body. Version cũ -> 409 kèm hóa đơn hiện tại.
+// Chỉ sửa được hóa đơn completed; hóa đơn đã huỷ hoặc trả hàng -> 409. Tổng tiền mới nhỏ hơn giảm giá -> 400.
+// Hóa đơn còn hóa đơn điện tử chưa huỷ -> 409, phải thay thế hoặc huỷ hóa đơn điện tử trước.
 func (ctrl *InvoiceController) Update(c *fiber.Ctx) error {
-	var invoice models.Invoice
-	if err := c.BodyParser(&invoice); err != nil {
+	var body struct {
+		models.Invoice
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(models.APIResponse{
 			Status:  "error",
 			Message: "Invalid input",
 			Data:    nil,
 		})
 	}
+	invoice := body.Invoice
 	if invoice.ID.IsZero() {
 		return c.Status(400).JSON(models.APIResponse{
 			Status:  "error",
@@ -179,8 +314,45 @@ func (ctrl *InvoiceController) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	version, ok := requestVersion(c)
+	if !ok {
+		return missingVersion(c)
+	}
+	invoice.Version = version
+	invoice.UpdatedBy = middleware.CurrentUserID(c)
+	if ok, err := ctrl.checkNoEInvoice(c, invoice.ID); !ok {
+		return err
+	}
+
 	id := invoice.ID.Hex()
-	if err := ctrl.repo.Update(c.Context(), id, invoice); err != nil {
+	previous, err := ctrl.repo.Update(c.Context(), id, invoice)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		current, _ := ctrl.repo.FindByID(c.Context(), id)
+		return versionConflict(c, current)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Invoice not found",
+			Data:    nil,
+		})
+	}
+	if errors.Is(err, repositories.ErrInvoiceNotEditable) {
+		current, _ := ctrl.repo.FindByID(c.Context(), id)
+		return c.Status(409).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Void or returned invoices cannot be edited",
+			Data:    current,
+		})
+	}
+	if errors.Is(err, repositories.ErrDiscountExceedsTotal) {
+		return c.Status(400).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Invoice total must not be less than its discount",
+			Data:    nil,
+		})
+	}
+	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Status:  "error",
 			Message: "Update failed",
@@ -188,9 +360,188 @@ func (ctrl *InvoiceController) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	revision := models.InvoiceRevision{
+		InvoiceID:   previous.ID,
+		Revision:    previous.Version,
+		Items:       previous.Items,
+		Note:        previous.Note,
+		TotalAmount: models.InvoiceNetTotal(*previous),
+		EditedBy:    invoice.UpdatedBy,
+		EditedAt:    time.Now().In(time.FixedZone("GMT+7", 7*3600)),
+		Reason:      body.Reason,
+	}
+	if _, err := ctrl.revisions.Create(c.Context(), revision); err != nil {
+		// MongoDB standalone không có transaction: hoàn tác lần sửa để hóa đơn không đổi mà thiếu revision
+		message := "Update failed"
+		if restoreErr := ctrl.repo.Restore(c.Context(), *previous); restoreErr != nil {
+			log.Printf("❌ Invoice %s updated but revision %d not recorded: %v (rollback: %v)\n", id, previous.Version, err, restoreErr)
+			message = "Invoice updated but failed to record revision"
+		}
+		return c.Status(500).JSON(models.APIResponse{
+			Status:  "error",
+			Message: message,
+			Data:    nil,
+		})
+	}
+
+	updated, _ := ctrl.repo.FindByID(c.Context(), id)
+	audit(c, models.AuditInvoiceUpdate, id, previous, updated)
+
+	setETag(c, previous.Version+1)
 	return c.JSON(models.APIResponse{
 		Status:  "success",
 		Message: "Invoice updated",
-		Data:    nil,
+		Data:    fiber.Map{"version": previous.Version + 1},
+	})
+}
+
+// Void huỷ hóa đơn đã hoàn tất. Hóa đơn huỷ vẫn được lưu và hiện trong báo cáo Z của ca đã bán.
+//
+// @route POST /api/invoices/:id/void
+//
+//	@body { "reason": "Nhập sai sản phẩm" }
+func (ctrl *InvoiceController) Void(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, models.AuditInvoiceVoid, func(id primitive.ObjectID, by, reason string) (*models.Invoice, error) {
+		return ctrl.repo.Void(c.Context(), id, by, reason)
 	})
 }
+
+// Return ghi nhận khách trả toàn bộ hóa đơn, hoàn lại số tiền khách đã trả.
+// Tiền hoàn được tính vào ca đang mở của người thực hiện (có thể khác ca bán).
+//
+// @route POST /api/invoices/:id/return
+//
+//	@body { "reason": "Sản phẩm lỗi" }
+func (ctrl *InvoiceController) Return(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, models.AuditInvoiceReturn, func(id primitive.ObjectID, by, reason string) (*models.Invoice, error) {
+		var shiftID *primitive.ObjectID
+		shift, err := ctrl.shifts.AcquireOpen(c.Context(), by)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		if shift != nil {
+			shiftID = &shift.ID
+			defer ctrl.shifts.ReleaseWrite(context.Background(), shift.ID)
+		}
+		return ctrl.repo.Return(c.Context(), id, by, reason, shiftID)
+	})
+}
+
+// changeStatus là phần dùng chung của Void và Return: đọc lý do, chuyển trạng thái hóa đơn completed, ghi nhật ký action.
+// Hóa đơn còn hóa đơn điện tử chưa huỷ -> 409.
+func (ctrl *InvoiceController) changeStatus(c *fiber.Ctx, action string, apply func(id primitive.ObjectID, by, reason string) (*models.Invoice, error)) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Reason is required", Data: nil})
+	}
+
+	current, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Invoice not found", Data: nil})
+	}
+	if ok, err := ctrl.checkNoEInvoice(c, current.ID); !ok {
+		return err
+	}
+	invoice, err := apply(current.ID, middleware.CurrentUserID(c), strings.TrimSpace(body.Reason))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Only completed invoices can be voided or returned", Data: current})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update failed", Data: nil})
+	}
+	audit(c, action, invoice.ID.Hex(), current, invoice)
+	setETag(c, invoice.Version)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Invoice " + invoice.Status, Data: invoice})
+}
+
+// History trả về lịch sử chỉnh sửa của hóa đơn, mỗi revision kèm danh sách trường thay đổi
+// so với phiên bản kế tiếp (hoặc so với hóa đơn hiện tại với revision mới nhất)
+//
+// @route GET /api/invoices/:id/history
+func (ctrl *InvoiceController) History(c *fiber.Ctx) error {
+	invoice, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Invoice not found", Data: nil})
+	}
+
+	revisions, err := ctrl.revisions.ListByInvoice(c.Context(), invoice.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get history failed", Data: nil})
+	}
+
+	type historyEntry struct {
+		models.InvoiceRevision
+		Changes []models.FieldChange `json:"changes"`
+	}
+	history := make([]historyEntry, 0, len(revisions))
+	for i, rev := range revisions {
+		nextItems, nextNote := invoice.Items, invoice.Note
+		if i+1 < len(revisions) {
+			nextItems, nextNote = revisions[i+1].Items, revisions[i+1].Note
+		}
+		history = append(history, historyEntry{
+			InvoiceRevision: rev,
+			Changes:         diffInvoice(rev.Items, rev.Note, nextItems, nextNote, invoice.Discount),
+		})
+	}
+
+	return c.JSON(models.APIResponse{Status: "success", Message: "Invoice history", Data: fiber.Map{
+		"invoice":   invoice,
+		"revisions": history,
+	}})
+}
+
+// diffInvoice so sánh hai phiên bản hóa đơn theo từng trường: ghi chú, tổng tiền (sau giảm giá, giảm giá không
+// sửa được nên hai phiên bản dùng chung discount) và từng dòng sản phẩm
+func diffInvoice(oldItems []models.InvoiceItem, oldNote string, newItems []models.InvoiceItem, newNote string, discount float64) []models.FieldChange {
+	changes := []models.FieldChange{}
+	if oldNote != newNote {
+		changes = append(changes, models.FieldChange{Field: "note", Old: oldNote, New: newNote})
+	}
+	if oldTotal, newTotal := models.InvoiceTotal(oldItems)-discount, models.InvoiceTotal(newItems)-discount; oldTotal != newTotal {
+		changes = append(changes, models.FieldChange{Field: "totalAmount", Old: oldTotal, New: newTotal})
+	}
+
+	// Ghép dòng sản phẩm theo productId (hoặc tên nếu không có productId)
+	itemKey := func(item models.InvoiceItem) string {
+		if !item.ProductID.IsZero() {
+			return item.ProductID.Hex()
+		}
+		return item.Name
+	}
+	oldByKey := make(map[string]models.InvoiceItem, len(oldItems))
+	for _, item := range oldItems {
+		oldByKey[itemKey(item)] = item
+	}
+	seen := make(map[string]bool, len(newItems))
+	for _, item := range newItems {
+		key := itemKey(item)
+		seen[key] = true
+		field := fmt.Sprintf("items[%s]", key)
+		prev, ok := oldByKey[key]
+		if !ok {
+			changes = append(changes, models.FieldChange{Field: field, Old: nil, New: item})
+			continue
+		}
+		if prev.Name != item.Name {
+			changes = append(changes, models.FieldChange{Field: field + ".name", Old: prev.Name, New: item.Name})
+		}
+		if prev.Quantity != item.Quantity {
+			changes = append(changes, models.FieldChange{Field: field + ".quantity", Old: prev.Quantity, New: item.Quantity})
+		}
+		if prev.Price != item.Price {
+			changes = append(changes, models.FieldChange{Field: field + ".price", Old: prev.Price, New: item.Price})
+		}
+	}
+	for _, item := range oldItems {
+		if key := itemKey(item); !seen[key] {
+			changes = append(changes, models.FieldChange{Field: fmt.Sprintf("items[%s]", key), Old: item, New: nil})
+		}
+	}
+	return changes
+}
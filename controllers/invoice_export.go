@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/models"
+	"go-fiber-api/utils"
+)
+
+// exportFlushRows là số dòng ghi giữa hai lần đẩy dữ liệu ra client
+const exportFlushRows = 500
+
+var invoiceStatusLabels = map[string]string{
+	models.InvoiceStatusCompleted: "Hoàn tất",
+	models.InvoiceStatusVoid:      "Đã huỷ",
+	models.InvoiceStatusReturned:  "Trả hàng",
+}
+
+var paymentMethodLabels = map[string]string{
+	models.PaymentCash:     "Tiền mặt",
+	models.PaymentCard:     "Thẻ",
+	models.PaymentTransfer: "Chuyển khoản",
+	models.PaymentEWallet:  "Ví điện tử",
+}
+
+// rowWriter là định dạng file xuất (CSV hoặc XLSX)
+type rowWriter interface {
+	WriteHeader(titles ...string) error
+	WriteRow(cells ...any) error
+	Close() error
+}
+
+// csvRowWriter ghi CSV UTF-8 có BOM để Excel hiển thị đúng tiếng Việt
+type csvRowWriter struct {
+	csv *csv.Writer
+}
+
+func newCSVRowWriter(w io.Writer) (*csvRowWriter, error) {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{csv: csv.NewWriter(w)}, nil
+}
+
+func (cw *csvRowWriter) WriteHeader(titles ...string) error {
+	return cw.csv.Write(titles)
+}
+
+// WriteRow ghi một dòng; tiền (float64) làm tròn, không có phần thập phân; ô chữ được chặn chèn công thức
+func (cw *csvRowWriter) WriteRow(cells ...any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case float64:
+			record[i] = strconv.FormatFloat(math.Round(v), 'f', 0, 64)
+		default:
+			record[i] = utils.SpreadsheetSafe(fmt.Sprint(v))
+		}
+	}
+	return cw.csv.Write(record)
+}
+
+func (cw *csvRowWriter) Close() error {
+	cw.csv.Flush()
+	return cw.csv.Error()
+}
+
+// Export xuất hóa đơn khớp bộ lọc (giống GET /api/invoices) ra file CSV hoặc XLSX.
+// Dữ liệu được stream từ cursor MongoDB ra client nên xuất được khoảng ngày lớn.
+//
+//   - format: csv (mặc định) | xlsx
+//   - layout: invoice (mặc định, mỗi hóa đơn một dòng) | item (mỗi dòng sản phẩm một dòng)
+//
+// Ngày theo định dạng dd/mm/yyyy (GMT+7), tiền VND không có phần thập phân.
+//
+// @route GET /api/invoices/export?format=xlsx&layout=item&from=01/05/2025&to=31/05/2025&code=HD&status=completed
+func (ctrl *InvoiceController) Export(c *fiber.Ctx) error {
+	format := c.Query("format", "csv")
+	layout := c.Query("layout", "invoice")
+	if format != "csv" && format != "xlsx" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "format must be csv or xlsx", Data: nil})
+	}
+	if layout != "invoice" && layout != "item" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "layout must be invoice or item", Data: nil})
+	}
+	filter, err := parseInvoiceFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: err.Error(), Data: nil})
+	}
+
+	loc := time.FixedZone("GMT+7", 7*3600)
+	filename := fmt.Sprintf("hoa-don-%s.%s", time.Now().In(loc).Format("20060102-150405"), format)
+	if format == "xlsx" {
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	c.Attachment(filename)
+
+	// Hàm stream chạy sau khi handler trả về, không được dùng c bên trong
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var out rowWriter
+		var err error
+		if format == "xlsx" {
+			out, err = utils.NewXLSXWriter(w, "Hoa don")
+		} else {
+			out, err = newCSVRowWriter(w)
+		}
+		if err != nil {
+			log.Printf("⚠️ Xuất hóa đơn thất bại: %v\n", err)
+			return
+		}
+
+		if layout == "item" {
+			err = out.WriteHeader("Mã hóa đơn", "Ngày", "Trạng thái", "Người tạo", "Sản phẩm", "Số lượng", "Đơn giá", "Thành tiền")
+		} else {
+			err = out.WriteHeader("Mã hóa đơn", "Ngày", "Trạng thái", "Người tạo", "Thanh toán", "Số lượng SP", "Tiền hàng", "Giảm giá", "Thành tiền", "Ghi chú")
+		}
+
+		rows := 0
+		if err == nil {
+			err = ctrl.repo.Each(context.Background(), filter, func(invoice *models.Invoice) error {
+				if err := writeInvoiceRows(out, layout, invoice, loc); err != nil {
+					return err
+				}
+				if rows++; rows%exportFlushRows == 0 {
+					return w.Flush()
+				}
+				return nil
+			})
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// Header đã gửi nên không đổi được status code, file nhận được sẽ bị thiếu
+			log.Printf("⚠️ Xuất hóa đơn bị gián đoạn sau %d hóa đơn: %v\n", rows, err)
+		}
+	})
+	return nil
+}
+
+// writeInvoiceRows ghi một hóa đơn theo layout: một dòng, hoặc mỗi sản phẩm một dòng
+func writeInvoiceRows(out rowWriter, layout string, invoice *models.Invoice, loc *time.Location) error {
+	date := invoice.CreatedAt.In(loc).Format("02/01/2006")
+	status := invoice.Status
+	if status == "" {
+		status = models.InvoiceStatusCompleted
+	}
+	statusLabel := invoiceStatusLabels[status]
+	createdBy := invoice.CreatedByName
+	if createdBy == "" {
+		createdBy = invoice.CreatedBy
+	}
+
+	if layout == "item" {
+		for _, item := range invoice.Items {
+			lineTotal := float64(item.Quantity) * item.Price
+			if err := out.WriteRow(invoice.Code, date, statusLabel, createdBy, item.Name, item.Quantity, item.Price, lineTotal); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	quantity := 0
+	for _, item := range invoice.Items {
+		quantity += item.Quantity
+	}
+	method := invoice.PaymentMethod
+	if method == "" {
+		method = models.PaymentCash
+	}
+	return out.WriteRow(invoice.Code, date, statusLabel, createdBy, paymentMethodLabels[method], quantity,
+		models.InvoiceTotal(invoice.Items), invoice.Discount, models.InvoiceNetTotal(*invoice), invoice.Note)
+}
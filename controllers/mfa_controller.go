@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// backupCodeCount là số mã dự phòng phát cho mỗi lần bật 2FA / tạo lại mã
+const backupCodeCount = 10
+
+// MFAController quản lý xác thực hai lớp TOTP của người dùng: đăng ký, kích hoạt, tắt, mã dự phòng.
+// Bước nhập mã khi đăng nhập nằm ở AuthController.
+type MFAController struct {
+	repo   *repositories.MFARepository
+	roles  *repositories.RoleRepository
+	issuer string // Tên hiển thị trong ứng dụng xác thực
+}
+
+func NewMFAController(repo *repositories.MFARepository, roles *repositories.RoleRepository, issuer string) *MFAController {
+	return &MFAController{repo: repo, roles: roles, issuer: issuer}
+}
+
+// Status xem trạng thái 2FA của mình
+//
+// @route GET /api/users/2fa
+func (ctrl *MFAController) Status(c *fiber.Ctx) error {
+	required, mfa, err := ctrl.state(c.Context(), middleware.CurrentUserID(c), middleware.CurrentRole(c))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get 2FA status failed", Data: nil})
+	}
+	status := models.MFAStatus{Required: required}
+	if mfa != nil && mfa.Enabled {
+		status.Enabled, status.EnabledAt, status.BackupCodesRemaining = true, mfa.EnabledAt, len(mfa.BackupCodes)
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "2FA status", Data: status})
+}
+
+// Setup tạo khoá TOTP mới (chờ kích hoạt) và trả về provisioning URI để client hiển thị mã QR.
+// Khoá chỉ có hiệu lực sau khi gọi Enable với mã đúng.
+//
+// @route POST /api/users/2fa/setup
+func (ctrl *MFAController) Setup(c *fiber.Ctx) error {
+	user, err := repositories.FindUserByID(middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	return ctrl.setup(c, user)
+}
+
+// Enable kích hoạt 2FA bằng mã đầu tiên từ ứng dụng xác thực, trả về mã dự phòng (chỉ hiển thị một lần)
+//
+// @route POST /api/users/2fa/enable
+// @body { "code": "123456" }
+func (ctrl *MFAController) Enable(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "code is required", Data: nil})
+	}
+	userID := middleware.CurrentUserID(c)
+	codes, ok, err := ctrl.enable(c.Context(), userID, body.Code)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Enable 2FA failed", Data: nil})
+	}
+	if !ok {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid code or 2FA setup not started", Data: nil})
+	}
+	audit(c, models.AuditUserMFAEnable, userID, nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Two-factor authentication enabled", Data: fiber.Map{"backupCodes": codes}})
+}
+
+// Disable tắt 2FA của mình, cần mật khẩu và mã TOTP (hoặc mã dự phòng).
+// Vai trò bắt buộc 2FA thì không tắt được.
+//
+// @route POST /api/users/2fa/disable
+// @body { "password": "...", "code": "123456" }
+func (ctrl *MFAController) Disable(c *fiber.Ctx) error {
+	var body struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Password == "" || body.Code == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "password and code are required", Data: nil})
+	}
+	user, err := repositories.FindUserByID(middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	required, mfa, err := ctrl.state(c.Context(), user.ID.Hex(), user.Role)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Disable 2FA failed", Data: nil})
+	}
+	if mfa == nil || !mfa.Enabled {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication is not enabled", Data: nil})
+	}
+	if required {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication is required for your role", Data: nil})
+	}
+	if !utils.CheckPasswordHash(body.Password, user.Password) {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Password is incorrect", Data: nil})
+	}
+	if ok, err := ctrl.verify(c.Context(), mfa, body.Code); err != nil || !ok {
+		return invalidMFACode(c, err)
+	}
+	if err := ctrl.repo.Delete(c.Context(), user.ID.Hex()); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Disable 2FA failed", Data: nil})
+	}
+	audit(c, models.AuditUserMFADisable, user.ID.Hex(), nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Two-factor authentication disabled", Data: nil})
+}
+
+// BackupCodes tạo lại bộ mã dự phòng (mã cũ hết hiệu lực), cần mã TOTP hiện tại
+//
+// @route POST /api/users/2fa/backup-codes
+// @body { "code": "123456" }
+func (ctrl *MFAController) BackupCodes(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "code is required", Data: nil})
+	}
+	userID := middleware.CurrentUserID(c)
+	mfa, err := ctrl.repo.Find(c.Context(), userID)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !mfa.Enabled) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication is not enabled", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Generate backup codes failed", Data: nil})
+	}
+	if ok, err := ctrl.verify(c.Context(), mfa, body.Code); err != nil || !ok {
+		return invalidMFACode(c, err)
+	}
+	codes, hashes, err := utils.NewBackupCodes(backupCodeCount)
+	if err == nil {
+		err = ctrl.repo.ReplaceBackupCodes(c.Context(), userID, hashes)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Generate backup codes failed", Data: nil})
+	}
+	// Không ghi mã dự phòng vào nhật ký
+	audit(c, models.AuditUserMFABackupCodes, userID, nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Backup codes generated", Data: fiber.Map{"backupCodes": codes}})
+}
+
+// Reset tắt 2FA của người dùng khác (mất điện thoại và mã dự phòng) và thu hồi mọi phiên của họ.
+// Vai trò bắt buộc 2FA thì người dùng phải đăng ký lại ở lần đăng nhập tiếp theo. Chỉ tắt được 2FA của người dùng
+// có vai trò không vượt quyền người gọi (admin chỉ admin tắt được).
+//
+// @route DELETE /api/users/:id/2fa
+func (ctrl *MFAController) Reset(c *fiber.Ctx) error {
+	user, err := repositories.FindUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if ok, err := checkManageRole(c, user.Role); !ok {
+		return err
+	}
+	if err := ctrl.repo.Delete(c.Context(), user.ID.Hex()); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Reset 2FA failed", Data: nil})
+	}
+	if err := repositories.RevokeUserSessions(user.ID.Hex(), models.RevokeMFAReset); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke user sessions", Data: nil})
+	}
+	audit(c, models.AuditUserMFAReset, user.ID.Hex(), nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Two-factor authentication reset", Data: nil})
+}
+
+// state trả về vai trò có bắt buộc 2FA không và cấu hình 2FA của người dùng (nil nếu chưa đăng ký)
+func (ctrl *MFAController) state(ctx context.Context, userID, role string) (bool, *models.UserMFA, error) {
+	required, err := ctrl.roles.RequiresMFA(ctx, role)
+	if err != nil {
+		return false, nil, err
+	}
+	mfa, err := ctrl.repo.Find(ctx, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return required, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	return required, mfa, nil
+}
+
+// setup sinh khoá TOTP chờ kích hoạt cho user và trả về khoá + provisioning URI
+func (ctrl *MFAController) setup(c *fiber.Ctx, user *models.User) error {
+	secret, err := utils.NewTOTPSecret()
+	if err == nil {
+		err = ctrl.repo.Setup(c.Context(), user.ID.Hex(), secret)
+	}
+	if errors.Is(err, repositories.ErrMFAAlreadyEnabled) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Two-factor authentication already enabled", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Setup 2FA failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Scan the QR code and confirm with a code", Data: fiber.Map{
+		"secret":     secret,
+		"otpauthUri": utils.TOTPURI(ctrl.issuer, user.Username, secret),
+	}})
+}
+
+// enable kiểm tra mã với khoá đang chờ kích hoạt, bật 2FA và trả về mã dự phòng.
+// ok = false nếu chưa gọi setup, đã bật rồi hoặc mã sai.
+func (ctrl *MFAController) enable(ctx context.Context, userID, code string) ([]string, bool, error) {
+	mfa, err := ctrl.repo.Find(ctx, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if mfa.Enabled {
+		return nil, false, nil
+	}
+	step, ok := utils.VerifyTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+	codes, hashes, err := utils.NewBackupCodes(backupCodeCount)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok, err := ctrl.repo.Enable(ctx, userID, step, hashes); err != nil || !ok {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// verify kiểm tra mã TOTP 6 số hoặc mã dự phòng của người dùng đã bật 2FA. Mỗi mã chỉ dùng được một lần.
+func (ctrl *MFAController) verify(ctx context.Context, mfa *models.UserMFA, code string) (bool, error) {
+	if step, ok := utils.VerifyTOTP(mfa.Secret, code, time.Now()); ok {
+		return ctrl.repo.UseStep(ctx, mfa.UserID, step)
+	}
+	return ctrl.repo.UseBackupCode(ctx, mfa.UserID, utils.HashBackupCode(code))
+}
+
+func invalidMFACode(c *fiber.Ctx, err error) error {
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Verify 2FA code failed", Data: nil})
+	}
+	return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Invalid or already used 2FA code", Data: nil})
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var pinPattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+
+// PINController xử lý đăng nhập nhanh bằng PIN trên máy POS dùng chung đã đăng ký (device token).
+// Phiên PIN không có refresh token, hết hạn sau ttl và chỉ có quyền bán hàng (models.PINPermissions).
+type PINController struct {
+	auth    *AuthController
+	devices *repositories.DeviceRepository
+	ttl     time.Duration
+}
+
+func NewPINController(auth *AuthController, devices *repositories.DeviceRepository, ttl time.Duration) *PINController {
+	return &PINController{auth: auth, devices: devices, ttl: ttl}
+}
+
+// Login đăng nhập bằng username + PIN từ máy POS đã đăng ký.
+// Sai PIN được đếm riêng với sai mật khẩu, theo username và theo máy; sai PIN_MAX_FAILURES lần liên tiếp -> khoá PIN
+// PIN_LOCK_MINUTES phút (đăng nhập bằng mật khẩu vẫn dùng được).
+// Vai trò bắt buộc 2FA không được đăng nhập bằng PIN (403), vì PIN không qua bước 2FA.
+//
+// @route POST /login/pin
+// @header X-Device-Token: <device token>
+// @body {"username": "thungan1", "pin": "2580"}
+func (ctrl *PINController) Login(c *fiber.Ctx) error {
+	var input struct {
+		Username string `json:"username"`
+		PIN      string `json:"pin"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Username == "" || input.PIN == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "username and pin are required", Data: nil})
+	}
+	attempt := models.LoginAttempt{Username: input.Username, IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent), Method: models.AuthPIN}
+
+	device, err := ctrl.device(c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		attempt.Reason = models.LoginInvalidDevice
+		ctrl.auth.log(c, attempt)
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Unknown or revoked device", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	attempt.DeviceID = device.ID.Hex()
+
+	// Giữ lượt thử trước bcrypt để request bị chặn không tốn CPU và request song song không vượt ngưỡng
+	until, locked, err := ctrl.auth.attempts.ReservePIN(c.Context(), input.Username, attempt.DeviceID)
+	if blocked, err := ctrl.auth.reject(c, attempt, until, locked, err); blocked {
+		return err
+	}
+
+	user, err := repositories.FindUserByUsername(input.Username)
+	hash := dummyPasswordHash()
+	if user != nil {
+		attempt.UserID = user.ID.Hex()
+		if user.PIN != "" {
+			hash = user.PIN
+		}
+	}
+	if !utils.CheckPasswordHash(input.PIN, hash) || err != nil || user.PIN == "" {
+		attempt.Reason = models.LoginInvalidPIN
+		ctrl.auth.log(c, attempt)
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Invalid username or PIN", Data: nil})
+	}
+	if err := ctrl.auth.attempts.ReleasePIN(c.Context(), input.Username, attempt.DeviceID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	if rejected, err := ctrl.auth.checkAccount(c, attempt, user); rejected {
+		return err
+	}
+	if allowed, err := ctrl.allowed(c, user); !allowed {
+		return err
+	}
+	if user.MustChangePassword {
+		return c.Status(403).JSON(models.APIResponse{Status: "error", Message: "Password change required, log in with your password", Data: nil})
+	}
+	attempt.Success, attempt.Reason = true, models.LoginSuccess
+	ctrl.auth.log(c, attempt)
+	if err := ctrl.auth.attempts.RecordPINSuccess(c.Context(), input.Username); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	ctrl.auth.touch(user)
+
+	session, err := ctrl.auth.sessions.Create(c.Context(), models.Session{
+		UserID:    user.ID.Hex(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+		Method:    models.AuthPIN,
+		DeviceID:  attempt.DeviceID,
+	}, "", ctrl.ttl)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Login failed", Data: nil})
+	}
+	token, err := ctrl.auth.keys.GenerateJWT(utils.TokenClaims{
+		UserID:    user.ID.Hex(),
+		Role:      user.Role,
+		SessionID: session.ID.Hex(),
+		Method:    models.AuthPIN,
+	}, ctrl.ttl)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Token generation failed", Data: nil})
+	}
+	rehash(user, "pin", input.PIN, user.PIN)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Login successful", Data: fiber.Map{
+		"id":        user.ID,
+		"role":      user.Role,
+		"token":     token,
+		"expiresIn": int(ctrl.ttl.Seconds()),
+		"sessionId": session.ID.Hex(),
+		"method":    models.AuthPIN,
+		"device":    device.Name,
+	}})
+}
+
+// Users liệt kê người dùng đã đặt PIN để máy POS hiển thị màn hình chọn thu ngân
+//
+// @route GET /login/pin/users
+// @header X-Device-Token: <device token>
+func (ctrl *PINController) Users(c *fiber.Ctx) error {
+	if _, err := ctrl.device(c); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Unknown or revoked device", Data: nil})
+		}
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get users failed", Data: nil})
+	}
+	users, err := repositories.GetUsersWithPIN()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get users failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Users", Data: users})
+}
+
+// SetPIN đặt hoặc đổi PIN của mình (4-6 số), cần mật khẩu hiện tại. Phiên PIN cũ bị thu hồi, PIN bị khoá được mở.
+// Vai trò bắt buộc 2FA không được đặt PIN (403).
+//
+// @route PUT /api/users/pin
+// @body {"password": "...", "pin": "2580"}
+func (ctrl *PINController) SetPIN(c *fiber.Ctx) error {
+	var body struct {
+		Password string `json:"password"`
+		PIN      string `json:"pin"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Password == "" {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "password and pin are required", Data: nil})
+	}
+	if !validPIN(body.PIN) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "PIN must be 4-6 digits and not a repeated or sequential number", Data: nil})
+	}
+	user, err := repositories.FindUserByID(middleware.CurrentUserID(c))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if !utils.CheckPasswordHash(body.Password, user.Password) {
+		return c.Status(401).JSON(models.APIResponse{Status: "error", Message: "Password is incorrect", Data: nil})
+	}
+	if allowed, err := ctrl.allowed(c, user); !allowed {
+		return err
+	}
+	hashed, err := utils.HashPassword(body.PIN)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to encrypt PIN", Data: nil})
+	}
+	if err := repositories.UpdateUserPIN(user.ID.Hex(), hashed); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to update PIN", Data: nil})
+	}
+	if err := ctrl.auth.attempts.RecordPINSuccess(c.Context(), user.Username); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to reset PIN lock", Data: nil})
+	}
+	if err := ctrl.auth.sessions.RevokeUserMethod(c.Context(), user.ID.Hex(), models.AuthPIN, models.RevokePINChanged); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke PIN sessions", Data: nil})
+	}
+	// Không ghi PIN hay hash vào nhật ký
+	audit(c, models.AuditUserPINSet, user.ID.Hex(), nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "PIN updated", Data: nil})
+}
+
+// RemovePIN xoá PIN của mình và thu hồi các phiên PIN
+//
+// @route DELETE /api/users/pin
+func (ctrl *PINController) RemovePIN(c *fiber.Ctx) error {
+	userID := middleware.CurrentUserID(c)
+	if err := repositories.UpdateUserPIN(userID, ""); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to remove PIN", Data: nil})
+	}
+	if err := ctrl.auth.sessions.RevokeUserMethod(c.Context(), userID, models.AuthPIN, models.RevokePINChanged); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke PIN sessions", Data: nil})
+	}
+	audit(c, models.AuditUserPINRemove, userID, nil, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "PIN removed", Data: nil})
+}
+
+// allowed trả về false (kèm response 403 đã ghi) nếu vai trò của user bắt buộc 2FA: đăng nhập bằng PIN không có
+// bước 2FA nên không được dùng để vượt qua yêu cầu này
+func (ctrl *PINController) allowed(c *fiber.Ctx, user *models.User) (bool, error) {
+	required, err := ctrl.auth.mfa.roles.RequiresMFA(c.Context(), user.Role)
+	if err != nil {
+		return false, c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Unable to check role", Data: nil})
+	}
+	if required {
+		return false, c.Status(403).JSON(models.APIResponse{Status: "error", Message: "PIN login is not allowed for roles that require two-factor authentication", Data: nil})
+	}
+	return true, nil
+}
+
+// device xác thực máy POS qua header X-Device-Token; thiếu hoặc không hợp lệ -> mongo.ErrNoDocuments
+func (ctrl *PINController) device(c *fiber.Ctx) (*models.Device, error) {
+	token := strings.TrimSpace(c.Get(models.HeaderDeviceToken))
+	if token == "" {
+		return nil, mongo.ErrNoDocuments
+	}
+	return ctrl.devices.Authenticate(c.Context(), utils.HashToken(token), c.IP())
+}
+
+// pinPermissions lọc quyền của vai trò theo models.PINPermissions (quyền thực tế của phiên PIN)
+func pinPermissions(permissions []string) []string {
+	allowed := []string{}
+	for _, p := range permissions {
+		for _, pin := range models.PINPermissions {
+			if p == pin {
+				allowed = append(allowed, p)
+			}
+		}
+	}
+	return allowed
+}
+
+// validPIN kiểm tra PIN 4-6 số, không phải dãy lặp (1111) hoặc dãy liên tiếp (1234, 9876)
+func validPIN(pin string) bool {
+	if !pinPattern.MatchString(pin) {
+		return false
+	}
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		repeated = repeated && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+	return !repeated && !ascending && !descending
+}
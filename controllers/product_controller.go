@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
 	"go-fiber-api/models"
 	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/mongo"
 	"strings"
 )
 
@@ -25,16 +28,19 @@ func (ctrl *ProductController) Create(c *fiber.Ctx) error {
 	if err := c.BodyParser(&product); err != nil {
 		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
 	}
-	err := ctrl.repo.Create(c.Context(), product)
+	product.UpdatedBy = middleware.CurrentUserID(c)
+	created, err := ctrl.repo.Create(c.Context(), product)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create failed", Data: nil})
 	}
+	audit(c, models.AuditProductCreate, created.ID.Hex(), nil, created)
 	return c.JSON(models.APIResponse{Status: "success", Message: "Created successfully", Data: nil})
 }
 
 // Update cập nhật thông tin sản phẩm (lấy ID từ body)
 // Method: PUT /api/products
-// Body JSON: { "id": "abc123", "name": "Tên mới", "price": 15000 }
+// Body JSON: { "id": "abc123", "name": "Tên mới", "price": 15000, "version": 3 }
+// Có thể gửi version qua header If-Match thay cho body. Version cũ -> 409 kèm sản phẩm hiện tại.
 func (ctrl *ProductController) Update(c *fiber.Ctx) error {
 	var product models.Product
 	if err := c.BodyParser(&product); err != nil {
@@ -44,23 +50,48 @@ func (ctrl *ProductController) Update(c *fiber.Ctx) error {
 	if product.ID.IsZero() {
 		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Missing product ID", Data: nil})
 	}
+	version, ok := requestVersion(c)
+	if !ok {
+		return missingVersion(c)
+	}
+	product.Version = version
+	product.UpdatedBy = middleware.CurrentUserID(c)
 
 	id := product.ID.Hex()
-	err := ctrl.repo.Update(c.Context(), id, product)
+	before, _ := ctrl.repo.FindByID(c.Context(), id)
+	updated, err := ctrl.repo.Update(c.Context(), id, product)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		current, _ := ctrl.repo.FindByID(c.Context(), id)
+		return versionConflict(c, current)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Product not found", Data: nil})
+	}
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update failed", Data: nil})
 	}
-	return c.JSON(models.APIResponse{Status: "success", Message: "Updated successfully", Data: nil})
+	audit(c, models.AuditProductUpdate, id, before, updated)
+	setETag(c, updated.Version)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Updated successfully", Data: updated})
 }
 
 // Delete xoá một hoặc nhiều sản phẩm
 // Method: DELETE /api/products?id=abc123,def456
 func (ctrl *ProductController) Delete(c *fiber.Ctx) error {
 	ids := strings.Split(c.Query("id"), ",")
+	deleted := map[string]*models.Product{}
+	for _, id := range ids {
+		if product, err := ctrl.repo.FindByID(c.Context(), id); err == nil {
+			deleted[id] = product
+		}
+	}
 	err := ctrl.repo.DeleteMany(c.Context(), ids)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Delete failed", Data: nil})
 	}
+	for id, product := range deleted {
+		audit(c, models.AuditProductDelete, id, product, nil)
+	}
 	return c.JSON(models.APIResponse{Status: "success", Message: "Deleted successfully", Data: nil})
 }
 
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultQuotationValidity là thời hạn hiệu lực khi tạo báo giá mà không gửi validUntil
+const defaultQuotationValidity = 15 * 24 * time.Hour
+
+//go:embed templates/quotation.html
+var quotationTemplateHTML string
+
+var quotationTemplate = template.Must(template.New("quotation").Funcs(template.FuncMap{
+	"vnd":       utils.FormatVND,
+	"inc":       func(i int) int { return i + 1 },
+	"lineTotal": func(item models.InvoiceItem) float64 { return float64(item.Quantity) * item.Price },
+	"date": func(t time.Time) string {
+		return t.In(time.FixedZone("GMT+7", 7*3600)).Format("02/01/2006")
+	},
+}).Parse(quotationTemplateHTML))
+
+// QuotationController xử lý báo giá cho khách hàng doanh nghiệp
+type QuotationController struct {
+	repo     *repositories.QuotationRepository
+	settings *repositories.StoreSettingRepository
+	invoices *InvoiceController
+}
+
+func NewQuotationController(repo *repositories.QuotationRepository, settings *repositories.StoreSettingRepository, invoices *InvoiceController) *QuotationController {
+	return &QuotationController{repo: repo, settings: settings, invoices: invoices}
+}
+
+// Create tạo báo giá mới (trạng thái "sent")
+//
+// @route POST /api/quotations
+//
+//	@body {
+//	  "customerName": "Công ty ABC",
+//	  "customerTaxCode": "0312345678",
+//	  "customerAddress": "12 Lê Lợi, Q1",
+//	  "items": [{ "productId": "xxx", "name": "Áo đồng phục", "quantity": 50, "price": 120000 }],
+//	  "validUntil": "2025-06-30T23:59:59+07:00",
+//	  "note": "Giao trong 7 ngày"
+//	}
+func (ctrl *QuotationController) Create(c *fiber.Ctx) error {
+	var quotation models.Quotation
+	if err := c.BodyParser(&quotation); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if quotation.CustomerName == "" || len(quotation.Items) == 0 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Missing customer name or items", Data: nil})
+	}
+	if quotation.ValidUntil.IsZero() {
+		quotation.ValidUntil = time.Now().Add(defaultQuotationValidity)
+	}
+	if !quotation.ValidUntil.After(time.Now()) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "validUntil must be in the future", Data: nil})
+	}
+	quotation.CreatedBy = middleware.CurrentUserID(c)
+
+	created, err := ctrl.repo.Create(c.Context(), quotation)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create failed", Data: nil})
+	}
+	audit(c, models.AuditQuotationCreate, created.ID.Hex(), nil, created)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Quotation created", Data: created})
+}
+
+// List danh sách báo giá có phân trang, lọc theo trạng thái
+//
+// @route GET /api/quotations?status=sent&page=1&limit=10
+func (ctrl *QuotationController) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+	if c.Query("limit") == "" || limit == 0 {
+		limit = 0
+	}
+	quotations, total, err := ctrl.repo.ListPaginated(c.Context(), c.Query("status"), int64(page), int64(limit))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "List fetched", Data: fiber.Map{
+		"quotations": quotations,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+	}})
+}
+
+// Get xem chi tiết báo giá
+//
+// @route GET /api/quotations/:id
+func (ctrl *QuotationController) Get(c *fiber.Ctx) error {
+	quotation, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Quotation not found", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Quotation", Data: quotation})
+}
+
+// UpdateStatus ghi nhận phản hồi của khách cho báo giá đang chờ
+//
+// @route PUT /api/quotations/:id/status
+// @body  { "status": "accepted" }   // accepted | rejected
+func (ctrl *QuotationController) UpdateStatus(c *fiber.Ctx) error {
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&body); err != nil || (body.Status != models.QuotationAccepted && body.Status != models.QuotationRejected) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Status must be accepted or rejected", Data: nil})
+	}
+	quotation, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Quotation not found", Data: nil})
+	}
+	if err := ctrl.repo.SetStatus(c.Context(), quotation.ID, body.Status); err != nil {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Only pending quotations can be updated", Data: nil})
+	}
+	audit(c, models.AuditQuotationStatus, quotation.ID.Hex(), fiber.Map{"status": quotation.Status}, fiber.Map{"status": body.Status})
+	return c.JSON(models.APIResponse{Status: "success", Message: "Quotation status updated", Data: nil})
+}
+
+// Convert chuyển báo giá thành hóa đơn: sao chép sản phẩm, ghi liên kết hai chiều giữa báo giá và hóa đơn.
+// paymentMethod (mặc định cash), discount (mặc định 0) trong body là thanh toán thực tế của hóa đơn.
+//
+// @route POST /api/quotations/:id/convert
+// @body { "paymentMethod": "transfer", "discount": 50000 }
+func (ctrl *QuotationController) Convert(c *fiber.Ctx) error {
+	payment, err := parsePayment(c)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	current, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Quotation not found", Data: nil})
+	}
+
+	// Cấp trước ID hóa đơn rồi giữ báo giá, tránh hai người chuyển cùng lúc tạo hai hóa đơn
+	invoiceID := primitive.NewObjectID()
+	quotation, err := ctrl.repo.ClaimForInvoice(c.Context(), current.ID, invoiceID)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(409).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Quotation is expired, rejected or already converted",
+			Data:    current,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Convert failed", Data: nil})
+	}
+
+	draft := models.Invoice{
+		ID:            invoiceID,
+		Items:         quotation.Items,
+		Note:          quotation.Note,
+		QuotationID:   &quotation.ID,
+		QuotationCode: quotation.Code,
+	}
+	payment.apply(&draft)
+	invoice, err := ctrl.invoices.createInvoice(c, draft)
+	if err != nil {
+		_ = ctrl.repo.ReleaseInvoice(c.Context(), quotation.ID, invoiceID)
+		return createFailed(c, err)
+	}
+	if err := ctrl.repo.CompleteInvoice(c.Context(), quotation.ID, invoice.Code); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Invoice created but failed to link quotation", Data: invoice})
+	}
+	audit(c, models.AuditQuotationConvert, quotation.ID.Hex(), fiber.Map{"status": current.Status}, fiber.Map{"invoiceId": invoice.ID, "invoiceCode": invoice.Code})
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Invoice created", Data: invoice})
+}
+
+// Render xuất báo giá dạng HTML có logo, tên, địa chỉ cửa hàng; dùng chức năng in của trình duyệt để lưu PDF
+//
+// @route GET /api/quotations/:id/html
+func (ctrl *QuotationController) Render(c *fiber.Ctx) error {
+	quotation, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Quotation not found", Data: nil})
+	}
+	store, err := ctrl.settings.Get(c.Context())
+	if err != nil && err != mongo.ErrNoDocuments {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get store info failed", Data: nil})
+	}
+
+	var buf bytes.Buffer
+	err = quotationTemplate.Execute(&buf, fiber.Map{
+		"Quotation": quotation,
+		"Store":     store,
+		"Total":     models.InvoiceTotal(quotation.Items),
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Render failed", Data: nil})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+)
+
+// ReportController cung cấp các báo cáo bán hàng cho quản lý
+type ReportController struct {
+	repo *repositories.ReportRepository
+}
+
+func NewReportController(repo *repositories.ReportRepository) *ReportController {
+	return &ReportController{repo: repo}
+}
+
+// Dashboard trả về toàn bộ số liệu cho màn hình tổng quan trong một lần gọi:
+//   - today, week, month: doanh thu, số hóa đơn, giá trị TB/hóa đơn, số SP/hóa đơn,
+//     so với cùng thời điểm của kỳ trước (hôm qua, tuần trước, tháng trước)
+//   - topByRevenue, topByQuantity: 10 sản phẩm bán chạy nhất tháng này
+//   - heatmap: doanh thu theo thứ x giờ trong `weeks` tuần gần nhất (mặc định 4)
+//
+// Mốc ngày tính theo GMT+7, tuần bắt đầu từ Thứ Hai. Không tính hóa đơn đã huỷ.
+//
+// @route GET /api/reports/dashboard?weeks=4
+func (ctrl *ReportController) Dashboard(c *fiber.Ctx) error {
+	weeks := c.QueryInt("weeks", 4)
+	if weeks < 1 || weeks > 52 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "weeks must be between 1 and 52", Data: nil})
+	}
+
+	loc := time.FixedZone("GMT+7", 7*3600)
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startOfWeek := startOfDay.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7)) // Thứ Hai
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+
+	today, err := ctrl.compare(c, startOfDay, startOfDay.AddDate(0, 0, -1), now)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+	week, err := ctrl.compare(c, startOfWeek, startOfWeek.AddDate(0, 0, -7), now)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+	month, err := ctrl.compare(c, startOfMonth, startOfMonth.AddDate(0, -1, 0), now)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+
+	topByRevenue, err := ctrl.repo.TopProducts(c.Context(), startOfMonth, now, "revenue", 10)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+	topByQuantity, err := ctrl.repo.TopProducts(c.Context(), startOfMonth, now, "quantity", 10)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+	heatmap, err := ctrl.repo.Heatmap(c.Context(), startOfDay.AddDate(0, 0, 1-7*weeks), now)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+
+	return c.JSON(models.APIResponse{Status: "success", Message: "Dashboard", Data: fiber.Map{
+		"today":         today,
+		"week":          week,
+		"month":         month,
+		"topByRevenue":  topByRevenue,
+		"topByQuantity": topByQuantity,
+		"heatmap":       heatmap,
+	}})
+}
+
+// Staff trả về doanh số theo nhân viên tạo hóa đơn: số hóa đơn, doanh thu, giảm giá đã cho, hóa đơn huỷ và trả hàng.
+// from, to theo định dạng dd/mm/yyyy (GMT+7, bao gồm cả ngày to); mặc định là hôm nay.
+//
+// @route GET /api/reports/staff?from=01/06/2025&to=30/06/2025
+func (ctrl *ReportController) Staff(c *fiber.Ctx) error {
+	loc := time.FixedZone("GMT+7", 7*3600)
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	to := from
+	if fromStr, toStr := c.Query("from"), c.Query("to"); fromStr != "" || toStr != "" {
+		var err1, err2 error
+		from, err1 = time.ParseInLocation("02/01/2006", fromStr, loc)
+		to, err2 = time.ParseInLocation("02/01/2006", toStr, loc)
+		if err1 != nil || err2 != nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid date format (dd/mm/yyyy)", Data: nil})
+		}
+		if to.Before(from) {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "to must not be before from", Data: nil})
+		}
+	}
+	to = to.AddDate(0, 0, 1) // Hết ngày to
+
+	staff, err := ctrl.repo.StaffSales(c.Context(), from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Report failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Staff sales", Data: fiber.Map{
+		"from":  from,
+		"to":    to,
+		"staff": staff,
+	}})
+}
+
+// compare tính chỉ số kỳ hiện tại [start, now) và kỳ trước tính đến cùng thời điểm [prevStart, prevStart + (now - start))
+func (ctrl *ReportController) compare(c *fiber.Ctx, start, prevStart, now time.Time) (*models.PeriodComparison, error) {
+	prevEnd := prevStart.Add(now.Sub(start))
+	if prevEnd.After(start) {
+		prevEnd = start // Tháng trước ngắn hơn tháng này
+	}
+
+	current, err := ctrl.repo.Summary(c.Context(), start, now)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := ctrl.repo.Summary(c.Context(), prevStart, prevEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	change := func(cur, prev float64) *float64 {
+		if prev == 0 {
+			return nil
+		}
+		pct := (cur - prev) / prev * 100
+		return &pct
+	}
+	return &models.PeriodComparison{
+		From:         start,
+		To:           now,
+		PreviousFrom: prevStart,
+		PreviousTo:   prevEnd,
+		Current:      current,
+		Previous:     previous,
+		Change: map[string]*float64{
+			"revenue":         change(current.Revenue, previous.Revenue),
+			"invoiceCount":    change(float64(current.InvoiceCount), float64(previous.InvoiceCount)),
+			"averageBasket":   change(current.AverageBasket, previous.AverageBasket),
+			"itemsPerInvoice": change(current.ItemsPerInvoice, previous.ItemsPerInvoice),
+		},
+	}, nil
+}
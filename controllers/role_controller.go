@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// RoleController quản lý vai trò và quyền của từng vai trò
+type RoleController struct {
+	repo *repositories.RoleRepository
+}
+
+func NewRoleController(repo *repositories.RoleRepository) *RoleController {
+	return &RoleController{repo: repo}
+}
+
+type roleInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	RequireMFA  bool     `json:"requireMfa"`
+}
+
+// normalize kiểm tra danh sách quyền và bỏ quyền trùng
+func (in *roleInput) normalize() error {
+	in.Description = strings.TrimSpace(in.Description)
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, p := range in.Permissions {
+		if !models.IsPermission(p) {
+			return errors.New("unknown permission " + p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	in.Permissions = permissions
+	return nil
+}
+
+// checkGrantPermissions chỉ cho gán những quyền người gọi đang có (admin có mọi quyền), tránh tự nâng quyền
+// qua vai trò; thiếu quyền -> đã ghi 403 và trả về false
+func checkGrantPermissions(c *fiber.Ctx, permissions []string) (bool, error) {
+	granted, err := callerPermissions(c)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Error checking role", Data: nil})
+	}
+	for _, p := range permissions {
+		if !granted[p] {
+			return false, c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Forbidden: you do not have permission " + p, Data: nil})
+		}
+	}
+	return true, nil
+}
+
+// Permissions liệt kê toàn bộ quyền có thể gán cho vai trò
+//
+// @route GET /api/roles/permissions
+func (ctrl *RoleController) Permissions(c *fiber.Ctx) error {
+	return c.JSON(models.APIResponse{Status: "success", Message: "Permissions", Data: models.Permissions})
+}
+
+// List liệt kê vai trò và quyền
+//
+// @route GET /api/roles
+func (ctrl *RoleController) List(c *fiber.Ctx) error {
+	roles, err := ctrl.repo.List(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get roles failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Roles", Data: roles})
+}
+
+// Create tạo vai trò mới
+//
+// @route POST /api/roles
+//
+//	@body {
+//	  "name": "accountant",
+//	  "description": "Kế toán",
+//	  "permissions": ["invoice.read", "invoice.export", "report.read"],
+//	  "requireMfa": false
+//	}
+func (ctrl *RoleController) Create(c *fiber.Ctx) error {
+	var in roleInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if !roleNamePattern.MatchString(in.Name) {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "name must be 2-32 lowercase letters, digits, '-' or '_'", Data: nil})
+	}
+	if err := in.normalize(); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: err.Error(), Data: nil})
+	}
+	if ok, err := checkGrantPermissions(c, in.Permissions); !ok {
+		return err
+	}
+
+	created, err := ctrl.repo.Create(c.Context(), models.Role{
+		Name:        in.Name,
+		Description: in.Description,
+		Permissions: in.Permissions,
+		RequireMFA:  in.RequireMFA,
+		UpdatedBy:   middleware.CurrentUserID(c),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Role already exists", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Create role failed", Data: nil})
+	}
+	audit(c, models.AuditRoleCreate, created.Name, nil, created)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Role created", Data: created})
+}
+
+// Update sửa mô tả và danh sách quyền của vai trò. Quyền của admin luôn là toàn bộ quyền, không sửa được.
+// Chỉ sửa được vai trò mà người gọi có đủ quyền của nó và chỉ gán được quyền người gọi đang có.
+// Người dùng đang đăng nhập nhận quyền mới ngay ở request tiếp theo.
+//
+// @route PUT /api/roles/:name
+//
+//	@body { "description": "Kế toán", "permissions": ["invoice.read", "report.read"] }
+func (ctrl *RoleController) Update(c *fiber.Ctx) error {
+	var in roleInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	in.Name = c.Params("name")
+	if in.Name == models.RoleAdmin {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Permissions of the admin role cannot be changed", Data: nil})
+	}
+	if err := in.normalize(); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: err.Error(), Data: nil})
+	}
+	if ok, err := checkManageRole(c, in.Name); !ok {
+		return err
+	}
+	if ok, err := checkGrantPermissions(c, in.Permissions); !ok {
+		return err
+	}
+
+	before, _ := ctrl.repo.FindByName(c.Context(), in.Name)
+	saved, err := ctrl.repo.Update(c.Context(), models.Role{
+		Name:        in.Name,
+		Description: in.Description,
+		Permissions: in.Permissions,
+		UpdatedBy:   middleware.CurrentUserID(c),
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Role not found", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update role failed", Data: nil})
+	}
+	audit(c, models.AuditRoleUpdate, saved.Name, before, saved)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Role updated", Data: saved})
+}
+
+// SetMFA bật / tắt bắt buộc xác thực hai lớp cho vai trò (kể cả admin). Người dùng của vai trò chưa đăng ký 2FA
+// phải đăng ký ở lần đăng nhập tiếp theo; phiên đang mở không refresh được nữa cho đến khi đăng ký.
+// Chỉ đổi được vai trò mà người gọi có đủ quyền của nó (vai trò admin chỉ admin đổi được).
+//
+// @route PUT /api/roles/:name/mfa
+// @body { "required": true }
+func (ctrl *RoleController) SetMFA(c *fiber.Ctx) error {
+	var body struct {
+		Required bool `json:"required"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if ok, err := checkManageRole(c, c.Params("name")); !ok {
+		return err
+	}
+	before, _ := ctrl.repo.FindByName(c.Context(), c.Params("name"))
+	saved, err := ctrl.repo.SetRequireMFA(c.Context(), c.Params("name"), body.Required, middleware.CurrentUserID(c))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Role not found", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update role failed", Data: nil})
+	}
+	audit(c, models.AuditRoleMFA, saved.Name, before, saved)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Role updated", Data: saved})
+}
+
+// Delete xoá vai trò tự tạo chưa gán cho người dùng nào. Vai trò mặc định không xoá được.
+//
+// @route DELETE /api/roles/:name
+func (ctrl *RoleController) Delete(c *fiber.Ctx) error {
+	name := c.Params("name")
+	role, err := ctrl.repo.FindByName(c.Context(), name)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Role not found", Data: nil})
+	}
+	if role.System {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Default roles cannot be deleted", Data: nil})
+	}
+	if ok, err := checkManageRole(c, name); !ok {
+		return err
+	}
+	count, err := repositories.CountUsersByRole(name)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Delete role failed", Data: nil})
+	}
+	if count > 0 {
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Role is assigned to users", Data: fiber.Map{"users": count}})
+	}
+	if err := ctrl.repo.Delete(c.Context(), name); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Delete role failed", Data: nil})
+	}
+	audit(c, models.AuditRoleDelete, name, role, nil)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Role deleted", Data: nil})
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/middleware"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ShiftController xử lý mở / đóng ca thu ngân và báo cáo Z cuối ca
+type ShiftController struct {
+	repo    *repositories.ShiftRepository
+	reports *repositories.ReportRepository
+}
+
+func NewShiftController(repo *repositories.ShiftRepository, reports *repositories.ReportRepository) *ShiftController {
+	return &ShiftController{repo: repo, reports: reports}
+}
+
+// Open mở ca mới cho người dùng hiện tại với tiền mặt đầu ca.
+// Mỗi thu ngân chỉ có một ca mở tại một thời điểm; hóa đơn tạo trong lúc ca mở được gán vào ca.
+//
+// @route POST /api/shifts/open
+//
+//	@body { "openingFloat": 500000 }
+func (ctrl *ShiftController) Open(c *fiber.Ctx) error {
+	var body struct {
+		OpeningFloat float64 `json:"openingFloat"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if body.OpeningFloat < 0 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "openingFloat must not be negative", Data: nil})
+	}
+
+	shift := models.Shift{CashierID: middleware.CurrentUserID(c), OpeningFloat: body.OpeningFloat}
+	if user, err := repositories.FindUserByID(shift.CashierID); err == nil {
+		shift.CashierName = user.Username
+	}
+	created, err := ctrl.repo.Open(c.Context(), shift)
+	if mongo.IsDuplicateKeyError(err) {
+		current, _ := ctrl.repo.FindOpenByCashier(c.Context(), shift.CashierID)
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "A shift is already open", Data: current})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Open shift failed", Data: nil})
+	}
+	audit(c, models.AuditShiftOpen, created.ID.Hex(), nil, created)
+	return c.Status(201).JSON(models.APIResponse{Status: "success", Message: "Shift opened", Data: created})
+}
+
+// Current trả về ca đang mở của người dùng hiện tại kèm báo cáo Z tạm tính đến thời điểm gọi
+//
+// @route GET /api/shifts/current
+func (ctrl *ShiftController) Current(c *fiber.Ctx) error {
+	shift, err := ctrl.repo.FindOpenByCashier(c.Context(), middleware.CurrentUserID(c))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "No open shift", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get shift failed", Data: nil})
+	}
+	report, err := ctrl.reports.BuildZReport(c.Context(), *shift)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Build report failed", Data: nil})
+	}
+	shift.Report = report
+	return c.JSON(models.APIResponse{Status: "success", Message: "Current shift", Data: shift})
+}
+
+// Close đóng ca đang mở của người dùng hiện tại với số tiền mặt đếm được và chốt báo cáo Z.
+// Báo cáo đã chốt được lưu cùng ca và không thay đổi nữa.
+//
+// @route POST /api/shifts/close
+//
+//	@body { "countedCash": 1850000, "note": "Thiếu 10.000đ tiền lẻ" }
+func (ctrl *ShiftController) Close(c *fiber.Ctx) error {
+	var body struct {
+		CountedCash *float64 `json:"countedCash"`
+		Note        string   `json:"note"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if body.CountedCash == nil || *body.CountedCash < 0 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "countedCash is required", Data: nil})
+	}
+
+	userID := middleware.CurrentUserID(c)
+	shift, err := ctrl.repo.FindOpenByCashier(c.Context(), userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "No open shift", Data: nil})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Close shift failed", Data: nil})
+	}
+
+	// Đóng ca trước rồi mới lập báo cáo Z: hóa đơn tạo sau thời điểm đóng không còn được gán vào ca nên không bị sót
+	closed, err := ctrl.repo.Close(c.Context(), shift.ID, userID, *body.CountedCash, body.Note)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Ca vừa được đóng bởi request khác
+		current, _ := ctrl.repo.FindByID(c.Context(), shift.ID.Hex())
+		return c.Status(409).JSON(models.APIResponse{Status: "error", Message: "Shift already closed", Data: current})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Close shift failed", Data: nil})
+	}
+	audit(c, models.AuditShiftClose, closed.ID.Hex(), shift, closed)
+	closed, err = ctrl.finalize(c, closed)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Shift closed but build report failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Shift closed", Data: closed})
+}
+
+// finalize lập và lưu báo cáo Z cho ca đã đóng mà chưa có báo cáo (kể cả ca đóng dở do lỗi giữa chừng)
+func (ctrl *ShiftController) finalize(c *fiber.Ctx, shift *models.Shift) (*models.Shift, error) {
+	if shift.Report != nil || shift.CountedCash == nil {
+		return shift, nil
+	}
+	report, err := ctrl.reports.BuildZReport(c.Context(), *shift)
+	if err != nil {
+		return nil, err
+	}
+	counted := *shift.CountedCash
+	difference := counted - report.ExpectedCash
+	report.CountedCash, report.Difference = &counted, &difference
+	return ctrl.repo.SetReport(c.Context(), shift.ID, *report)
+}
+
+// List trả về danh sách ca (mới nhất trước), có thể lọc theo thu ngân
+//
+// @route GET /api/shifts?cashierId=66a1...&page=1&limit=10
+func (ctrl *ShiftController) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+	if page < 1 {
+		page = 1
+	}
+	shifts, total, err := ctrl.repo.List(c.Context(), c.Query("cashierId"), int64(page), int64(limit))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "List failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Shifts", Data: fiber.Map{
+		"shifts": shifts,
+		"page":   page,
+		"limit":  limit,
+		"total":  total,
+	}})
+}
+
+// Get trả về chi tiết ca. Ca đã đóng trả về báo cáo Z đã chốt; ca đang mở trả về báo cáo tạm tính.
+//
+// @route GET /api/shifts/:id
+func (ctrl *ShiftController) Get(c *fiber.Ctx) error {
+	shift, err := ctrl.repo.FindByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Shift not found", Data: nil})
+	}
+	if shift.Status == models.ShiftOpen {
+		report, err := ctrl.reports.BuildZReport(c.Context(), *shift)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Build report failed", Data: nil})
+		}
+		shift.Report = report
+	} else if shift, err = ctrl.finalize(c, shift); err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Build report failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Shift detail", Data: shift})
+}
@@ -1,17 +1,21 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/einvoice"
+	"go-fiber-api/middleware"
 	"go-fiber-api/models"
 	"go-fiber-api/repositories"
 )
 
 type StoreSettingController struct {
-	repo *repositories.StoreSettingRepository
+	repo      *repositories.StoreSettingRepository
+	numbering *repositories.NumberingRepository
 }
 
-func NewStoreSettingController(repo *repositories.StoreSettingRepository) *StoreSettingController {
-	return &StoreSettingController{repo: repo}
+func NewStoreSettingController(repo *repositories.StoreSettingRepository, numbering *repositories.NumberingRepository) *StoreSettingController {
+	return &StoreSettingController{repo: repo, numbering: numbering}
 }
 
 // GET /api/settings
@@ -23,14 +27,18 @@ func NewStoreSettingController(repo *repositories.StoreSettingRepository) *Store
 //	  "data": {
 //	    "storeName": "Cửa hàng A",
 //	    "phone": "0912345678",
-//	    "logoUrl": "https://..."
+//	    "logoUrl": "https://...",
+//	    "version": 3
 //	  }
 //	}
+//
+// Header ETag chứa version hiện tại, dùng cho If-Match khi cập nhật.
 func (ctrl *StoreSettingController) Get(c *fiber.Ctx) error {
 	setting, err := ctrl.repo.Get(c.Context())
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get failed", Data: nil})
 	}
+	setETag(c, setting.Version)
 	return c.JSON(models.APIResponse{Status: "success", Message: "Fetched store info", Data: setting})
 }
 
@@ -40,15 +48,124 @@ func (ctrl *StoreSettingController) Get(c *fiber.Ctx) error {
 //	{
 //	  "storeName": "Cửa hàng mới",
 //	  "phone": "0909123456",
-//	  "logoUrl": "https://cdn.com/logo.png"
+//	  "logoUrl": "https://cdn.com/logo.png",
+//	  "taxCode": "0101234567",
+//	  "einvoiceTemplate": "1",
+//	  "einvoiceSeries": "C25TAA",
+//	  "defaultVatRate": "10%",
+//	  "version": 3
 //	}
+//
+// Có thể gửi version qua header If-Match thay cho body. Version cũ -> 409 kèm thông tin hiện tại.
 func (ctrl *StoreSettingController) Upsert(c *fiber.Ctx) error {
 	var setting models.StoreSetting
 	if err := c.BodyParser(&setting); err != nil {
 		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
 	}
-	if err := ctrl.repo.Upsert(c.Context(), setting); err != nil {
+	if _, ok := einvoice.TaxRates[setting.DefaultVATRate]; setting.DefaultVATRate != "" && !ok {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid defaultVatRate", Data: nil})
+	}
+	version, ok := requestVersion(c)
+	if !ok {
+		return missingVersion(c)
+	}
+	setting.Version = version
+	setting.UpdatedBy = middleware.CurrentUserID(c)
+
+	before, _ := ctrl.repo.Get(c.Context())
+	saved, err := ctrl.repo.Upsert(c.Context(), setting)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		current, _ := ctrl.repo.Get(c.Context())
+		return versionConflict(c, current)
+	}
+	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update failed", Data: nil})
 	}
-	return c.JSON(models.APIResponse{Status: "success", Message: "Store info saved", Data: nil})
+	audit(c, models.AuditSettingsUpdate, saved.ID.Hex(), before, saved)
+	setETag(c, saved.Version)
+	return c.JSON(models.APIResponse{Status: "success", Message: "Store info saved", Data: saved})
+}
+
+// GET /api/settings/numbering
+// Trả về cấu hình đánh số của từng loại chứng từ (cấu hình mặc định nếu chưa chỉnh).
+// Header ETag chứa version của thông tin cửa hàng, dùng cho If-Match khi đổi cấu hình.
+func (ctrl *StoreSettingController) ListNumbering(c *fiber.Ctx) error {
+	schemes, err := ctrl.numbering.List(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Get failed", Data: nil})
+	}
+	if setting, err := ctrl.repo.Get(c.Context()); err == nil {
+		setETag(c, setting.Version)
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Fetched numbering schemes", Data: schemes})
+}
+
+// PUT /api/settings/numbering/:docType
+// Body:
+//
+//	{
+//	  "prefix": "HD",
+//	  "dateFormat": "YYMM",
+//	  "seqWidth": 5,
+//	  "resetPeriod": "monthly",
+//	  "version": 3
+//	}
+//
+// resetPeriod: daily | monthly | yearly | never. Trả về cấu hình đã lưu kèm vài mã tiếp theo để xem trước.
+// version là version của thông tin cửa hàng (GET /api/settings), có thể gửi qua header If-Match. Version cũ -> 409.
+// Đổi tiền tố hoặc định dạng ngày thì số thứ tự bắt đầu lại từ 1 trong kỳ hiện tại.
+func (ctrl *StoreSettingController) SaveNumbering(c *fiber.Ctx) error {
+	var scheme models.NumberingScheme
+	if err := c.BodyParser(&scheme); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	scheme.DocType = c.Params("docType")
+	if err := repositories.ValidateNumberingScheme(scheme); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: err.Error(), Data: nil})
+	}
+	version, ok := requestVersion(c)
+	if !ok {
+		return missingVersion(c)
+	}
+	before, _ := ctrl.numbering.Get(c.Context(), scheme.DocType)
+	saved, err := ctrl.numbering.Save(c.Context(), scheme, middleware.CurrentUserID(c), version)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		current, _ := ctrl.repo.Get(c.Context())
+		return versionConflict(c, current)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Update failed", Data: nil})
+	}
+	audit(c, models.AuditNumberingUpdate, scheme.DocType, before, scheme)
+	setETag(c, saved)
+	preview, err := ctrl.numbering.Preview(c.Context(), scheme, 3)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Preview failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Numbering scheme saved", Data: fiber.Map{
+		"scheme":  scheme,
+		"preview": preview,
+		"version": saved,
+	}})
+}
+
+// GET /api/settings/numbering/:docType/preview?count=5
+// Xem trước các mã sẽ được cấp tiếp theo (không làm tăng bộ đếm)
+func (ctrl *StoreSettingController) PreviewNumbering(c *fiber.Ctx) error {
+	count := c.QueryInt("count", 5)
+	if count < 1 || count > 50 {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "count must be between 1 and 50", Data: nil})
+	}
+	scheme, err := ctrl.numbering.Get(c.Context(), c.Params("docType"))
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{Status: "error", Message: "Unknown document type", Data: nil})
+	}
+	codes, err := ctrl.numbering.Preview(c.Context(), scheme, count)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Preview failed", Data: nil})
+	}
+	return c.JSON(models.APIResponse{Status: "success", Message: "Numbering preview", Data: fiber.Map{
+		"scheme": scheme,
+		"codes":  codes,
+	}})
 }
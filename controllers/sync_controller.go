@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxSyncInvoices giới hạn số hóa đơn offline trong một lần đồng bộ
+const maxSyncInvoices = 500
+
+// syncClockSkew lùi cursor trả về một khoảng nhỏ để không bỏ sót bản ghi ghi xong ngay sau lúc truy vấn.
+// Client có thể nhận lại vài bản ghi đã có, cần xử lý theo kiểu upsert.
+const syncClockSkew = 5 * time.Second
+
+// SyncController xử lý đồng bộ hai chiều cho máy POS chạy offline
+type SyncController struct {
+	creator    *InvoiceController
+	invoices   *repositories.InvoiceRepository
+	products   *repositories.ProductRepository
+	settings   *repositories.StoreSettingRepository
+	tombstones *repositories.TombstoneRepository
+}
+
+func NewSyncController(
+	creator *InvoiceController,
+	invoices *repositories.InvoiceRepository,
+	products *repositories.ProductRepository,
+	settings *repositories.StoreSettingRepository,
+	tombstones *repositories.TombstoneRepository,
+) *SyncController {
+	return &SyncController{creator: creator, invoices: invoices, products: products, settings: settings, tombstones: tombstones}
+}
+
+// syncInvoiceInput là hóa đơn tạo offline trên máy POS
+type syncInvoiceInput struct {
+	ClientID  string               `json:"clientId"`  // UUID do máy POS sinh
+	CreatedAt time.Time            `json:"createdAt"` // Thời điểm bán trên máy POS
+	Items     []models.InvoiceItem `json:"items"`
+	Note      string               `json:"note"`
+
+	PaymentMethod string  `json:"paymentMethod"`
+	Discount      float64 `json:"discount"`
+}
+
+// syncInvoiceResult là kết quả xử lý từng hóa đơn offline
+type syncInvoiceResult struct {
+	ClientID string `json:"clientId"`
+	Status   string `json:"status"` // created | duplicate | conflict | rejected
+	ID       string `json:"id,omitempty"`
+	Code     string `json:"code,omitempty"` // Mã hóa đơn do server cấp
+	Message  string `json:"message,omitempty"`
+}
+
+// Sync đẩy hóa đơn offline lên server và kéo về sản phẩm, giá, thông tin cửa hàng đã thay đổi từ cursor
+//
+// @route POST /api/sync
+//
+//	@body {
+//	  "cursor": "2025-06-10T03:15:00.123Z",
+//	  "invoices": [
+//	    {
+//	      "clientId": "0b8f6f0e-5c55-4a4e-9a4f-3f2f1d7a9c11",
+//	      "createdAt": "2025-06-10T10:01:02+07:00",
+//	      "items": [{ "productId": "xxx", "name": "Áo thun", "quantity": 1, "price": 120000 }],
+//	      "note": "",
+//	      "paymentMethod": "cash",
+//	      "discount": 0
+//	    }
+//	  ]
+//	}
+//
+// Không gửi cursor (hoặc cursor cũ hơn thời gian lưu tombstone) -> trả về toàn bộ dữ liệu với "full": true.
+// Lưu "cursor" trong response để dùng cho lần đồng bộ tiếp theo.
+func (ctrl *SyncController) Sync(c *fiber.Ctx) error {
+	var body struct {
+		Cursor   string             `json:"cursor"`
+		Invoices []syncInvoiceInput `json:"invoices"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid input", Data: nil})
+	}
+	if len(body.Invoices) > maxSyncInvoices {
+		return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Too many invoices in one sync", Data: nil})
+	}
+
+	var since time.Time
+	if body.Cursor != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, body.Cursor)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{Status: "error", Message: "Invalid cursor", Data: nil})
+		}
+	}
+	// Lấy mốc trước khi đọc dữ liệu để không bỏ sót thay đổi xảy ra trong lúc đồng bộ
+	watermark := time.Now().Add(-syncClockSkew)
+
+	// === Đẩy hóa đơn offline ===
+	results := make([]syncInvoiceResult, 0, len(body.Invoices))
+	for _, input := range body.Invoices {
+		results = append(results, ctrl.pushInvoice(c, input))
+	}
+
+	// === Kéo dữ liệu thay đổi ===
+	full := since.IsZero() || time.Since(since) > ctrl.tombstones.Retention()
+	if full {
+		since = time.Time{}
+	}
+
+	products, err := ctrl.products.ListUpdatedSince(c.Context(), since)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Sync failed", Data: nil})
+	}
+
+	tombstones := []models.Tombstone{}
+	if !full {
+		tombstones, err = ctrl.tombstones.ListSince(c.Context(), "products", since)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Sync failed", Data: nil})
+		}
+	}
+
+	var settings *models.StoreSetting
+	setting, err := ctrl.settings.Get(c.Context())
+	if err != nil && err != mongo.ErrNoDocuments {
+		return c.Status(500).JSON(models.APIResponse{Status: "error", Message: "Sync failed", Data: nil})
+	}
+	if err == nil && (full || !setting.UpdatedAt.Before(since)) {
+		settings = setting
+	}
+
+	return c.JSON(models.APIResponse{Status: "success", Message: "Synced", Data: fiber.Map{
+		"invoices":   results,
+		"products":   products,
+		"tombstones": tombstones,
+		"settings":   settings,
+		"full":       full,
+		"cursor":     watermark.UTC().Format(time.RFC3339Nano),
+	}})
+}
+
+// pushInvoice lưu một hóa đơn offline. Gửi lại cùng clientId không tạo hóa đơn mới mà trả về mã đã cấp.
+func (ctrl *SyncController) pushInvoice(c *fiber.Ctx, input syncInvoiceInput) syncInvoiceResult {
+	result := syncInvoiceResult{ClientID: input.ClientID}
+	if _, err := uuid.Parse(input.ClientID); err != nil {
+		result.Status, result.Message = "rejected", "clientId must be a UUID"
+		return result
+	}
+	if len(input.Items) == 0 {
+		result.Status, result.Message = "rejected", "Invoice has no items"
+		return result
+	}
+	for _, item := range input.Items {
+		if item.Quantity <= 0 || item.Price < 0 {
+			result.Status, result.Message = "rejected", "Invalid item quantity or price"
+			return result
+		}
+	}
+
+	// clientId đã được đồng bộ trước đó (client gửi lại sau khi mất kết nối): kiểm tra trước để không tốn số hóa đơn
+	existing, err := ctrl.invoices.FindByClientID(c.Context(), input.ClientID)
+	if err == nil {
+		return alreadySynced(result, existing, input)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		result.Status, result.Message = "rejected", "Create failed"
+		return result
+	}
+
+	invoice := models.Invoice{
+		ClientID:      input.ClientID,
+		Items:         input.Items,
+		Note:          input.Note,
+		PaymentMethod: input.PaymentMethod,
+		Discount:      input.Discount,
+	}
+	if !input.CreatedAt.IsZero() {
+		createdAt := input.CreatedAt
+		invoice.ClientCreatedAt = &createdAt
+	}
+	created, err := ctrl.creator.createInvoice(c, invoice)
+	if err == nil {
+		result.Status, result.ID, result.Code = "created", created.ID.Hex(), created.Code
+		return result
+	}
+	var invalid invalidInvoiceError
+	if errors.As(err, &invalid) {
+		result.Status, result.Message = "rejected", invalid.message
+		return result
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		result.Status, result.Message = "rejected", "Create failed"
+		return result
+	}
+
+	// Hai lần gửi cùng clientId chạy song song: lần còn lại đã lưu trước
+	existing, err = ctrl.invoices.FindByClientID(c.Context(), input.ClientID)
+	if err != nil {
+		result.Status, result.Message = "rejected", "Create failed"
+		return result
+	}
+	return alreadySynced(result, existing, input)
+}
+
+// alreadySynced trả kết quả cho clientId đã có hóa đơn: duplicate nếu cùng nội dung
+// (sản phẩm, ghi chú, phương thức thanh toán, giảm giá), ngược lại conflict
+func alreadySynced(result syncInvoiceResult, existing *models.Invoice, input syncInvoiceInput) syncInvoiceResult {
+	result.ID, result.Code = existing.ID.Hex(), existing.Code
+	paymentMethod := input.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = models.PaymentCash
+	}
+	if !slices.Equal(existing.Items, input.Items) || existing.Note != input.Note ||
+		existing.PaymentMethod != paymentMethod || existing.Discount != input.Discount {
+		result.Status, result.Message = "conflict", "clientId already synced with different content"
+		return result
+	}
+	result.Status = "duplicate"
+	return result
+}
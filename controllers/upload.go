@@ -57,6 +57,7 @@ func GetUploadUrl(c *fiber.Ctx) error {
 		})
 	}
 	directURL := "https://" + endpoint + "/" + bucket + "/" + input.Key
+	audit(c, models.AuditUploadPresign, input.Key, nil, fiber.Map{"bucket": bucket, "key": input.Key, "url": directURL})
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Upload URL generated successfully",
@@ -1,13 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"go-fiber-api/config"
+	"go-fiber-api/middleware"
 	"go-fiber-api/models"
 	"go-fiber-api/repositories"
 	"go-fiber-api/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
-	"os"
+	"go.mongodb.org/mongo-driver/mongo"
 	"strings"
 )
 
@@ -17,18 +20,48 @@ import (
 //
 //	{
 //	  "username": "teacher1",
-//	  "password": "123456",
-//	  "role": "member",
+//	  "password": "Thungan2025",
+//	  "role": "user",
+//	  "fullName": "Nguyễn Văn A",
+//	  "phone": "0901234567"
 //	}
+//
+// Chỉ gán được vai trò có quyền nằm trong quyền của người tạo; vai trò admin chỉ admin gán được.
 func CreateUser(c *fiber.Ctx) error {
-	var user models.User
-	if err := c.BodyParser(&user); err != nil {
+	var input models.User
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
 			Status:  "error",
 			Message: "Invalid data",
 			Data:    nil,
 		})
 	}
+	// Chỉ nhận các trường người tạo được đặt; trạng thái, thời điểm do server quản lý
+	user := models.User{
+		Username: strings.TrimSpace(input.Username),
+		Password: input.Password,
+		Role:     input.Role,
+		FullName: strings.TrimSpace(input.FullName),
+		Phone:    strings.TrimSpace(input.Phone),
+	}
+
+	if user.Username == "" || user.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Username and password are required",
+			Data:    nil,
+		})
+	}
+	if err := utils.CurrentPasswordPolicy().Validate(user.Password, user.Username); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if ok, err := checkRole(c, user.Role); !ok {
+		return err
+	}
 
 	// Kiểm tra username đã tồn tại chưa
 	exists, err := repositories.IsUsernameExists(user.Username)
@@ -69,6 +102,7 @@ func CreateUser(c *fiber.Ctx) error {
 
 	// Xoá password trước khi trả về frontend
 	user.Password = ""
+	audit(c, models.AuditUserCreate, user.ID.Hex(), nil, user)
 
 	return c.JSON(models.APIResponse{
 		Status:  "success",
@@ -77,12 +111,12 @@ func CreateUser(c *fiber.Ctx) error {
 	})
 }
 
-// GetUsersByRole retrieves users by their role
-// GET /api/users?role=member
+// GetUsersByRole retrieves users by their role and status (active | disabled, default all)
+// GET /api/users?role=user&status=active
 func GetUsersByRole(c *fiber.Ctx) error {
 	role := c.Query("role")
 
-	users, err := repositories.GetUsersByRole(role)
+	users, err := repositories.GetUsersByRole(role, c.Query("status"))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Status:  "error",
@@ -98,14 +132,13 @@ func GetUsersByRole(c *fiber.Ctx) error {
 	})
 }
 
-// ChangeUserPassword updates a user's password after verifying the old one
+// ChangeUserPassword updates the current user's password after verifying the old one
 // PUT /api/users/password
 // Body:
 //
 //	{
-//	  "id": "665e1b3fa6ef0c2d7e3e594f",
 //	  "old_password": "admin123",
-//	  "new_password": "123456"
+//	  "new_password": "Matkhau2025"
 //	}
 func ChangeUserPassword(c *fiber.Ctx) error {
 	var body struct {
@@ -123,50 +156,7 @@ func ChangeUserPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Lấy token từ header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
-			Status:  "error",
-			Message: "Missing or invalid Authorization header",
-			Data:    nil,
-		})
-	}
-
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Kiểm tra signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fiber.NewError(fiber.StatusUnauthorized, "Unexpected signing method")
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
-			Status:  "error",
-			Message: "Invalid token",
-			Data:    nil,
-		})
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
-			Status:  "error",
-			Message: "Invalid token claims",
-			Data:    nil,
-		})
-	}
-
-	userID, ok := claims["id"].(string)
-	if !ok || userID == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
-			Status:  "error",
-			Message: "User ID not found in token",
-			Data:    nil,
-		})
-	}
+	userID := middleware.CurrentUserID(c)
 	// Tìm user
 	user, err := repositories.FindUserByID(userID)
 	if err != nil {
@@ -185,6 +175,20 @@ func ChangeUserPassword(c *fiber.Ctx) error {
 			Data:    nil,
 		})
 	}
+	if err := utils.CurrentPasswordPolicy().Validate(body.NewPassword, user.Username); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Status:  "error",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if passwordReused(user, body.NewPassword) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "New password must be different from your recent passwords",
+			Data:    nil,
+		})
+	}
 
 	// Mã hoá mật khẩu mới
 	hashed, err := utils.HashPassword(body.NewPassword)
@@ -197,7 +201,7 @@ func ChangeUserPassword(c *fiber.Ctx) error {
 	}
 
 	// Cập nhật mật khẩu
-	err = repositories.UpdateUserPassword(userID, hashed)
+	err = repositories.UpdateUserPassword(userID, hashed, passwordHistory(user))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Status:  "error",
@@ -206,9 +210,19 @@ func ChangeUserPassword(c *fiber.Ctx) error {
 		})
 	}
 
+	// Thu hồi mọi phiên (kể cả phiên hiện tại): token bị lộ trước khi đổi mật khẩu không dùng được nữa
+	if err := repositories.RevokeUserSessions(userID, models.RevokePasswordChanged); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Status:  "error",
+			Message: "Password changed but sessions could not be revoked",
+			Data:    nil,
+		})
+	}
+	audit(c, models.AuditUserChangePassword, userID, nil, nil)
+
 	return c.JSON(models.APIResponse{
 		Status:  "success",
-		Message: "Password changed successfully",
+		Message: "Password changed successfully, please log in again",
 		Data:    nil,
 	})
 }
@@ -221,20 +235,140 @@ func ChangeUserPassword(c *fiber.Ctx) error {
 //	{
 //	    "id": "665e1b3fa6ef0c2d7e3e594f",
 //	    "username": "newname",
-//	    "role": "member"
+//	    "role": "user",
+//	    "fullName": "Nguyễn Văn A",
+//	    "phone": "0901234567"
 //	}
+//
+// Người dùng đang có vai trò nhiều quyền hơn người sửa (hoặc admin, nếu người sửa không phải admin) không sửa được.
+// Không hạ vai trò của admin cuối cùng còn hoạt động.
 func UpdateUser(c *fiber.Ctx) error {
 	var user models.User
 	if err := c.BodyParser(&user); err != nil || user.ID.IsZero() {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{Status: "error", Message: "Invalid data", Data: nil})
 	}
+	user.Username, user.FullName, user.Phone = strings.TrimSpace(user.Username), strings.TrimSpace(user.FullName), strings.TrimSpace(user.Phone)
+	if user.Username == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{Status: "error", Message: "Username is required", Data: nil})
+	}
+	if ok, err := checkRole(c, user.Role); !ok {
+		return err
+	}
+	// Không tự đổi vai trò của mình để tránh tự nâng quyền hoặc tự khoá
+	if user.ID.Hex() == middleware.CurrentUserID(c) && user.Role != middleware.CurrentRole(c) {
+		return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Cannot change your own role", Data: nil})
+	}
+	existing, err := repositories.FindUserByID(user.ID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if ok, err := checkManageRole(c, existing.Role); !ok {
+		return err
+	}
+	if existing.Role == models.RoleAdmin && user.Role != models.RoleAdmin && !existing.Disabled {
+		if ok, err := checkAdminRemains(c, []string{existing.ID.Hex()}); !ok {
+			return err
+		}
+	}
 	if err := repositories.UpdateUser(user.ID.Hex(), user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to update user", Data: nil})
 	}
+	existing.Password = ""
+	audit(c, models.AuditUserUpdate, user.ID.Hex(), existing, auditUser(user.ID.Hex()))
+	// Vai trò nằm trong access token nên đổi vai trò phải thu hồi phiên để quyền mới có hiệu lực ngay
+	if existing.Role != user.Role {
+		if err := repositories.RevokeUserSessions(user.ID.Hex(), models.RevokeRoleChanged); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke user sessions", Data: nil})
+		}
+	}
 	return c.JSON(models.APIResponse{Status: "success", Message: "User updated", Data: nil})
 }
 
-// DeleteUsers xoá một hoặc nhiều người dùng
+// checkRole kiểm tra vai trò có trong collection roles và người gọi được gán vai trò này;
+// không hợp lệ -> đã ghi response lỗi và trả về false
+func checkRole(c *fiber.Ctx, role string) (bool, error) {
+	exists, err := repositories.RoleExists(role)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Error checking role", Data: nil})
+	}
+	if !exists {
+		return false, c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{Status: "error", Message: "Unknown role " + role, Data: nil})
+	}
+	return checkManageRole(c, role)
+}
+
+// checkManageRole kiểm tra người gọi được gán vai trò role hoặc quản lý người dùng đang có vai trò này: admin được
+// mọi vai trò; người khác chỉ được vai trò không phải admin và có quyền nằm trong quyền của chính mình, để người có
+// user.manage không tự tạo tài khoản mạnh hơn mình hay chiếm tài khoản admin. Không được -> đã ghi 403, trả về false.
+func checkManageRole(c *fiber.Ctx, role string) (bool, error) {
+	if middleware.CurrentRole(c) == models.RoleAdmin {
+		return true, nil
+	}
+	forbidden := func() (bool, error) {
+		return false, c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Forbidden: role " + role + " has permissions you do not have", Data: nil})
+	}
+	if role == models.RoleAdmin {
+		return forbidden()
+	}
+	target, err := repositories.FindRole(role)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Vai trò đã bị xoá: không còn quyền nào
+		return true, nil
+	}
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Error checking role", Data: nil})
+	}
+	granted, err := callerPermissions(c)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Error checking role", Data: nil})
+	}
+	for _, p := range target.Permissions {
+		if !granted[p] {
+			return forbidden()
+		}
+	}
+	return true, nil
+}
+
+// callerPermissions trả về quyền của vai trò người gọi (admin có mọi quyền); vai trò đã bị xoá -> không có quyền nào
+func callerPermissions(c *fiber.Ctx) (map[string]bool, error) {
+	granted := map[string]bool{}
+	role := middleware.CurrentRole(c)
+	if role == models.RoleAdmin {
+		for _, p := range models.AllPermissions() {
+			granted[p] = true
+		}
+		return granted, nil
+	}
+	caller, err := repositories.FindRole(role)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return granted, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range caller.Permissions {
+		granted[p] = true
+	}
+	return granted, nil
+}
+
+// checkAdminRemains kiểm tra sau khi hạ vai trò / vô hiệu hoá các user ids vẫn còn ít nhất một admin hoạt động;
+// không còn -> đã ghi 409 và trả về false
+func checkAdminRemains(c *fiber.Ctx, ids []string) (bool, error) {
+	count, err := repositories.CountActiveUsersByRole(models.RoleAdmin, ids)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Error checking admins", Data: nil})
+	}
+	if count == 0 {
+		return false, c.Status(fiber.StatusConflict).JSON(models.APIResponse{Status: "error", Message: "Cannot remove the last active admin", Data: nil})
+	}
+	return true, nil
+}
+
+// DeleteUsers vô hiệu hoá một hoặc nhiều người dùng (không xoá hẳn): không đăng nhập được, mọi phiên và API key do họ
+// tạo bị thu hồi, hóa đơn và ca cũ vẫn tra được thu ngân. Kích hoạt lại qua POST /api/users/:id/enable.
+// Không vô hiệu hoá được người dùng có vai trò mạnh hơn người gọi hoặc admin cuối cùng còn hoạt động.
 //
 // @route DELETE /api/users?id=abc,def
 func DeleteUsers(c *fiber.Ctx) error {
@@ -242,8 +376,138 @@ func DeleteUsers(c *fiber.Ctx) error {
 	if len(ids) == 0 || ids[0] == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{Status: "error", Message: "Missing id", Data: nil})
 	}
-	if err := repositories.DeleteUsers(ids); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Delete failed", Data: nil})
+	for _, id := range ids {
+		if id == middleware.CurrentUserID(c) {
+			return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Cannot disable your own account", Data: nil})
+		}
+	}
+	before := map[string]*models.User{}
+	removesAdmin := false
+	for _, id := range ids {
+		if user := auditUser(id); user != nil && !user.Disabled {
+			if ok, err := checkManageRole(c, user.Role); !ok {
+				return err
+			}
+			before[id] = user
+			removesAdmin = removesAdmin || user.Role == models.RoleAdmin
+		}
+	}
+	if removesAdmin {
+		if ok, err := checkAdminRemains(c, ids); !ok {
+			return err
+		}
+	}
+	if err := repositories.DisableUsers(ids); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Disable failed", Data: nil})
+	}
+	for _, id := range ids {
+		if err := repositories.RevokeUserSessions(id, models.RevokeUserDisabled); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke user sessions", Data: nil})
+		}
+		if err := repositories.RevokeUserAPIKeys(id, models.RevokeUserDisabled); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke user API keys", Data: nil})
+		}
+	}
+	for id, user := range before {
+		audit(c, models.AuditUserDisable, id, user, auditUser(id))
 	}
-	return c.JSON(models.APIResponse{Status: "success", Message: "Deleted successfully", Data: nil})
+	return c.JSON(models.APIResponse{Status: "success", Message: "Disabled successfully", Data: nil})
+}
+
+// EnableUser kích hoạt lại người dùng đã bị vô hiệu hoá (vai trò không vượt quyền người gọi)
+//
+// @route POST /api/users/:id/enable
+func EnableUser(c *fiber.Ctx) error {
+	user, err := repositories.FindUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if ok, err := checkManageRole(c, user.Role); !ok {
+		return err
+	}
+	if err := repositories.EnableUser(user.ID.Hex()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Enable failed", Data: nil})
+	}
+	user.Password = ""
+	audit(c, models.AuditUserEnable, user.ID.Hex(), user, auditUser(user.ID.Hex()))
+	return c.JSON(models.APIResponse{Status: "success", Message: "User enabled", Data: nil})
+}
+
+// ResetUserPassword đặt mật khẩu tạm cho người dùng quên mật khẩu và trả về mật khẩu tạm (chỉ hiển thị một lần).
+// Mật khẩu tạm hết hạn sau TEMP_PASSWORD_TTL_HOURS giờ; đăng nhập bằng mật khẩu tạm chỉ được đổi mật khẩu.
+// Mọi phiên của người dùng bị thu hồi. Chỉ đặt lại được cho người dùng có vai trò không vượt quyền người gọi.
+//
+// @route POST /api/users/:id/reset-password
+func ResetUserPassword(c *fiber.Ctx) error {
+	user, err := repositories.FindUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIResponse{Status: "error", Message: "User not found", Data: nil})
+	}
+	if user.ID.Hex() == middleware.CurrentUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{Status: "error", Message: "Use PUT /api/users/password to change your own password", Data: nil})
+	}
+	if ok, err := checkManageRole(c, user.Role); !ok {
+		return err
+	}
+	password, err := utils.NewTemporaryPassword()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Reset password failed", Data: nil})
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to encrypt password", Data: nil})
+	}
+	expires := time.Now().Add(time.Duration(config.GetEnvInt("TEMP_PASSWORD_TTL_HOURS", 24)) * time.Hour)
+	if err := repositories.SetTemporaryPassword(user.ID.Hex(), hashed, expires, passwordHistory(user)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Reset password failed", Data: nil})
+	}
+	if err := repositories.RevokeUserSessions(user.ID.Hex(), models.RevokePasswordReset); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{Status: "error", Message: "Unable to revoke user sessions", Data: nil})
+	}
+	// Không ghi mật khẩu tạm vào nhật ký
+	audit(c, models.AuditUserResetPassword, user.ID.Hex(), nil, fiber.Map{"mustChangePassword": true, "passwordExpiresAt": expires})
+	return c.JSON(models.APIResponse{Status: "success", Message: "Temporary password generated", Data: fiber.Map{
+		"temporaryPassword": password,
+		"expiresAt":         expires,
+	}})
+}
+
+// passwordHistory trả về lịch sử mật khẩu khi thay mật khẩu hiện tại của user: thêm hash hiện tại vào đầu
+// (trừ mật khẩu tạm), giữ tối đa PASSWORD_HISTORY mục
+func passwordHistory(user *models.User) []string {
+	history := user.PasswordHistory
+	if !user.MustChangePassword && user.Password != "" {
+		history = append([]string{user.Password}, history...)
+	}
+	if keep := utils.CurrentPasswordPolicy().History; len(history) > keep {
+		history = history[:max(keep, 0)]
+	}
+	return history
+}
+
+// passwordReused kiểm tra mật khẩu trùng mật khẩu hiện tại hoặc một trong các mật khẩu gần nhất
+func passwordReused(user *models.User, password string) bool {
+	if utils.CheckPasswordHash(password, user.Password) {
+		return true
+	}
+	history := user.PasswordHistory
+	if keep := utils.CurrentPasswordPolicy().History; len(history) > keep {
+		history = history[:max(keep, 0)]
+	}
+	for _, hash := range history {
+		if utils.CheckPasswordHash(password, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditUser đọc user để ghi ảnh chụp vào nhật ký thao tác (đã bỏ hash mật khẩu); không có -> nil
+func auditUser(id string) *models.User {
+	user, err := repositories.FindUserByID(id)
+	if err != nil {
+		return nil
+	}
+	user.Password = ""
+	return user
 }
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go-fiber-api/models"
+)
+
+// requestVersion lấy version mà client đã đọc trước khi sửa.
+// Ưu tiên header If-Match (ETag dạng "3" hoặc W/"3"), sau đó tới trường "version" trong body JSON.
+func requestVersion(c *fiber.Ctx) (int64, bool) {
+	if ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch)); ifMatch != "" && ifMatch != "*" {
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+		version, err := strconv.ParseInt(tag, 10, 64)
+		return version, err == nil
+	}
+
+	var body struct {
+		Version *int64 `json:"version"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil || body.Version == nil {
+		return 0, false
+	}
+	return *body.Version, true
+}
+
+// setETag gắn header ETag theo version của bản ghi
+func setETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// missingVersion trả về 428 khi client không gửi version hoặc If-Match
+func missingVersion(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusPreconditionRequired).JSON(models.APIResponse{
+		Status:  "error",
+		Message: `Missing version (send "version" in body or If-Match header)`,
+		Data:    nil,
+	})
+}
+
+// versionConflict trả về 409 kèm bản ghi hiện tại để client tải lại và sửa tiếp
+func versionConflict(c *fiber.Ctx, current any) error {
+	return c.Status(fiber.StatusConflict).JSON(models.APIResponse{
+		Status:  "error",
+		Message: "Record was modified by someone else",
+		Data:    current,
+	})
+}
@@ -0,0 +1,217 @@
+// Package einvoice lập hóa đơn điện tử dạng XML theo định dạng của Tổng cục Thuế
+// (Nghị định 123/2020/NĐ-CP, Thông tư 78/2021/TT-BTC), kiểm tra cấu trúc và ký số XML.
+package einvoice
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"go-fiber-api/models"
+	"go-fiber-api/utils"
+)
+
+// SchemaVersion là phiên bản định dạng dữ liệu hóa đơn điện tử (PBan)
+const SchemaVersion = "2.0.1"
+
+// Tính chất dòng hàng hóa, dịch vụ (TChat)
+const (
+	LineGoods    = 1 // Hàng hóa, dịch vụ
+	LineDiscount = 3 // Chiết khấu thương mại
+)
+
+// TaxRates là thuế suất GTGT hợp lệ và tỷ lệ tương ứng. KCT: không chịu thuế, KKKNT: không kê khai, tính nộp thuế.
+var TaxRates = map[string]float64{
+	"0%":    0,
+	"5%":    0.05,
+	"8%":    0.08,
+	"10%":   0.10,
+	"KCT":   0,
+	"KKKNT": 0,
+}
+
+var paymentLabels = map[string]string{
+	models.PaymentCash:     "Tiền mặt",
+	models.PaymentCard:     "Thẻ",
+	models.PaymentTransfer: "Chuyển khoản",
+	models.PaymentEWallet:  "Ví điện tử",
+}
+
+// Party là thông tin người bán hoặc người mua
+type Party struct {
+	Name      string // Tên đơn vị
+	TaxCode   string // Mã số thuế
+	Address   string
+	Phone     string
+	Email     string
+	BuyerName string // Họ tên người mua hàng (chỉ người mua)
+}
+
+// Line là một dòng trên hóa đơn. Số tiền là tiền chưa thuế.
+type Line struct {
+	Kind      int // LineGoods | LineDiscount
+	No        int // Số thứ tự
+	Name      string
+	Unit      string
+	Quantity  float64
+	UnitPrice float64
+	Amount    float64 // Thành tiền chưa thuế
+	TaxRate   string
+}
+
+// TaxGroup là tổng hợp theo từng thuế suất
+type TaxGroup struct {
+	TaxRate string
+	Amount  float64 // Thành tiền chưa thuế
+	Tax     float64 // Tiền thuế
+}
+
+// Reference là hóa đơn điện tử liên quan, dùng khi lập hóa đơn thay thế
+type Reference struct {
+	TemplateCode string
+	Series       string
+	Number       int64
+	IssueDate    time.Time
+	Note         string // Lý do thay thế
+}
+
+// Document là nội dung hóa đơn điện tử trước khi xuất XML
+type Document struct {
+	TemplateCode  string // Ký hiệu mẫu số (KHMSHDon)
+	Series        string // Ký hiệu hóa đơn (KHHDon)
+	Number        int64  // Số hóa đơn (SHDon)
+	IssueDate     time.Time
+	PaymentMethod string
+	Replaces      *Reference // Hóa đơn bị thay thế, nil với hóa đơn lập mới
+	Seller        Party
+	Buyer         Party
+	Lines         []Line
+	TaxGroups     []TaxGroup
+	TotalAmount   float64 // Tổng tiền chưa thuế (TgTCThue)
+	TotalTax      float64 // Tổng tiền thuế (TgTThue)
+	TotalDiscount float64 // Tổng chiết khấu thương mại chưa thuế (TTCKTMai)
+	TotalPayment  float64 // Tổng tiền thanh toán (TgTTTBSo)
+	AmountInWords string  // Số tiền viết bằng chữ (TgTTTBChu)
+}
+
+// Build lập nội dung hóa đơn điện tử từ hóa đơn bán hàng.
+//
+// Đơn giá trên hóa đơn bán lẻ đã gồm thuế GTGT nên tiền chưa thuế được tách ngược theo thuế suất của từng dòng
+// (dòng không ghi thuế suất dùng thuế suất mặc định của cửa hàng). Giảm giá trên toàn hóa đơn được phân bổ theo
+// tỷ lệ doanh số của từng thuế suất và ghi thành dòng chiết khấu thương mại. Tổng thanh toán luôn bằng số tiền khách trả.
+func Build(invoice models.Invoice, setting models.StoreSetting, buyer models.EInvoiceBuyer, number int64) (*Document, error) {
+	loc := time.FixedZone("GMT+7", 7*3600)
+	doc := &Document{
+		TemplateCode:  setting.EInvoiceTemplate,
+		Series:        setting.EInvoiceSeries,
+		Number:        number,
+		IssueDate:     time.Now().In(loc),
+		PaymentMethod: paymentLabels[invoice.PaymentMethod],
+		Seller: Party{
+			Name:    setting.StoreName,
+			TaxCode: setting.TaxCode,
+			Address: setting.Address,
+			Phone:   setting.Phone,
+			Email:   setting.Email,
+		},
+		Buyer: Party{
+			Name:      buyer.Name,
+			TaxCode:   buyer.TaxCode,
+			Address:   buyer.Address,
+			Phone:     buyer.Phone,
+			Email:     buyer.Email,
+			BuyerName: buyer.BuyerName,
+		},
+	}
+	if doc.PaymentMethod == "" {
+		doc.PaymentMethod = paymentLabels[models.PaymentCash]
+	}
+	if len(invoice.Items) == 0 {
+		return nil, errors.New("invoice has no items")
+	}
+
+	// Doanh số đã gồm thuế theo từng thuế suất, để phân bổ giảm giá
+	gross := map[string]float64{}
+	var rates []string
+	for _, item := range invoice.Items {
+		rate := item.VATRate
+		if rate == "" {
+			rate = setting.DefaultVATRate
+		}
+		if _, ok := TaxRates[rate]; !ok {
+			return nil, errors.New("invalid VAT rate " + rate + " for item " + item.Name)
+		}
+		if _, seen := gross[rate]; !seen {
+			rates = append(rates, rate)
+		}
+		gross[rate] += float64(item.Quantity) * item.Price
+	}
+	sort.Strings(rates)
+
+	groups := map[string]*TaxGroup{}
+	for _, rate := range rates {
+		groups[rate] = &TaxGroup{TaxRate: rate}
+	}
+	for _, item := range invoice.Items {
+		rate := item.VATRate
+		if rate == "" {
+			rate = setting.DefaultVATRate
+		}
+		amount := math.Round(float64(item.Quantity) * item.Price / (1 + TaxRates[rate]))
+		doc.Lines = append(doc.Lines, Line{
+			Kind:      LineGoods,
+			No:        len(doc.Lines) + 1,
+			Name:      item.Name,
+			Unit:      item.Unit,
+			Quantity:  float64(item.Quantity),
+			UnitPrice: math.Round(amount/float64(item.Quantity)*100) / 100,
+			Amount:    amount,
+			TaxRate:   rate,
+		})
+		groups[rate].Amount += amount
+	}
+
+	// Phân bổ giảm giá; thuế suất cuối cùng nhận phần lẻ để tổng giảm giá khớp tuyệt đối
+	grossTotal := models.InvoiceTotal(invoice.Items)
+	if invoice.Discount < 0 || invoice.Discount > grossTotal {
+		return nil, errors.New("invoice discount must be between 0 and the invoice total")
+	}
+	remaining := math.Round(invoice.Discount)
+	for i, rate := range rates {
+		if remaining <= 0 {
+			break
+		}
+		share := math.Round(invoice.Discount * gross[rate] / grossTotal)
+		if i == len(rates)-1 || share > remaining {
+			share = remaining
+		}
+		remaining -= share
+		gross[rate] -= share
+		if share == 0 {
+			continue
+		}
+		amount := math.Round(share / (1 + TaxRates[rate]))
+		doc.Lines = append(doc.Lines, Line{
+			Kind:    LineDiscount,
+			No:      len(doc.Lines) + 1,
+			Name:    "Chiết khấu thương mại",
+			Amount:  amount,
+			TaxRate: rate,
+		})
+		groups[rate].Amount -= amount
+		doc.TotalDiscount += amount
+	}
+
+	for _, rate := range rates {
+		group := groups[rate]
+		// Tiền thuế = phần chênh giữa doanh số gồm thuế và tiền chưa thuế, để tổng thanh toán khớp số tiền khách trả
+		group.Tax = math.Round(gross[rate]) - group.Amount
+		doc.TaxGroups = append(doc.TaxGroups, *group)
+		doc.TotalAmount += group.Amount
+		doc.TotalTax += group.Tax
+	}
+	doc.TotalPayment = doc.TotalAmount + doc.TotalTax
+	doc.AmountInWords = utils.VNDInWords(doc.TotalPayment)
+	return doc, nil
+}
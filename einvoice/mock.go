@@ -0,0 +1,120 @@
+package einvoice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// MockProvider là nhà cung cấp giả lập chạy trong bộ nhớ, không gọi mạng. Dùng cho môi trường phát triển
+// và kiểm thử toàn bộ luồng phát hành / thay thế / huỷ. Dữ liệu mất khi khởi động lại server.
+type MockProvider struct {
+	// Fail nếu khác nil được gọi trước mỗi thao tác (issue, replace, cancel, status);
+	// trả về lỗi để giả lập nhà cung cấp lỗi tạm thời hoặc từ chối (PermanentError)
+	Fail func(action string) error
+
+	mu       sync.Mutex
+	invoices map[string]*Result // theo Ref
+	byKey    map[string]string  // Key -> Ref, để gửi lại cùng Key không phát hành lại
+}
+
+func NewMockProvider() *MockProvider {
+	return &MockProvider{invoices: map[string]*Result{}, byKey: map[string]string{}}
+}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+func (p *MockProvider) Issue(ctx context.Context, req IssueRequest) (*Result, error) {
+	if err := p.fail("issue"); err != nil {
+		return nil, err
+	}
+	if len(req.XML) == 0 || !strings.Contains(string(req.XML), "<SignatureValue>") {
+		return nil, &PermanentError{Message: "mock: invoice XML is not signed"}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.issue(req), nil
+}
+
+func (p *MockProvider) Replace(ctx context.Context, ref string, req IssueRequest) (*Result, error) {
+	if err := p.fail("replace"); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	original, ok := p.invoices[ref]
+	if !ok {
+		return nil, &PermanentError{Message: "mock: invoice " + ref + " not found"}
+	}
+	if existing, ok := p.byKey[req.Key]; ok {
+		return copyResult(p.invoices[existing]), nil
+	}
+	if original.Status != StatusIssued {
+		return nil, &PermanentError{Message: "mock: invoice " + ref + " is " + original.Status}
+	}
+	original.Status = StatusReplaced
+	return p.issue(req), nil
+}
+
+func (p *MockProvider) Cancel(ctx context.Context, ref, reason string) (*Result, error) {
+	if err := p.fail("cancel"); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	invoice, ok := p.invoices[ref]
+	if !ok {
+		return nil, &PermanentError{Message: "mock: invoice " + ref + " not found"}
+	}
+	if invoice.Status == StatusReplaced {
+		return nil, &PermanentError{Message: "mock: invoice " + ref + " is replaced"}
+	}
+	invoice.Status = StatusCancelled
+	invoice.Message = reason
+	return copyResult(invoice), nil
+}
+
+func (p *MockProvider) Status(ctx context.Context, ref string) (*Result, error) {
+	if err := p.fail("status"); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	invoice, ok := p.invoices[ref]
+	if !ok {
+		return nil, &PermanentError{Message: "mock: invoice " + ref + " not found"}
+	}
+	return copyResult(invoice), nil
+}
+
+// issue phát hành hóa đơn, gọi khi đang giữ khoá
+func (p *MockProvider) issue(req IssueRequest) *Result {
+	if ref, ok := p.byKey[req.Key]; ok {
+		return copyResult(p.invoices[ref])
+	}
+	sum := sha256.Sum256([]byte(req.Key))
+	code := strings.ToUpper(hex.EncodeToString(sum[:]))
+	result := &Result{
+		Ref:              "MOCK-" + code[:12],
+		Status:           StatusIssued,
+		TaxAuthorityCode: "M1-" + code[12:24],
+		LookupCode:       code[24:34],
+	}
+	p.invoices[result.Ref] = result
+	p.byKey[req.Key] = result.Ref
+	return copyResult(result)
+}
+
+func (p *MockProvider) fail(action string) error {
+	if p.Fail == nil {
+		return nil
+	}
+	return p.Fail(action)
+}
+
+func copyResult(r *Result) *Result {
+	copied := *r
+	return &copied
+}
@@ -0,0 +1,74 @@
+package einvoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Provider là nhà cung cấp dịch vụ hóa đơn điện tử (VNPT, Viettel, MISA...) nhận XML đã ký,
+// chuyển cơ quan thuế và trả về mã của cơ quan thuế, mã tra cứu.
+//
+// Các thao tác phải idempotent theo IssueRequest.Key: gửi lại cùng Key sau lỗi mạng không được phát hành hai lần.
+type Provider interface {
+	Name() string
+	// Issue phát hành hóa đơn mới
+	Issue(ctx context.Context, req IssueRequest) (*Result, error)
+	// Replace phát hành hóa đơn thay thế cho hóa đơn ref đã phát hành
+	Replace(ctx context.Context, ref string, req IssueRequest) (*Result, error)
+	// Cancel huỷ hóa đơn ref đã phát hành
+	Cancel(ctx context.Context, ref, reason string) (*Result, error)
+	// Status tra cứu trạng thái hóa đơn ref
+	Status(ctx context.Context, ref string) (*Result, error)
+}
+
+// IssueRequest là hóa đơn điện tử gửi nhà cung cấp
+type IssueRequest struct {
+	Key          string // Khoá idempotent, dùng ID bản ghi hóa đơn điện tử
+	TemplateCode string
+	Series       string
+	Number       int64
+	XML          []byte // XML đã ký
+	Reason       string // Lý do thay thế (chỉ Replace)
+}
+
+// Trạng thái hóa đơn phía nhà cung cấp
+const (
+	StatusIssued    = "issued"
+	StatusCancelled = "cancelled"
+	StatusReplaced  = "replaced"
+)
+
+// Result là kết quả nhà cung cấp trả về
+type Result struct {
+	Ref              string // Mã giao dịch phía nhà cung cấp
+	Status           string
+	TaxAuthorityCode string // Mã của cơ quan thuế
+	LookupCode       string // Mã tra cứu
+	Message          string
+}
+
+// PermanentError là lỗi không nên gửi lại (dữ liệu bị từ chối, sai cấu hình...).
+// Lỗi khác (mạng, timeout, nhà cung cấp bận) được coi là tạm thời và sẽ gửi lại.
+type PermanentError struct {
+	Message string
+}
+
+func (e *PermanentError) Error() string { return e.Message }
+
+// IsPermanent kiểm tra lỗi có phải lỗi không nên gửi lại không
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// NewProvider tạo nhà cung cấp theo tên cấu hình trong EINVOICE_PROVIDER. Không có mặc định: chạy mock phải
+// ghi rõ EINVOICE_PROVIDER=mock để môi trường thật không vô tình gửi hóa đơn vào nhà cung cấp giả lập.
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case "mock":
+		return NewMockProvider(), nil
+	default:
+		return nil, fmt.Errorf("unsupported e-invoice provider %q", name)
+	}
+}
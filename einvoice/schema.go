@@ -0,0 +1,506 @@
+package einvoice
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// hdonXSD là lược đồ XML hóa đơn điện tử dùng để kiểm tra XML xuất ra
+//
+//go:embed schema/HDon.xsd
+var hdonXSD []byte
+
+// hdonSchema được nạp một lần khi khởi động; lược đồ lỗi là lỗi lập trình nên panic
+var hdonSchema = mustLoadSchema(hdonXSD)
+
+// Bộ kiểm tra XSD dưới đây hỗ trợ phần lược đồ mà HDon.xsd dùng: phần tử toàn cục, complexType có sequence
+// (element, any) và attribute, simpleType restriction với các facet pattern, enumeration, minLength,
+// maxLength, minInclusive, maxInclusive, fractionDigits; kiểu dựng sẵn string, decimal, integer,
+// positiveInteger, date. Lược đồ dùng cấu trúc khác sẽ bị từ chối khi nạp.
+
+type xsdSchema struct {
+	Elements     []xsdParticle    `xml:"element"`
+	ComplexTypes []xsdComplexType `xml:"complexType"`
+	SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+}
+
+// xsdParticle là xs:element hoặc xs:any
+type xsdParticle struct {
+	XMLName   xml.Name
+	Name      string          `xml:"name,attr"`
+	Type      string          `xml:"type,attr"`
+	Fixed     *string         `xml:"fixed,attr"`
+	MinOccurs string          `xml:"minOccurs,attr"`
+	MaxOccurs string          `xml:"maxOccurs,attr"`
+	Namespace string          `xml:"namespace,attr"`
+	Complex   *xsdComplexType `xml:"complexType"`
+	Simple    *xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdComplexType struct {
+	Name     string `xml:"name,attr"`
+	Sequence *struct {
+		Particles []xsdParticle `xml:",any"`
+	} `xml:"sequence"`
+	Attributes []xsdAttribute `xml:"attribute"`
+}
+
+type xsdAttribute struct {
+	Name  string  `xml:"name,attr"`
+	Type  string  `xml:"type,attr"`
+	Use   string  `xml:"use,attr"`
+	Fixed *string `xml:"fixed,attr"`
+}
+
+type xsdFacet struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdSimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction struct {
+		Base           string     `xml:"base,attr"`
+		Patterns       []xsdFacet `xml:"pattern"`
+		Enumerations   []xsdFacet `xml:"enumeration"`
+		MinLength      *xsdFacet  `xml:"minLength"`
+		MaxLength      *xsdFacet  `xml:"maxLength"`
+		MinInclusive   *xsdFacet  `xml:"minInclusive"`
+		MaxInclusive   *xsdFacet  `xml:"maxInclusive"`
+		FractionDigits *xsdFacet  `xml:"fractionDigits"`
+	} `xml:"restriction"`
+}
+
+// schema là lược đồ đã nạp: phần tử gốc và các kiểu đặt tên
+type schema struct {
+	root    *xsdParticle
+	complex map[string]*xsdComplexType
+	simple  map[string]*simpleType
+}
+
+// simpleType là xs:simpleType đã biên dịch các facet
+type simpleType struct {
+	base           string // Kiểu dựng sẵn hoặc tên simpleType khác
+	patterns       []*regexp.Regexp
+	enumerations   []string
+	minLength      int
+	maxLength      int // 0 = không giới hạn
+	minInclusive   *big.Rat
+	maxInclusive   *big.Rat
+	fractionDigits int // -1 = không giới hạn
+}
+
+var builtinTypes = map[string]bool{"string": true, "decimal": true, "integer": true, "positiveInteger": true, "date": true}
+
+var (
+	decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	integerPattern = regexp.MustCompile(`^[+-]?\d+$`)
+)
+
+func mustLoadSchema(data []byte) *schema {
+	s, err := loadSchema(data)
+	if err != nil {
+		panic("einvoice: invalid schema: " + err.Error())
+	}
+	return s
+}
+
+func loadSchema(data []byte) (*schema, error) {
+	var doc xsdSchema
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Elements) != 1 {
+		return nil, fmt.Errorf("expected exactly one global element, got %d", len(doc.Elements))
+	}
+	s := &schema{root: &doc.Elements[0], complex: map[string]*xsdComplexType{}, simple: map[string]*simpleType{}}
+	for i := range doc.ComplexTypes {
+		s.complex[doc.ComplexTypes[i].Name] = &doc.ComplexTypes[i]
+	}
+	for i := range doc.SimpleTypes {
+		compiled, err := compileSimpleType(&doc.SimpleTypes[i])
+		if err != nil {
+			return nil, fmt.Errorf("simpleType %s: %w", doc.SimpleTypes[i].Name, err)
+		}
+		s.simple[doc.SimpleTypes[i].Name] = compiled
+	}
+	// Kiểm tra trước mọi tham chiếu kiểu để lỗi lược đồ lộ ra khi khởi động thay vì lúc lập hóa đơn
+	if err := s.checkParticle(s.root); err != nil {
+		return nil, err
+	}
+	for _, ct := range s.complex {
+		if err := s.checkComplex(ct); err != nil {
+			return nil, err
+		}
+	}
+	for name, st := range s.simple {
+		if !builtinTypes[st.base] && s.simple[st.base] == nil {
+			return nil, fmt.Errorf("simpleType %s: unknown base %q", name, st.base)
+		}
+	}
+	return s, nil
+}
+
+func compileSimpleType(t *xsdSimpleType) (*simpleType, error) {
+	r := &t.Restriction
+	st := &simpleType{base: localName(r.Base), fractionDigits: -1}
+	for _, p := range r.Patterns {
+		// Mẫu XSD luôn khớp toàn bộ giá trị
+		re, err := regexp.Compile(`^(?:` + p.Value + `)$`)
+		if err != nil {
+			return nil, err
+		}
+		st.patterns = append(st.patterns, re)
+	}
+	for _, e := range r.Enumerations {
+		st.enumerations = append(st.enumerations, e.Value)
+	}
+	var err error
+	if r.MinLength != nil {
+		if st.minLength, err = strconv.Atoi(r.MinLength.Value); err != nil {
+			return nil, err
+		}
+	}
+	if r.MaxLength != nil {
+		if st.maxLength, err = strconv.Atoi(r.MaxLength.Value); err != nil {
+			return nil, err
+		}
+	}
+	if r.FractionDigits != nil {
+		if st.fractionDigits, err = strconv.Atoi(r.FractionDigits.Value); err != nil {
+			return nil, err
+		}
+	}
+	if r.MinInclusive != nil {
+		if st.minInclusive, err = parseRat(r.MinInclusive.Value); err != nil {
+			return nil, err
+		}
+	}
+	if r.MaxInclusive != nil {
+		if st.maxInclusive, err = parseRat(r.MaxInclusive.Value); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (s *schema) checkParticle(p *xsdParticle) error {
+	switch p.XMLName.Local {
+	case "any":
+		return nil
+	case "element":
+	default:
+		return fmt.Errorf("unsupported particle xs:%s", p.XMLName.Local)
+	}
+	switch {
+	case p.Complex != nil:
+		return s.checkComplex(p.Complex)
+	case p.Simple != nil:
+		compiled, err := compileSimpleType(p.Simple)
+		if err != nil {
+			return fmt.Errorf("element %s: %w", p.Name, err)
+		}
+		if !builtinTypes[compiled.base] && s.simple[compiled.base] == nil {
+			return fmt.Errorf("element %s: unknown base %q", p.Name, compiled.base)
+		}
+		return nil
+	}
+	name := localName(p.Type)
+	if !builtinTypes[name] && s.simple[name] == nil && s.complex[name] == nil {
+		return fmt.Errorf("element %s: unknown type %q", p.Name, p.Type)
+	}
+	return nil
+}
+
+func (s *schema) checkComplex(ct *xsdComplexType) error {
+	if ct.Sequence != nil {
+		for i := range ct.Sequence.Particles {
+			if err := s.checkParticle(&ct.Sequence.Particles[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, attr := range ct.Attributes {
+		name := localName(attr.Type)
+		if !builtinTypes[name] && s.simple[name] == nil {
+			return fmt.Errorf("attribute %s: unknown type %q", attr.Name, attr.Type)
+		}
+	}
+	return nil
+}
+
+// element là một phần tử của XML cần kiểm tra
+type element struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	text     string
+	children []*element
+}
+
+// parseElement đọc XML thành cây phần tử
+func parseElement(doc []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	var stack []*element
+	var root *element
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root != nil {
+				return nil, fmt.Errorf("multiple root elements")
+			} else {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("empty document")
+	}
+	return root, nil
+}
+
+// validate kiểm tra XML theo lược đồ, trả về danh sách lỗi dạng "đường/dẫn: mô tả"
+func (s *schema) validate(doc []byte) []string {
+	root, err := parseElement(doc)
+	if err != nil {
+		return []string{"malformed XML: " + err.Error()}
+	}
+	v := &validator{}
+	if root.name.Local != s.root.Name {
+		v.fail("root element must be %s, got %s", s.root.Name, root.name.Local)
+		return v.problems
+	}
+	s.validateElement(v, root.name.Local, root, s.root)
+	return v.problems
+}
+
+func (s *schema) validateElement(v *validator, path string, e *element, decl *xsdParticle) {
+	var ct *xsdComplexType
+	var st *simpleType
+	switch {
+	case decl.Complex != nil:
+		ct = decl.Complex
+	case decl.Simple != nil:
+		st, _ = compileSimpleType(decl.Simple)
+	default:
+		name := localName(decl.Type)
+		if ct = s.complex[name]; ct == nil {
+			if st = s.simple[name]; st == nil {
+				st = &simpleType{base: name, fractionDigits: -1}
+			}
+		}
+	}
+
+	if ct == nil {
+		if len(e.children) > 0 {
+			v.fail("%s: element must not have child elements", path)
+			return
+		}
+		for _, attr := range e.attrs {
+			if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+				v.fail("%s: unexpected attribute %s", path, attr.Name.Local)
+			}
+		}
+		if decl.Fixed != nil && e.text != *decl.Fixed {
+			v.fail("%s: value must be %q", path, *decl.Fixed)
+			return
+		}
+		s.validateValue(v, path, e.text, st)
+		return
+	}
+
+	declared := map[string]bool{}
+	for _, attr := range ct.Attributes {
+		declared[attr.Name] = true
+		value, ok := attrValue(e, attr.Name)
+		if !ok {
+			if attr.Use == "required" {
+				v.fail("%s/@%s is required", path, attr.Name)
+			}
+			continue
+		}
+		if attr.Fixed != nil && value != *attr.Fixed {
+			v.fail("%s/@%s must be %q", path, attr.Name, *attr.Fixed)
+			continue
+		}
+		s.validateValue(v, path+"/@"+attr.Name, value, s.typeOf(attr.Type))
+	}
+	for _, attr := range e.attrs {
+		if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" && !declared[attr.Name.Local] {
+			v.fail("%s: unexpected attribute %s", path, attr.Name.Local)
+		}
+	}
+	if strings.TrimSpace(e.text) != "" {
+		v.fail("%s: element must not contain text", path)
+	}
+
+	// Sequence: các phần tử con phải xuất hiện đúng thứ tự, đúng số lần
+	children := e.children
+	if ct.Sequence != nil {
+		for i := range ct.Sequence.Particles {
+			p := &ct.Sequence.Particles[i]
+			min, max := occurs(p)
+			count := 0
+			for len(children) > 0 && (max < 0 || count < max) && matches(p, children[0]) {
+				if p.XMLName.Local == "element" {
+					s.validateElement(v, path+"/"+p.Name, children[0], p)
+				}
+				children = children[1:]
+				count++
+			}
+			if count < min {
+				if p.XMLName.Local == "any" {
+					v.fail("%s: at least %d child element(s) are required", path, min)
+				} else {
+					v.fail("%s/%s is required", path, p.Name)
+				}
+			}
+		}
+	}
+	for _, child := range children {
+		v.fail("%s: unexpected element %s", path, child.name.Local)
+	}
+}
+
+// validateValue kiểm tra giá trị chữ theo kiểu đơn, kể cả các kiểu cơ sở mà kiểu này kế thừa
+func (s *schema) validateValue(v *validator, path, value string, st *simpleType) {
+	if st == nil {
+		return
+	}
+	if base := s.simple[st.base]; base != nil {
+		s.validateValue(v, path, value, base)
+	} else if !validBuiltin(st.base, value) {
+		v.fail("%s: %q is not a valid %s", path, value, st.base)
+		return
+	}
+
+	if len(st.enumerations) > 0 && !slices.Contains(st.enumerations, value) {
+		v.fail("%s: %q must be one of %s", path, value, strings.Join(st.enumerations, ", "))
+	}
+	for _, pattern := range st.patterns {
+		if !pattern.MatchString(value) {
+			v.fail("%s: %q does not match the required format", path, value)
+		}
+	}
+	length := utf8.RuneCountInString(value)
+	if length < st.minLength {
+		v.fail("%s: must be at least %d characters", path, st.minLength)
+	}
+	if st.maxLength > 0 && length > st.maxLength {
+		v.fail("%s: must be at most %d characters", path, st.maxLength)
+	}
+	if st.minInclusive == nil && st.maxInclusive == nil && st.fractionDigits < 0 {
+		return
+	}
+	n, err := parseRat(value)
+	if err != nil {
+		v.fail("%s: %q is not a number", path, value)
+		return
+	}
+	if st.minInclusive != nil && n.Cmp(st.minInclusive) < 0 {
+		v.fail("%s: must be at least %s", path, st.minInclusive.RatString())
+	}
+	if st.maxInclusive != nil && n.Cmp(st.maxInclusive) > 0 {
+		v.fail("%s: must be at most %s", path, st.maxInclusive.RatString())
+	}
+	if st.fractionDigits >= 0 {
+		if _, fraction, ok := strings.Cut(value, "."); ok && len(strings.TrimRight(fraction, "0")) > st.fractionDigits {
+			v.fail("%s: must have at most %d decimal places", path, st.fractionDigits)
+		}
+	}
+}
+
+func (s *schema) typeOf(name string) *simpleType {
+	name = localName(name)
+	if st := s.simple[name]; st != nil {
+		return st
+	}
+	return &simpleType{base: name, fractionDigits: -1}
+}
+
+func validBuiltin(name, value string) bool {
+	switch name {
+	case "decimal":
+		return decimalPattern.MatchString(value)
+	case "integer":
+		return integerPattern.MatchString(value)
+	case "positiveInteger":
+		n, ok := new(big.Int).SetString(strings.TrimPrefix(value, "+"), 10)
+		return integerPattern.MatchString(value) && ok && n.Sign() > 0
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	}
+	return true
+}
+
+// occurs trả về minOccurs, maxOccurs (-1 = unbounded) của phần tử, mặc định 1
+func occurs(p *xsdParticle) (int, int) {
+	min, max := 1, 1
+	if p.MinOccurs != "" {
+		min, _ = strconv.Atoi(p.MinOccurs)
+	}
+	if p.MaxOccurs == "unbounded" {
+		max = -1
+	} else if p.MaxOccurs != "" {
+		max, _ = strconv.Atoi(p.MaxOccurs)
+	}
+	return min, max
+}
+
+func matches(p *xsdParticle, e *element) bool {
+	if p.XMLName.Local == "any" {
+		return p.Namespace == "" || p.Namespace == "##any" || p.Namespace == e.name.Space
+	}
+	return e.name.Local == p.Name && e.name.Space == ""
+}
+
+func attrValue(e *element, name string) (string, bool) {
+	for _, attr := range e.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func localName(name string) string {
+	if _, local, ok := strings.Cut(name, ":"); ok {
+		return local
+	}
+	return name
+}
+
+func parseRat(value string) (*big.Rat, error) {
+	n, ok := new(big.Rat).SetString(strings.TrimPrefix(value, "+"))
+	if !ok {
+		return nil, fmt.Errorf("%q is not a number", value)
+	}
+	return n, nil
+}
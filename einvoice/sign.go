@@ -0,0 +1,123 @@
+package einvoice
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Thuật toán XML Signature dùng khi ký
+const (
+	xmldsigNS        = "http://www.w3.org/2000/09/xmldsig#"
+	algC14N          = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	algEnveloped     = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algSHA256        = "http://www.w3.org/2001/04/xmlenc#sha256"
+	signaturePointer = "<DSCKS><NBan></NBan></DSCKS>"
+)
+
+// Signer ký số hóa đơn điện tử bằng chứng thư số của người bán (RSA-SHA256, XML Signature dạng enveloped)
+type Signer struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// LoadSigner đọc chứng thư số và khoá bí mật RSA dạng PEM (khoá PKCS#1 hoặc PKCS#8)
+func LoadSigner(certFile, keyFile string) (*Signer, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("certificate file is not a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("key file is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key must be RSA")
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, err
+	}
+
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || pub.N.Cmp(key.N) != 0 {
+		return nil, errors.New("private key does not match certificate")
+	}
+	return &Signer{key: key, cert: cert}, nil
+}
+
+// Subject trả về chủ thể của chứng thư số
+func (s *Signer) Subject() string {
+	return s.cert.Subject.String()
+}
+
+// Sign ký phần dữ liệu hóa đơn (DLHDon) của XML do Document.XML tạo ra và chèn chữ ký vào DSCKS/NBan.
+// XML đã ở dạng chuẩn hoá C14N nên digest được tính trực tiếp trên chuỗi byte của DLHDon.
+func (s *Signer) Sign(doc []byte) ([]byte, error) {
+	start := bytes.Index(doc, []byte(`<DLHDon Id="`+DataID+`">`))
+	end := bytes.Index(doc, []byte("</DLHDon>"))
+	if start < 0 || end < start || bytes.Count(doc, []byte(signaturePointer)) != 1 {
+		return nil, errors.New("document is not an unsigned e-invoice")
+	}
+	digest := sha256.Sum256(doc[start : end+len("</DLHDon>")])
+
+	signedInfo := el("SignedInfo",
+		el("CanonicalizationMethod").attr("Algorithm", algC14N),
+		el("SignatureMethod").attr("Algorithm", algRSASHA256),
+		el("Reference",
+			el("Transforms",
+				el("Transform").attr("Algorithm", algEnveloped),
+				el("Transform").attr("Algorithm", algC14N),
+			),
+			el("DigestMethod").attr("Algorithm", algSHA256),
+			text("DigestValue", base64.StdEncoding.EncodeToString(digest[:])),
+		).attr("URI", "#"+DataID),
+	)
+
+	// Dạng C14N của SignedInfo mang theo namespace kế thừa từ phần tử Signature
+	canonical := &node{name: signedInfo.name, attrs: [][2]string{{"xmlns", xmldsigNS}}, children: signedInfo.children}
+	hashed := sha256.Sum256(canonical.bytes())
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return nil, fmt.Errorf("sign e-invoice: %w", err)
+	}
+
+	sig := el("Signature",
+		signedInfo,
+		text("SignatureValue", base64.StdEncoding.EncodeToString(signature)),
+		el("KeyInfo",
+			el("X509Data",
+				text("X509SubjectName", s.cert.Subject.String()),
+				text("X509Certificate", base64.StdEncoding.EncodeToString(s.cert.Raw)),
+			),
+		),
+	).attr("xmlns", xmldsigNS).attr("Id", "seller")
+
+	signed := "<DSCKS><NBan>" + string(sig.bytes()) + "</NBan></DSCKS>"
+	return bytes.Replace(doc, []byte(signaturePointer), []byte(signed), 1), nil
+}
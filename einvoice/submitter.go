@@ -0,0 +1,152 @@
+package einvoice
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// claimLease là thời gian giữ một thao tác khi đang gửi; tiến trình dừng giữa chừng thì thao tác được gửi lại sau đó
+const claimLease = 5 * time.Minute
+
+// Store lưu trạng thái gửi hóa đơn điện tử (repositories.EInvoiceRepository). Claim trả về mongo.ErrNoDocuments
+// khi không có thao tác đến hạn.
+type Store interface {
+	Claim(ctx context.Context, id *primitive.ObjectID, lease time.Duration) (*models.EInvoice, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.EInvoice, error)
+	RecordSuccess(ctx context.Context, id primitive.ObjectID, action string, result models.EInvoiceSuccess) error
+	RecordFailure(ctx context.Context, id primitive.ObjectID, action, message string, nextAttemptAt *time.Time) error
+	MarkReplaced(ctx context.Context, id, replacement primitive.ObjectID) error
+}
+
+// Submitter gửi các thao tác đang chờ (phát hành, thay thế, huỷ) tới nhà cung cấp, ghi nhận kết quả
+// và lên lịch gửi lại khi lỗi tạm thời với thời gian chờ tăng dần (1, 2, 4... phút, tối đa 1 giờ).
+type Submitter struct {
+	repo        Store
+	provider    Provider
+	maxAttempts int
+}
+
+func NewSubmitter(repo Store, provider Provider, maxAttempts int) *Submitter {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Submitter{repo: repo, provider: provider, maxAttempts: maxAttempts}
+}
+
+// Provider trả về nhà cung cấp đang dùng
+func (s *Submitter) Provider() Provider { return s.provider }
+
+// Process gửi ngay thao tác đang chờ của hóa đơn điện tử id nếu đã đến hạn.
+// Lỗi gửi không trả về mà được ghi vào bản ghi (lastError, submissions).
+func (s *Submitter) Process(ctx context.Context, id primitive.ObjectID) error {
+	record, err := s.repo.Claim(ctx, &id, claimLease)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.submit(ctx, record)
+}
+
+// Run gửi lại các thao tác đến hạn sau mỗi interval cho đến khi ctx bị huỷ
+func (s *Submitter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for ctx.Err() == nil {
+			record, err := s.repo.Claim(ctx, nil, claimLease)
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) {
+					log.Printf("⚠️ Không lấy được hóa đơn điện tử chờ gửi: %v\n", err)
+				}
+				break
+			}
+			if err := s.submit(ctx, record); err != nil {
+				log.Printf("⚠️ Không ghi được kết quả gửi hóa đơn điện tử %s: %v\n", record.ID.Hex(), err)
+			}
+		}
+	}
+}
+
+func (s *Submitter) submit(ctx context.Context, record *models.EInvoice) error {
+	action := record.PendingAction
+	req := IssueRequest{
+		Key:          record.ID.Hex(),
+		TemplateCode: record.TemplateCode,
+		Series:       record.Series,
+		Number:       record.Number,
+		XML:          []byte(record.XML),
+		Reason:       record.Reason,
+	}
+
+	var (
+		result   *Result
+		err      error
+		replaced *models.EInvoice
+	)
+	switch action {
+	case models.EInvoiceActionIssue:
+		result, err = s.provider.Issue(ctx, req)
+	case models.EInvoiceActionReplace:
+		if record.ReplacesID == nil {
+			err = &PermanentError{Message: "replacement has no original e-invoice"}
+			break
+		}
+		if replaced, err = s.repo.FindByID(ctx, *record.ReplacesID); err != nil {
+			break
+		}
+		result, err = s.provider.Replace(ctx, replaced.ProviderRef, req)
+	case models.EInvoiceActionCancel:
+		result, err = s.provider.Cancel(ctx, record.ProviderRef, record.Reason)
+	default:
+		err = &PermanentError{Message: "unknown action " + action}
+	}
+
+	if err != nil {
+		return s.repo.RecordFailure(ctx, record.ID, action, err.Error(), s.nextAttempt(record, err))
+	}
+
+	success := models.EInvoiceSuccess{
+		Status:           models.EInvoiceIssued,
+		Active:           true,
+		ProviderRef:      result.Ref,
+		TaxAuthorityCode: result.TaxAuthorityCode,
+		LookupCode:       result.LookupCode,
+		Message:          result.Message,
+	}
+	if action == models.EInvoiceActionCancel {
+		success.Status, success.Active = models.EInvoiceCancelled, false
+	}
+	if replaced != nil {
+		// Bỏ hiệu lực bản cũ trước để không vi phạm index một bản hiệu lực mỗi hóa đơn
+		if err := s.repo.MarkReplaced(ctx, replaced.ID, record.ID); err != nil {
+			return err
+		}
+	}
+	return s.repo.RecordSuccess(ctx, record.ID, action, success)
+}
+
+// nextAttempt trả về thời điểm gửi lại, nil nếu không gửi lại nữa
+func (s *Submitter) nextAttempt(record *models.EInvoice, err error) *time.Time {
+	if IsPermanent(err) || record.Attempts >= s.maxAttempts {
+		return nil
+	}
+	delay := time.Hour
+	if record.Attempts <= 6 {
+		delay = time.Duration(1<<(record.Attempts-1)) * time.Minute
+	}
+	next := time.Now().Add(delay)
+	return &next
+}
@@ -0,0 +1,449 @@
+package einvoice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memStore là Store trong bộ nhớ, giữ đúng điều kiện lọc của repositories.EInvoiceRepository
+type memStore struct {
+	mu      sync.Mutex
+	records map[primitive.ObjectID]*models.EInvoice
+}
+
+func newMemStore() *memStore {
+	return &memStore{records: map[primitive.ObjectID]*models.EInvoice{}}
+}
+
+func (s *memStore) add(record models.EInvoice) primitive.ObjectID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	record.ID = primitive.NewObjectID()
+	record.SubmissionStatus = models.EInvoicePending
+	record.NextAttemptAt = &now
+	s.records[record.ID] = &record
+	return record.ID
+}
+
+func (s *memStore) get(t *testing.T, id primitive.ObjectID) models.EInvoice {
+	t.Helper()
+	record, err := s.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("record %s not found", id.Hex())
+	}
+	return *record
+}
+
+func (s *memStore) Claim(ctx context.Context, id *primitive.ObjectID, lease time.Duration) (*models.EInvoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, record := range s.records {
+		if id != nil && record.ID != *id {
+			continue
+		}
+		if record.PendingAction == "" || record.NextAttemptAt == nil || record.NextAttemptAt.After(now) {
+			continue
+		}
+		next := now.Add(lease)
+		record.NextAttemptAt = &next
+		record.Attempts++
+		copied := *record
+		return &copied, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (s *memStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.EInvoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (s *memStore) RecordSuccess(ctx context.Context, id primitive.ObjectID, action string, result models.EInvoiceSuccess) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[id]
+	if record == nil || record.PendingAction != action {
+		return nil
+	}
+	record.SubmissionStatus, record.Active, record.Attempts = result.Status, result.Active, 0
+	if result.ProviderRef != "" {
+		record.ProviderRef = result.ProviderRef
+	}
+	if result.TaxAuthorityCode != "" {
+		record.TaxAuthorityCode = result.TaxAuthorityCode
+	}
+	if result.LookupCode != "" {
+		record.LookupCode = result.LookupCode
+	}
+	record.PendingAction, record.NextAttemptAt, record.LastError = "", nil, ""
+	record.Submissions = append(record.Submissions, models.EInvoiceSubmission{Action: action, At: time.Now(), Success: true, Message: result.Message})
+	return nil
+}
+
+func (s *memStore) RecordFailure(ctx context.Context, id primitive.ObjectID, action, message string, nextAttemptAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[id]
+	if record == nil || record.PendingAction != action {
+		return nil
+	}
+	record.Submissions = append(record.Submissions, models.EInvoiceSubmission{Action: action, At: time.Now(), Message: message})
+	record.LastError, record.NextAttemptAt = message, nextAttemptAt
+	if nextAttemptAt == nil && record.SubmissionStatus == models.EInvoicePending {
+		record.SubmissionStatus = models.EInvoiceFailed
+	}
+	return nil
+}
+
+func (s *memStore) MarkReplaced(ctx context.Context, id, replacement primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[id]
+	record.SubmissionStatus, record.Active, record.ReplacedBy = models.EInvoiceReplaced, false, &replacement
+	return nil
+}
+
+// requestCancel giống EInvoiceRepository.RequestCancel
+func (s *memStore) requestCancel(id primitive.ObjectID, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	record := s.records[id]
+	record.PendingAction, record.Reason, record.Attempts, record.NextAttemptAt = models.EInvoiceActionCancel, reason, 0, &now
+}
+
+// retry giống EInvoiceRepository.Retry
+func (s *memStore) retry(id primitive.ObjectID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	record := s.records[id]
+	record.Attempts, record.NextAttemptAt = 0, &now
+	if record.SubmissionStatus == models.EInvoiceFailed {
+		record.SubmissionStatus = models.EInvoicePending
+	}
+}
+
+// makeDue đưa lần gửi lại về thời điểm hiện tại để không phải chờ backoff
+func (s *memStore) makeDue(id primitive.ObjectID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.records[id].NextAttemptAt = &now
+}
+
+// testSigner tạo chứng thư số tự ký để ký XML trong test
+func testSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Cửa hàng Test", SerialNumber: "MST:0101234567"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := LoadSigner(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+var testSetting = models.StoreSetting{
+	StoreName:        "Cửa hàng Test",
+	TaxCode:          "0101234567",
+	Address:          "1 Tràng Tiền, Hà Nội",
+	EInvoiceTemplate: "1",
+	EInvoiceSeries:   "C25TAA",
+	DefaultVATRate:   "10%",
+}
+
+// signedRecord lập, ký và kiểm tra hóa đơn điện tử như EInvoiceController, trả về bản ghi chưa lưu
+func signedRecord(t *testing.T, signer *Signer, number int64, replaces *Reference) models.EInvoice {
+	t.Helper()
+	invoice := models.Invoice{
+		ID:   primitive.NewObjectID(),
+		Code: "HD0001",
+		Items: []models.InvoiceItem{
+			{Name: "Cà phê", Quantity: 2, Price: 55000, VATRate: "8%"},
+			{Name: "Bánh ngọt", Quantity: 1, Price: 33000},
+		},
+		Discount: 10000,
+	}
+	doc, err := Build(invoice, testSetting, models.EInvoiceBuyer{Name: "Công ty TNHH ABC", TaxCode: "0101234568"}, number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Replaces = replaces
+	if err := Validate(doc.XML(), false); err != nil {
+		t.Fatalf("unsigned XML: %v", err)
+	}
+	signed, err := signer.Sign(doc.XML())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(signed, true); err != nil {
+		t.Fatalf("signed XML: %v", err)
+	}
+	return models.EInvoice{
+		InvoiceID:    invoice.ID,
+		TemplateCode: doc.TemplateCode,
+		Series:       doc.Series,
+		Number:       doc.Number,
+		IssuedAt:     doc.IssueDate,
+		XML:          string(signed),
+	}
+}
+
+// issued lưu và phát hành một hóa đơn điện tử, trả về ID bản ghi
+func issued(t *testing.T, store *memStore, submitter *Submitter, signer *Signer) primitive.ObjectID {
+	t.Helper()
+	record := signedRecord(t, signer, 1, nil)
+	record.PendingAction = models.EInvoiceActionIssue
+	id := store.add(record)
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.get(t, id); got.SubmissionStatus != models.EInvoiceIssued {
+		t.Fatalf("status = %s (%s), want issued", got.SubmissionStatus, got.LastError)
+	}
+	return id
+}
+
+func TestSubmitterIssue(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 5)
+	id := issued(t, store, submitter, signer)
+
+	record := store.get(t, id)
+	if !record.Active || record.PendingAction != "" || record.NextAttemptAt != nil {
+		t.Fatalf("issued record = %+v, want active without pending action", record)
+	}
+	if record.ProviderRef == "" || record.TaxAuthorityCode == "" || record.LookupCode == "" {
+		t.Fatalf("provider codes missing: %+v", record)
+	}
+	if len(record.Submissions) != 1 || !record.Submissions[0].Success {
+		t.Fatalf("submissions = %+v, want one successful", record.Submissions)
+	}
+	status, err := provider.Status(context.Background(), record.ProviderRef)
+	if err != nil || status.Status != StatusIssued {
+		t.Fatalf("provider status = %+v, %v", status, err)
+	}
+
+	// Không còn thao tác chờ -> gọi lại không gửi nữa
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.get(t, id); len(got.Submissions) != 1 {
+		t.Fatalf("processed twice: %+v", got.Submissions)
+	}
+}
+
+func TestSubmitterReplace(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 5)
+	originalID := issued(t, store, submitter, signer)
+	original := store.get(t, originalID)
+
+	replacement := signedRecord(t, signer, 2, &Reference{
+		TemplateCode: original.TemplateCode,
+		Series:       original.Series,
+		Number:       original.Number,
+		IssueDate:    original.IssuedAt,
+		Note:         "Sai mã số thuế người mua",
+	})
+	replacement.ReplacesID = &originalID
+	replacement.PendingAction = models.EInvoiceActionReplace
+	replacementID := store.add(replacement)
+	if err := submitter.Process(context.Background(), replacementID); err != nil {
+		t.Fatal(err)
+	}
+
+	got := store.get(t, replacementID)
+	if got.SubmissionStatus != models.EInvoiceIssued || !got.Active || got.ProviderRef == original.ProviderRef {
+		t.Fatalf("replacement = %+v, want a new active issued e-invoice", got)
+	}
+	old := store.get(t, originalID)
+	if old.SubmissionStatus != models.EInvoiceReplaced || old.Active || old.ReplacedBy == nil || *old.ReplacedBy != replacementID {
+		t.Fatalf("original = %+v, want replaced by %s", old, replacementID.Hex())
+	}
+	status, err := provider.Status(context.Background(), original.ProviderRef)
+	if err != nil || status.Status != StatusReplaced {
+		t.Fatalf("provider status of original = %+v, %v", status, err)
+	}
+}
+
+func TestSubmitterCancel(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 5)
+	id := issued(t, store, submitter, signer)
+
+	store.requestCancel(id, "Khách huỷ giao dịch")
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	record := store.get(t, id)
+	if record.SubmissionStatus != models.EInvoiceCancelled || record.Active || record.PendingAction != "" {
+		t.Fatalf("record = %+v, want cancelled", record)
+	}
+	status, err := provider.Status(context.Background(), record.ProviderRef)
+	if err != nil || status.Status != StatusCancelled {
+		t.Fatalf("provider status = %+v, %v", status, err)
+	}
+}
+
+func TestSubmitterRetryTransientError(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 5)
+	provider.Fail = func(string) error { return errors.New("provider timeout") }
+
+	record := signedRecord(t, signer, 1, nil)
+	record.PendingAction = models.EInvoiceActionIssue
+	id := store.add(record)
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	got := store.get(t, id)
+	if got.SubmissionStatus != models.EInvoicePending || got.LastError != "provider timeout" {
+		t.Fatalf("record = %+v, want pending with lastError", got)
+	}
+	if got.NextAttemptAt == nil || !got.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("nextAttemptAt = %v, want a scheduled retry", got.NextAttemptAt)
+	}
+
+	// Chưa đến hạn -> không gửi
+	provider.Fail = nil
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.get(t, id); got.SubmissionStatus != models.EInvoicePending {
+		t.Fatalf("sent before the retry was due: %+v", got)
+	}
+
+	store.makeDue(id)
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	got = store.get(t, id)
+	if got.SubmissionStatus != models.EInvoiceIssued || got.LastError != "" || len(got.Submissions) != 2 {
+		t.Fatalf("record = %+v, want issued after one failed submission", got)
+	}
+}
+
+func TestSubmitterStopsAfterMaxAttempts(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 2)
+	provider.Fail = func(string) error { return errors.New("provider busy") }
+
+	record := signedRecord(t, signer, 1, nil)
+	record.PendingAction = models.EInvoiceActionIssue
+	id := store.add(record)
+	for i := 0; i < 2; i++ {
+		store.makeDue(id)
+		if err := submitter.Process(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := store.get(t, id)
+	if got.SubmissionStatus != models.EInvoiceFailed || got.NextAttemptAt != nil || got.PendingAction != models.EInvoiceActionIssue {
+		t.Fatalf("record = %+v, want failed and stopped with the action kept", got)
+	}
+}
+
+func TestSubmitterManualRetryAfterRejection(t *testing.T) {
+	store, provider, signer := newMemStore(), NewMockProvider(), testSigner(t)
+	submitter := NewSubmitter(store, provider, 5)
+	provider.Fail = func(string) error { return &PermanentError{Message: "rejected: invalid buyer"} }
+
+	record := signedRecord(t, signer, 1, nil)
+	record.PendingAction = models.EInvoiceActionIssue
+	id := store.add(record)
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	got := store.get(t, id)
+	if got.SubmissionStatus != models.EInvoiceFailed || got.NextAttemptAt != nil {
+		t.Fatalf("record = %+v, want failed without automatic retry", got)
+	}
+
+	provider.Fail = nil
+	store.retry(id)
+	if err := submitter.Process(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.get(t, id); got.SubmissionStatus != models.EInvoiceIssued || !got.Active {
+		t.Fatalf("record = %+v, want issued after manual retry", got)
+	}
+}
+
+func TestBuildRejectsDiscountOnZeroTotal(t *testing.T) {
+	invoice := models.Invoice{Items: []models.InvoiceItem{{Name: "Quà tặng", Quantity: 1, Price: 0}}, Discount: 1000}
+	if _, err := Build(invoice, testSetting, models.EInvoiceBuyer{}, 1); err == nil {
+		t.Fatal("Build accepted a discount larger than the invoice total")
+	}
+}
+
+func TestValidateRejectsSchemaViolations(t *testing.T) {
+	signer := testSigner(t)
+	record := signedRecord(t, signer, 1, nil)
+	doc := []byte(record.XML)
+
+	cases := map[string][]byte{
+		"bad series":      replaceOnce(doc, "<KHHDon>C25TAA</KHHDon>", "<KHHDon>X25TAA</KHHDon>"),
+		"missing element": replaceOnce(doc, "<DVTTe>VND</DVTTe>", ""),
+		"unknown element": replaceOnce(doc, "<TGia>1</TGia>", "<TGia>1</TGia><Foo>1</Foo>"),
+		"bad tax code":    replaceOnce(doc, "<MST>0101234567</MST>", "<MST>12345</MST>"),
+	}
+	for name, invalid := range cases {
+		if err := Validate(invalid, false); err == nil {
+			t.Errorf("%s: Validate accepted invalid XML", name)
+		}
+	}
+}
+
+func replaceOnce(doc []byte, old, new string) []byte {
+	if !strings.Contains(string(doc), old) {
+		panic("replaceOnce: " + old + " not found")
+	}
+	return []byte(strings.Replace(string(doc), old, new, 1))
+}
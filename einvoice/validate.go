@@ -0,0 +1,194 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValidationError liệt kê các lỗi cấu trúc của XML hóa đơn điện tử
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid e-invoice: " + strings.Join(e.Problems, "; ")
+}
+
+// xmlInvoice ánh xạ các phần tử của định dạng hóa đơn điện tử dùng để kiểm tra
+type xmlInvoice struct {
+	XMLName xml.Name `xml:"HDon"`
+	Data    struct {
+		ID      string `xml:"Id,attr"`
+		General struct {
+			Version       string `xml:"PBan"`
+			Title         string `xml:"THDon"`
+			Template      string `xml:"KHMSHDon"`
+			Series        string `xml:"KHHDon"`
+			Number        string `xml:"SHDon"`
+			IssueDate     string `xml:"NLap"`
+			Currency      string `xml:"DVTTe"`
+			ExchangeRate  string `xml:"TGia"`
+			PaymentMethod string `xml:"HTTToan"`
+			Related       *struct {
+				Kind      string `xml:"TCHDon"`
+				Type      string `xml:"LHDCLQuan"`
+				Template  string `xml:"KHMSHDCLQuan"`
+				Series    string `xml:"KHHDCLQuan"`
+				Number    string `xml:"SHDCLQuan"`
+				IssueDate string `xml:"NLHDCLQuan"`
+				Note      string `xml:"GChu"`
+			} `xml:"TTHDLQuan"`
+		} `xml:"TTChung"`
+		Content struct {
+			Seller xmlParty `xml:"NBan"`
+			Buyer  xmlParty `xml:"NMua"`
+			Lines  []struct {
+				Kind      string `xml:"TChat"`
+				No        string `xml:"STT"`
+				Name      string `xml:"THHDVu"`
+				Quantity  string `xml:"SLuong"`
+				UnitPrice string `xml:"DGia"`
+				Amount    string `xml:"ThTien"`
+				TaxRate   string `xml:"TSuat"`
+			} `xml:"DSHHDVu>HHDVu"`
+			Totals struct {
+				Groups []struct {
+					TaxRate string `xml:"TSuat"`
+					Amount  string `xml:"ThTien"`
+					Tax     string `xml:"TThue"`
+				} `xml:"THTTLTSuat>LTSuat"`
+				Amount   string `xml:"TgTCThue"`
+				Tax      string `xml:"TgTThue"`
+				Discount string `xml:"TTCKTMai"`
+				Payment  string `xml:"TgTTTBSo"`
+				InWords  string `xml:"TgTTTBChu"`
+			} `xml:"TToan"`
+		} `xml:"NDHDon"`
+	} `xml:"DLHDon"`
+	Signatures struct {
+		Seller struct {
+			Inner []byte `xml:",innerxml"`
+		} `xml:"NBan"`
+	} `xml:"DSCKS"`
+}
+
+type xmlParty struct {
+	Name      string `xml:"Ten"`
+	TaxCode   string `xml:"MST"`
+	Address   string `xml:"DChi"`
+	Email     string `xml:"DCTDTu"`
+	BuyerName string `xml:"HVTNMHang"`
+}
+
+// Validate kiểm tra XML hóa đơn điện tử: trước hết theo lược đồ schema/HDon.xsd (thứ tự và số lần xuất hiện
+// của phần tử, mã số thuế, ký hiệu, ngày, thuế suất, độ dài tối đa...), sau đó các ràng buộc nghiệp vụ mà
+// lược đồ không diễn tả được: số thứ tự dòng, thành tiền từng dòng và các tổng tiền phải khớp nhau.
+// signed = true yêu cầu đã có chữ ký số của người bán.
+func Validate(doc []byte, signed bool) error {
+	if problems := hdonSchema.validate(doc); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	var inv xmlInvoice
+	if err := xml.Unmarshal(doc, &inv); err != nil {
+		return &ValidationError{Problems: []string{"malformed XML: " + err.Error()}}
+	}
+	v := &validator{}
+	data := &inv.Data
+	general := &data.General
+
+	if related := general.Related; related != nil {
+		v.check(related.Template != general.Template || related.Series != general.Series || related.Number != general.Number,
+			"an e-invoice cannot replace itself")
+	}
+
+	buyer := &data.Content.Buyer
+	v.check(buyer.Name != "" || buyer.BuyerName != "", "NMua/Ten or NMua/HVTNMHang is required")
+	v.check(buyer.TaxCode == "" || buyer.Name != "", "NMua/Ten is required when NMua/MST is set")
+
+	// Dòng hàng hóa: cộng tiền chưa thuế theo thuế suất để đối chiếu với bảng tổng hợp
+	lines := data.Content.Lines
+	lineTotals := map[string]float64{}
+	lineCounts := map[string]int{}
+	for i, line := range lines {
+		field := fmt.Sprintf("HHDVu[%d]", i+1)
+		v.check(line.No == strconv.Itoa(i+1), "%s/STT must be %d", field, i+1)
+		lineAmount := v.number(field+"/ThTien", line.Amount)
+		switch line.Kind {
+		case strconv.Itoa(LineGoods):
+			quantity := v.number(field+"/SLuong", line.Quantity)
+			price := v.number(field+"/DGia", line.UnitPrice)
+			v.check(quantity > 0, "%s/SLuong must be positive", field)
+			// Đơn giá làm tròn 2 chữ số nên cho phép lệch tối đa 1đ mỗi đơn vị
+			v.check(math.Abs(quantity*price-lineAmount) <= math.Max(1, quantity*0.01), "%s/ThTien does not match SLuong x DGia", field)
+			lineTotals[line.TaxRate] += lineAmount
+		case strconv.Itoa(LineDiscount):
+			lineTotals[line.TaxRate] -= lineAmount
+		default:
+			v.fail("%s/TChat must be %d or %d", field, LineGoods, LineDiscount)
+		}
+		lineCounts[line.TaxRate]++
+	}
+
+	totals := &data.Content.Totals
+	var sumAmount, sumTax float64
+	for _, group := range totals.Groups {
+		field := "LTSuat[" + group.TaxRate + "]"
+		rate := TaxRates[group.TaxRate]
+		groupAmount := v.number(field+"/ThTien", group.Amount)
+		groupTax := v.number(field+"/TThue", group.Tax)
+		v.check(groupAmount == lineTotals[group.TaxRate], "%s/ThTien does not match the sum of its lines", field)
+		// Tiền thuế tách ngược từ giá gồm thuế: lệch do làm tròn tối đa 1đ mỗi dòng
+		v.check(math.Abs(groupTax-groupAmount*rate) <= float64(lineCounts[group.TaxRate]), "%s/TThue does not match the tax rate", field)
+		delete(lineTotals, group.TaxRate)
+		sumAmount += groupAmount
+		sumTax += groupTax
+	}
+	for rate := range lineTotals {
+		v.fail("tax rate %s is used by a line but missing from THTTLTSuat", rate)
+	}
+
+	amountTotal := v.number("TgTCThue", totals.Amount)
+	taxTotal := v.number("TgTThue", totals.Tax)
+	payment := v.number("TgTTTBSo", totals.Payment)
+	v.check(amountTotal == sumAmount, "TgTCThue must equal the sum of LTSuat/ThTien")
+	v.check(taxTotal == sumTax, "TgTThue must equal the sum of LTSuat/TThue")
+	v.check(payment == amountTotal+taxTotal, "TgTTTBSo must equal TgTCThue + TgTThue")
+
+	if signed {
+		v.check(strings.Contains(string(inv.Signatures.Seller.Inner), "<SignatureValue>"), "DSCKS/NBan must contain the seller signature")
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator gom các lỗi kiểm tra
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.fail(format, args...)
+	}
+}
+
+// number đọc một giá trị số bắt buộc, không âm (định dạng đã được lược đồ kiểm tra, chỉ còn phần tử không bắt
+// buộc trong lược đồ như SLuong, DGia có thể thiếu)
+func (v *validator) number(field, value string) float64 {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		v.fail("%s must be a non-negative number", field)
+		return 0
+	}
+	return n
+}
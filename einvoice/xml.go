@@ -0,0 +1,175 @@
+package einvoice
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// DataID là Id của phần dữ liệu hóa đơn (DLHDon), được chữ ký số tham chiếu qua URI "#data"
+const DataID = "data"
+
+// node là một phần tử XML. XML được ghi ở dạng chuẩn hoá C14N (không khai báo XML, không khoảng trắng thừa,
+// phần tử rỗng ghi đủ thẻ mở/đóng) để có thể tính digest trực tiếp trên chuỗi byte khi ký.
+type node struct {
+	name     string
+	attrs    [][2]string // Theo đúng thứ tự C14N: khai báo namespace trước, sau đó thuộc tính theo tên
+	text     string
+	children []*node
+}
+
+func el(name string, children ...*node) *node {
+	return &node{name: name, children: children}
+}
+
+func text(name, value string) *node {
+	return &node{name: name, text: value}
+}
+
+// optional trả về nil nếu giá trị rỗng để bỏ qua phần tử không bắt buộc
+func optional(name, value string) *node {
+	if value == "" {
+		return nil
+	}
+	return text(name, value)
+}
+
+func (n *node) attr(name, value string) *node {
+	n.attrs = append(n.attrs, [2]string{name, value})
+	return n
+}
+
+func (n *node) write(buf *bytes.Buffer) {
+	buf.WriteString("<" + n.name)
+	for _, a := range n.attrs {
+		buf.WriteString(" " + a[0] + `="` + escapeAttr(a[1]) + `"`)
+	}
+	buf.WriteString(">")
+	buf.WriteString(escapeText(n.text))
+	for _, child := range n.children {
+		if child != nil {
+			child.write(buf)
+		}
+	}
+	buf.WriteString("</" + n.name + ">")
+}
+
+func (n *node) bytes() []byte {
+	var buf bytes.Buffer
+	n.write(&buf)
+	return buf.Bytes()
+}
+
+// escapeText và escapeAttr escape theo quy tắc của C14N
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
+
+// amount ghi số tiền VND không có phần thập phân
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+// decimal ghi số lượng, đơn giá (tối đa 2 chữ số thập phân, bỏ số 0 thừa)
+func decimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// XML xuất hóa đơn điện tử chưa ký. Phần ký số (DSCKS/NBan) để trống, được điền bởi Signer.Sign.
+func (d *Document) XML() []byte {
+	lines := el("DSHHDVu")
+	for _, line := range d.Lines {
+		item := el("HHDVu",
+			text("TChat", strconv.Itoa(line.Kind)),
+			text("STT", strconv.Itoa(line.No)),
+			text("THHDVu", line.Name),
+		)
+		if line.Kind == LineGoods {
+			item.children = append(item.children,
+				optional("DVTinh", line.Unit),
+				text("SLuong", decimal(line.Quantity)),
+				text("DGia", decimal(line.UnitPrice)),
+			)
+		}
+		item.children = append(item.children,
+			text("ThTien", amount(line.Amount)),
+			text("TSuat", line.TaxRate),
+		)
+		lines.children = append(lines.children, item)
+	}
+
+	taxGroups := el("THTTLTSuat")
+	for _, group := range d.TaxGroups {
+		taxGroups.children = append(taxGroups.children, el("LTSuat",
+			text("TSuat", group.TaxRate),
+			text("ThTien", amount(group.Amount)),
+			text("TThue", amount(group.Tax)),
+		))
+	}
+
+	buyerName := d.Buyer.Name
+	if buyerName == "" && d.Buyer.BuyerName == "" {
+		buyerName = "Người mua không lấy hóa đơn"
+	}
+
+	var related *node
+	if ref := d.Replaces; ref != nil {
+		related = el("TTHDLQuan",
+			text("TCHDon", "1"),    // 1: thay thế
+			text("LHDCLQuan", "1"), // 1: hóa đơn điện tử theo Nghị định 123/2020
+			text("KHMSHDCLQuan", ref.TemplateCode),
+			text("KHHDCLQuan", ref.Series),
+			text("SHDCLQuan", strconv.FormatInt(ref.Number, 10)),
+			text("NLHDCLQuan", ref.IssueDate.Format("2006-01-02")),
+			optional("GChu", ref.Note),
+		)
+	}
+
+	data := el("DLHDon",
+		el("TTChung",
+			text("PBan", SchemaVersion),
+			text("THDon", "Hóa đơn giá trị gia tăng"),
+			text("KHMSHDon", d.TemplateCode),
+			text("KHHDon", d.Series),
+			text("SHDon", strconv.FormatInt(d.Number, 10)),
+			text("NLap", d.IssueDate.Format("2006-01-02")),
+			text("DVTTe", "VND"),
+			text("TGia", "1"),
+			text("HTTToan", d.PaymentMethod),
+			related,
+		),
+		el("NDHDon",
+			el("NBan",
+				text("Ten", d.Seller.Name),
+				text("MST", d.Seller.TaxCode),
+				text("DChi", d.Seller.Address),
+				optional("SDThoai", d.Seller.Phone),
+				optional("DCTDTu", d.Seller.Email),
+			),
+			el("NMua",
+				optional("Ten", buyerName),
+				optional("MST", d.Buyer.TaxCode),
+				optional("DChi", d.Buyer.Address),
+				optional("SDThoai", d.Buyer.Phone),
+				optional("DCTDTu", d.Buyer.Email),
+				optional("HVTNMHang", d.Buyer.BuyerName),
+			),
+			lines,
+			el("TToan",
+				taxGroups,
+				text("TgTCThue", amount(d.TotalAmount)),
+				text("TgTThue", amount(d.TotalTax)),
+				text("TTCKTMai", amount(d.TotalDiscount)),
+				text("TgTTTBSo", amount(d.TotalPayment)),
+				text("TgTTTBChu", d.AmountInWords),
+			),
+		),
+	).attr("Id", DataID)
+
+	root := el("HDon", data, el("DSCKS", el("NBan")))
+	return append([]byte(`<?xml version="1.0" encoding="UTF-8"?>`), root.bytes()...)
+}
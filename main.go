@@ -29,7 +29,14 @@ func main() {
 	seed.SeedAdminUser()
 	seed.SeedStoreSettings()
 
-	app := fiber.New()
+	// IP thật của client sau reverse proxy (giới hạn IP của API key, chống dò mật khẩu, nhật ký): chỉ đọc
+	// PROXY_HEADER khi request đến từ TRUSTED_PROXIES, request khác dùng IP của kết nối TCP
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             os.Getenv("PROXY_HEADER"),
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          config.GetEnvList("TRUSTED_PROXIES"),
+		EnableIPValidation:      true,
+	})
 	app.Use(recover.New())        // Bắt panic để tránh server bị crash
 	app.Use(cors.New())
 	routes.Setup(app, config.DB)
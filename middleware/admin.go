@@ -1,31 +1,28 @@
 package middleware
 
 import (
+	"go-fiber-api/models"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
 )
 
 // AdminOnly ensures that the request is authenticated and the user role is admin.
+// Route nghiệp vụ dùng Authorizer.Require; AdminOnly chỉ dành cho thao tác luôn cần vai trò admin
+// (đăng nhập bằng mật khẩu, không nhận phiên PIN hay API key).
 func AdminOnly() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userToken, ok := c.Locals("user").(*jwt.Token)
-		if !ok || userToken == nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Invalid or missing JWT",
-				"data":    nil,
-			})
+		if CurrentAPIKey(c) != nil {
+			return apiKeyForbidden(c)
 		}
-		claims, ok := userToken.Claims.(jwt.MapClaims)
-		if !ok {
+		role := CurrentRole(c)
+		if role == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"status":  "error",
-				"message": "Invalid token claims",
+				"message": "Invalid or missing JWT",
 				"data":    nil,
 			})
 		}
-		role, _ := claims["role"].(string)
-		if role != "admin" {
+		if role != models.RoleAdmin || Claims(c).Method == models.AuthPIN {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Forbidden",
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// authenticateAPIKey xác thực request của tích hợp gửi header X-API-Key: khoá còn hiệu lực, IP được phép.
+// Request được gắn claims với Method = apikey (UserID là ID của khoá, không có vai trò / phiên)
+// và khoá được lưu vào c.Locals("apiKey"), đọc qua CurrentAPIKey().
+func authenticateAPIKey(c *fiber.Ctx, apiKeys *repositories.APIKeyRepository, raw string) error {
+	key, err := apiKeys.FindActive(c.Context(), utils.HashToken(raw))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(fiber.StatusUnauthorized).
+			JSON(fiber.Map{"status": "error", "message": "Invalid, expired or revoked API key", "data": nil})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).
+			JSON(fiber.Map{"status": "error", "message": "API key check failed", "data": nil})
+	}
+	if !key.AllowsIP(c.IP()) {
+		return c.Status(fiber.StatusForbidden).
+			JSON(fiber.Map{"status": "error", "message": "API key is not allowed from this IP", "data": nil})
+	}
+	if err := apiKeys.Touch(c.Context(), key.ID, c.IP()); err != nil {
+		log.Printf("⚠️ Không ghi được lần dùng API key %s: %v\n", key.Prefix, err)
+	}
+	c.Locals("user", &jwt.Token{Valid: true, Claims: &utils.TokenClaims{UserID: key.ID.Hex(), Method: models.AuthAPIKey}})
+	c.Locals("apiKey", key)
+	return c.Next()
+}
+
+// CurrentAPIKey lấy API key của request đã được Protected() xác thực, nil nếu request dùng JWT
+func CurrentAPIKey(c *fiber.Ctx) *models.APIKey {
+	key, _ := c.Locals("apiKey").(*models.APIKey)
+	return key
+}
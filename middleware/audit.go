@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"go-fiber-api/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditTrail gắn repository nhật ký thao tác vào request để controller ghi qua AuditLog(c).
+// Nếu thao tác đã thực hiện nhưng không ghi được nhật ký (MarkAuditFailed), response được thay bằng lỗi 500
+// để client và người vận hành biết thay đổi này chưa có dấu vết. Phải đặt trước Idempotency: response đã lưu
+// vẫn là kết quả gốc, gửi lại cùng Idempotency-Key không thực hiện lại thao tác.
+func AuditTrail(repo *repositories.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("auditLog", repo)
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if failed, _ := c.Locals("auditFailed").(bool); !failed {
+			return nil
+		}
+		c.Response().ResetBody()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The change was saved but could not be written to the audit log, contact an administrator",
+			"data":    fiber.Map{"auditFailed": true},
+		})
+	}
+}
+
+// AuditLog trả về repository nhật ký thao tác gắn bởi AuditTrail, nil nếu route không đi qua AuditTrail
+func AuditLog(c *fiber.Ctx) *repositories.AuditRepository {
+	repo, _ := c.Locals("auditLog").(*repositories.AuditRepository)
+	return repo
+}
+
+// MarkAuditFailed đánh dấu request có thao tác không ghi được nhật ký
+func MarkAuditFailed(c *fiber.Ctx) {
+	c.Locals("auditFailed", true)
+}
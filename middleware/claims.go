@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"go-fiber-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Claims lấy nội dung access token đã được Protected() xác thực. Mọi controller đọc thông tin người dùng
+// hiện tại qua hàm này (hoặc các hàm Current* bên dưới), không tự parse header Authorization.
+// Trả về nil nếu request không có token hợp lệ.
+func Claims(c *fiber.Ctx) *utils.TokenClaims {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok || token == nil {
+		return nil
+	}
+	claims, _ := token.Claims.(*utils.TokenClaims)
+	return claims
+}
+
+// CurrentUserID lấy ID người dùng từ claim "id" của JWT đã được Protected() xác thực.
+// Trả về chuỗi rỗng nếu request không có token hợp lệ.
+func CurrentUserID(c *fiber.Ctx) string {
+	if claims := Claims(c); claims != nil {
+		return claims.UserID
+	}
+	return ""
+}
+
+// CurrentRole lấy vai trò người dùng từ claim "role" của JWT, chuỗi rỗng nếu không có token hợp lệ
+func CurrentRole(c *fiber.Ctx) string {
+	if claims := Claims(c); claims != nil {
+		return claims.Role
+	}
+	return ""
+}
+
+// CurrentSessionID lấy ID phiên đăng nhập từ claim "sid" của JWT
+func CurrentSessionID(c *fiber.Ctx) string {
+	if claims := Claims(c); claims != nil {
+		return claims.SessionID
+	}
+	return ""
+}
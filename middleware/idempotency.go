@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go-fiber-api/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Idempotency cho phép client gửi lại request an toàn với cùng header Idempotency-Key:
+// lần đầu xử lý bình thường và lưu response, các lần sau phát lại đúng response đó.
+// Key bị dùng lại với body khác -> 422, request đầu còn đang xử lý -> 409.
+func Idempotency(repo *repositories.IdempotencyRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Idempotency-Key is too long",
+				"data":    nil,
+			})
+		}
+
+		// Key gắn theo user để các máy POS khác nhau không đụng nhau
+		id := CurrentUserID(c) + ":" + key
+		sum := sha256.Sum256([]byte(c.Method() + " " + c.Path() + "\n" + string(c.Body())))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		existing, created, err := repo.Reserve(c.Context(), id, fingerprint)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Idempotency check failed",
+				"data":    nil,
+			})
+		}
+		if !created {
+			if existing.Fingerprint != fingerprint {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Idempotency-Key was already used with a different request",
+					"data":    nil,
+				})
+			}
+			if !existing.Completed {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"status":  "error",
+					"message": "A request with this Idempotency-Key is still being processed",
+					"data":    nil,
+				})
+			}
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, existing.ContentType)
+			return c.Status(existing.StatusCode).Send(existing.Response)
+		}
+
+		if err := c.Next(); err != nil {
+			_ = repo.Release(c.Context(), id)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			_ = repo.Release(c.Context(), id)
+			return nil
+		}
+		response := append([]byte(nil), c.Response().Body()...)
+		contentType := string(c.Response().Header.ContentType())
+		_ = repo.Complete(c.Context(), id, status, contentType, response)
+		return nil
+	}
+}
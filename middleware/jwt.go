@@ -1,23 +1,54 @@
 package middleware
 
 import (
-	"os"
+	"strings"
+
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
 
 	"github.com/gofiber/fiber/v2"
-	jwtware "github.com/gofiber/jwt/v3"
 )
 
-func Protected() fiber.Handler {
-	return jwtware.New(jwtware.Config{
-		SigningKey:   []byte(os.Getenv("JWT_SECRET")),
-		ErrorHandler: jwtError,
-	})
-}
-func jwtError(c *fiber.Ctx, err error) error {
-	if err.Error() == "Missing or malformed JWT" {
-		return c.Status(fiber.StatusBadRequest).
-			JSON(fiber.Map{"status": "error", "message": "Missing or malformed JWT", "data": nil})
+// Protected xác thực access token (chữ ký theo kid, hạn, issuer) và kiểm tra phiên đăng nhập (claim "sid")
+// chưa bị thu hồi: đăng xuất, đổi mật khẩu, đổi vai trò hoặc xoá người dùng làm token hết hiệu lực ngay.
+// Token hợp lệ được lưu vào c.Locals("user"), đọc qua Claims().
+// Request có header X-API-Key được xác thực bằng API key thay cho JWT.
+func Protected(keys *utils.KeyRing, sessions *repositories.SessionRepository, apiKeys *repositories.APIKeyRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if raw := c.Get(models.HeaderAPIKey); raw != "" {
+			return authenticateAPIKey(c, apiKeys, raw)
+		}
+		header := c.Get(fiber.HeaderAuthorization)
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenString == "" {
+			return c.Status(fiber.StatusBadRequest).
+				JSON(fiber.Map{"status": "error", "message": "Missing or malformed JWT", "data": nil})
+		}
+		token, err := keys.Parse(tokenString, utils.TokenAccess)
+		if err != nil {
+			return jwtError(c)
+		}
+		c.Locals("user", token)
+
+		sid := CurrentSessionID(c)
+		if sid == "" {
+			return jwtError(c)
+		}
+		active, err := sessions.IsActive(c.Context(), sid)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(fiber.Map{"status": "error", "message": "Session check failed", "data": nil})
+		}
+		if !active {
+			return c.Status(fiber.StatusUnauthorized).
+				JSON(fiber.Map{"status": "error", "message": "Session has been revoked", "data": nil})
+		}
+		return c.Next()
 	}
+}
+
+func jwtError(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusUnauthorized).
 		JSON(fiber.Map{"status": "error", "message": "Invalid or expired JWT", "data": nil})
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PasswordChange chặn mọi API của phiên đăng nhập bằng mật khẩu tạm (claim "mcp"), trừ các path trong allowed,
+// cho đến khi người dùng đổi mật khẩu. Đổi mật khẩu thu hồi phiên nên token mới sau khi đăng nhập lại không còn cờ này.
+// Phải đặt sau Protected().
+func PasswordChange(allowed ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := Claims(c)
+		if claims == nil || !claims.MustChangePassword {
+			return c.Next()
+		}
+		path := strings.TrimSuffix(c.Path(), "/")
+		for _, p := range allowed {
+			if path == p {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Password change required",
+			"data":    fiber.Map{"mustChangePassword": true},
+		})
+	}
+}
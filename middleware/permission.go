@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"strings"
+
+	"go-fiber-api/models"
+	"go-fiber-api/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authorizer kiểm tra quyền của vai trò trong JWT theo bảng roles
+type Authorizer struct {
+	roles *repositories.RoleRepository
+}
+
+func NewAuthorizer(roles *repositories.RoleRepository) *Authorizer {
+	return &Authorizer{roles: roles}
+}
+
+// Require cho phép request nếu vai trò của người dùng có ít nhất một trong các quyền,
+// không có -> 403. Phiên đăng nhập bằng PIN chỉ được dùng các quyền trong models.PINPermissions.
+// Request dùng API key được kiểm tra theo quyền của khoá. Phải đặt sau Protected().
+func (a *Authorizer) Require(permissions ...string) fiber.Handler {
+	pinPermissions := []string{}
+	for _, p := range permissions {
+		for _, allowed := range models.PINPermissions {
+			if p == allowed {
+				pinPermissions = append(pinPermissions, p)
+			}
+		}
+	}
+	return func(c *fiber.Ctx) error {
+		if key := CurrentAPIKey(c); key != nil {
+			if !key.HasPermission(permissions...) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Forbidden: API key requires permission " + strings.Join(permissions, " or "),
+					"data":    nil,
+				})
+			}
+			return c.Next()
+		}
+		claims := Claims(c)
+		if claims == nil || claims.Role == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or missing JWT",
+				"data":    nil,
+			})
+		}
+		required := permissions
+		if claims.Method == models.AuthPIN {
+			if len(pinPermissions) == 0 {
+				return pinForbidden(c)
+			}
+			required = pinPermissions
+		}
+		allowed, err := a.roles.HasPermission(c.Context(), claims.Role, required...)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Permission check failed",
+				"data":    nil,
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Forbidden: requires permission " + strings.Join(required, " or "),
+				"data":    nil,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// PasswordSession chặn phiên đăng nhập bằng PIN và API key ở các API nhạy cảm không khai báo quyền
+// (đổi mật khẩu, 2FA, đặt PIN). Phải đặt sau Protected().
+func PasswordSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if CurrentAPIKey(c) != nil {
+			return apiKeyForbidden(c)
+		}
+		if claims := Claims(c); claims != nil && claims.Method == models.AuthPIN {
+			return pinForbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+func apiKeyForbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Forbidden: not available with an API key",
+		"data":    nil,
+	})
+}
+
+func pinForbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Forbidden: not available in a PIN session, log in with your password",
+		"data":    nil,
+	})
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"net"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey là khoá truy cập API cho tích hợp máy - máy (script kế toán, website), do admin tạo.
+// Client gửi khoá qua header X-API-Key; DB chỉ lưu hash của khoá.
+type APIKey struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" bson:"name"`
+	Prefix      string             `json:"prefix" bson:"prefix"` // Vài ký tự đầu của khoá để nhận diện
+	KeyHash     string             `json:"-" bson:"keyHash"`
+	Permissions []string           `json:"permissions" bson:"permissions"`
+	AllowedIPs  []string           `json:"allowedIps,omitempty" bson:"allowedIps,omitempty"` // IP hoặc CIDR; rỗng = mọi IP
+	ExpiresAt   *time.Time         `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	CreatedBy   string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	LastUsedAt  *time.Time         `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	LastIP      string             `json:"lastIp,omitempty" bson:"lastIp,omitempty"`
+	RevokedAt   *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	// Lý do thu hồi tự động (models.RevokeUserDisabled); thu hồi qua API thì bỏ trống
+	RevokedReason string `json:"revokedReason,omitempty" bson:"revokedReason,omitempty"`
+}
+
+// HeaderAPIKey là header client tích hợp gửi API key
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyPrefix đứng đầu mọi API key để dễ nhận ra khi bị lộ (log, mã nguồn)
+const APIKeyPrefix = "pk_"
+
+// APIKeyDeniedPermissions là các quyền quản trị tài khoản không cấp được cho API key
+var APIKeyDeniedPermissions = []string{PermUserManage, PermRoleManage, PermDeviceManage, PermAPIKeyManage}
+
+// AllowsIP kiểm tra IP của request có nằm trong danh sách cho phép của khoá không
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range k.AllowedIPs {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(allowed); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission kiểm tra khoá có ít nhất một trong các quyền không
+func (k *APIKey) HasPermission(permissions ...string) bool {
+	for _, p := range permissions {
+		for _, granted := range k.Permissions {
+			if p == granted {
+				return true
+			}
+		}
+	}
+	return false
+}
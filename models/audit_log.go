@@ -0,0 +1,132 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog là một mục nhật ký thao tác thay đổi dữ liệu (người dùng, sản phẩm, hóa đơn, cài đặt, upload...).
+// Collection audit_logs chỉ ghi thêm; mỗi mục được ghi kèm seq rồi được niêm phong theo thứ tự seq: lưu hash
+// của mục trước (PrevHash) và HMAC của chính nó (Hash) với khoá AUDIT_HMAC_KEY nằm ngoài database, nên sửa,
+// xoá hoặc chèn một mục sẽ làm gãy chuỗi hash từ mục đó trở đi. Mục chưa niêm phong có Hash rỗng.
+type AuditLog struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Seq         int64              `json:"seq" bson:"seq"` // Số thứ tự liên tục bắt đầu từ 1
+	Time        time.Time          `json:"time" bson:"time"`
+	ActorID     string             `json:"actorId,omitempty" bson:"actorId,omitempty"`         // ID người dùng hoặc API key
+	ActorRole   string             `json:"actorRole,omitempty" bson:"actorRole,omitempty"`     // Vai trò trong JWT
+	ActorMethod string             `json:"actorMethod,omitempty" bson:"actorMethod,omitempty"` // password | pin | apikey
+	Action      string             `json:"action" bson:"action"`                               // <đối tượng>.<thao tác>, ví dụ product.update
+	TargetType  string             `json:"targetType" bson:"targetType"`
+	TargetID    string             `json:"targetId,omitempty" bson:"targetId,omitempty"`
+	Before      json.RawMessage    `json:"before,omitempty" bson:"before,omitempty"` // Ảnh chụp JSON trước khi thay đổi
+	After       json.RawMessage    `json:"after,omitempty" bson:"after,omitempty"`   // Ảnh chụp JSON sau khi thay đổi
+	IP          string             `json:"ip,omitempty" bson:"ip,omitempty"`
+	RequestID   string             `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	PrevHash    string             `json:"prevHash" bson:"prevHash"`
+	Hash        string             `json:"hash" bson:"hash"`
+}
+
+// Hành động ghi vào nhật ký thao tác
+const (
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditUserDisable        = "user.disable"
+	AuditUserEnable         = "user.enable"
+	AuditUserResetPassword  = "user.reset_password"
+	AuditUserChangePassword = "user.change_password"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserMFAReset       = "user.mfa_reset"
+	AuditUserMFAEnable      = "user.mfa_enable"
+	AuditUserMFADisable     = "user.mfa_disable"
+	AuditUserMFABackupCodes = "user.mfa_backup_codes"
+	AuditUserPINSet         = "user.pin_set"
+	AuditUserPINRemove      = "user.pin_remove"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleMFA            = "role.mfa"
+	AuditRoleDelete         = "role.delete"
+	AuditDeviceCreate       = "device.create"
+	AuditDeviceRevoke       = "device.revoke"
+	AuditAPIKeyCreate       = "apikey.create"
+	AuditAPIKeyRevoke       = "apikey.revoke"
+	AuditProductCreate      = "product.create"
+	AuditProductUpdate      = "product.update"
+	AuditProductDelete      = "product.delete"
+	AuditInvoiceCreate      = "invoice.create"
+	AuditInvoiceUpdate      = "invoice.update"
+	AuditInvoiceDelete      = "invoice.delete"
+	AuditInvoiceVoid        = "invoice.void"
+	AuditInvoiceReturn      = "invoice.return"
+	AuditEInvoiceIssue      = "einvoice.issue"
+	AuditEInvoiceReplace    = "einvoice.replace"
+	AuditEInvoiceCancel     = "einvoice.cancel"
+	AuditEInvoiceRetry      = "einvoice.retry"
+	AuditQuotationCreate    = "quotation.create"
+	AuditQuotationStatus    = "quotation.status"
+	AuditQuotationConvert   = "quotation.convert"
+	AuditShiftOpen          = "shift.open"
+	AuditShiftClose         = "shift.close"
+	AuditHeldOrderCreate    = "held_order.create"
+	AuditHeldOrderDiscard   = "held_order.discard"
+	AuditSettingsUpdate     = "settings.update"
+	AuditNumberingUpdate    = "settings.numbering"
+	AuditUploadPresign      = "upload.presign"
+	AuditMissing            = "audit.missing" // Lấp seq đã cấp nhưng mục không ghi được
+)
+
+// NewAuditLog tạo mục nhật ký (chưa có Seq, PrevHash, Hash); thời điểm làm tròn tới mili giây như MongoDB lưu
+func NewAuditLog(action, targetID string) AuditLog {
+	targetType, _, _ := strings.Cut(action, ".")
+	return AuditLog{
+		ID:         primitive.NewObjectID(),
+		Time:       time.Now().UTC().Truncate(time.Millisecond),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+}
+
+// ComputeHash tính HMAC-SHA256 với key của mục nhật ký (mọi trường trừ Hash, gồm cả PrevHash)
+func (l *AuditLog) ComputeHash(key []byte) string {
+	content, _ := json.Marshal(struct {
+		ID          string          `json:"id"`
+		Seq         int64           `json:"seq"`
+		Time        string          `json:"time"`
+		ActorID     string          `json:"actorId"`
+		ActorRole   string          `json:"actorRole"`
+		ActorMethod string          `json:"actorMethod"`
+		Action      string          `json:"action"`
+		TargetType  string          `json:"targetType"`
+		TargetID    string          `json:"targetId"`
+		Before      json.RawMessage `json:"before,omitempty"`
+		After       json.RawMessage `json:"after,omitempty"`
+		IP          string          `json:"ip"`
+		RequestID   string          `json:"requestId"`
+		PrevHash    string          `json:"prevHash"`
+	}{
+		l.ID.Hex(), l.Seq, l.Time.UTC().Format(time.RFC3339Nano), l.ActorID, l.ActorRole, l.ActorMethod,
+		l.Action, l.TargetType, l.TargetID, l.Before, l.After, l.IP, l.RequestID, l.PrevHash,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditVerification là kết quả kiểm tra chuỗi hash của nhật ký thao tác
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`            // Số mục đã kiểm tra
+	BrokenAt int64  `json:"brokenAt,omitempty"` // Seq của mục đầu tiên bị sửa, xoá hoặc chèn
+	Reason   string `json:"reason,omitempty"`
+	Pending  int64  `json:"pending"` // Số mục mới ghi chưa được niêm phong
+	// Mục cuối đã niêm phong: lưu lại ở nơi khác (ticket, email) để phát hiện bị xoá bớt các mục cuối
+	HeadSeq  int64  `json:"headSeq"`
+	HeadHash string `json:"headHash,omitempty"`
+}
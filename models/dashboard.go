@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SalesSummary là các chỉ số bán hàng trong một khoảng thời gian
+type SalesSummary struct {
+	Revenue         float64 `json:"revenue"`         // Doanh thu
+	InvoiceCount    int64   `json:"invoiceCount"`    // Số hóa đơn
+	ItemCount       int64   `json:"itemCount"`       // Tổng số lượng sản phẩm bán ra
+	AverageBasket   float64 `json:"averageBasket"`   // Giá trị trung bình mỗi hóa đơn
+	ItemsPerInvoice float64 `json:"itemsPerInvoice"` // Số sản phẩm trung bình mỗi hóa đơn
+}
+
+// PeriodComparison so sánh một kỳ (hôm nay, tuần này, tháng này) với cùng thời điểm của kỳ trước
+type PeriodComparison struct {
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	PreviousFrom time.Time           `json:"previousFrom"`
+	PreviousTo   time.Time           `json:"previousTo"`
+	Current      SalesSummary        `json:"current"`
+	Previous     SalesSummary        `json:"previous"`
+	Change       map[string]*float64 `json:"change"` // % thay đổi theo từng chỉ số, null nếu kỳ trước bằng 0
+}
+
+// SalesHeatmap là doanh thu và số hóa đơn theo thứ trong tuần x giờ trong ngày (GMT+7).
+// Chỉ số hàng 0 = Thứ Hai ... 6 = Chủ Nhật, cột = giờ 0..23.
+type SalesHeatmap struct {
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Revenue      [7][24]float64 `json:"revenue"`
+	InvoiceCount [7][24]int64   `json:"invoiceCount"`
+}
+
+// StaffSales là doanh số của một nhân viên (người tạo hóa đơn) trong khoảng thời gian.
+// Hóa đơn cũ không ghi nhận người tạo được gom vào userId rỗng.
+type StaffSales struct {
+	UserID       string  `json:"userId" bson:"_id"`
+	Username     string  `json:"username" bson:"username"`
+	InvoiceCount int64   `json:"invoiceCount" bson:"invoiceCount"` // Hóa đơn đã hoàn tất
+	Revenue      float64 `json:"revenue" bson:"revenue"`           // Doanh thu sau giảm giá của hóa đơn đã hoàn tất
+	Discounts    float64 `json:"discounts" bson:"discounts"`       // Tổng giảm giá đã cho trên hóa đơn đã hoàn tất
+	VoidCount    int64   `json:"voidCount" bson:"voidCount"`
+	VoidAmount   float64 `json:"voidAmount" bson:"voidAmount"`
+	ReturnCount  int64   `json:"returnCount" bson:"returnCount"`
+	ReturnAmount float64 `json:"returnAmount" bson:"returnAmount"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device là máy POS dùng chung được admin đăng ký. Máy gửi device token (header X-Device-Token) khi đăng nhập
+// bằng PIN; DB chỉ lưu hash của token.
+type Device struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Name       string             `json:"name" bson:"name"`
+	TokenHash  string             `json:"-" bson:"tokenHash"`
+	CreatedBy  string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	LastUsedAt *time.Time         `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	LastIP     string             `json:"lastIp,omitempty" bson:"lastIp,omitempty"`
+	RevokedAt  *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// HeaderDeviceToken là header máy POS gửi device token
+const HeaderDeviceToken = "X-Device-Token"
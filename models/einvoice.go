@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EInvoiceBuyer là thông tin người mua ghi trên hóa đơn điện tử. Khách lẻ có thể để trống.
+type EInvoiceBuyer struct {
+	Name      string `json:"name" bson:"name,omitempty"`       // Tên đơn vị mua hàng
+	TaxCode   string `json:"taxCode" bson:"taxCode,omitempty"` // Mã số thuế người mua
+	Address   string `json:"address" bson:"address,omitempty"`
+	Phone     string `json:"phone" bson:"phone,omitempty"`
+	Email     string `json:"email" bson:"email,omitempty"`
+	BuyerName string `json:"buyerName" bson:"buyerName,omitempty"` // Họ tên người mua hàng
+}
+
+// EInvoice là hóa đơn điện tử đã lập và ký số cho một hóa đơn bán hàng. Một hóa đơn có thể có nhiều bản
+// (bản bị huỷ, bị thay thế) nhưng chỉ một bản đang có hiệu lực.
+type EInvoice struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	InvoiceID    primitive.ObjectID `json:"invoiceId" bson:"invoiceId"`
+	InvoiceCode  string             `json:"invoiceCode" bson:"invoiceCode"`
+	TemplateCode string             `json:"templateCode" bson:"templateCode"` // Ký hiệu mẫu số
+	Series       string             `json:"series" bson:"series"`             // Ký hiệu hóa đơn
+	Number       int64              `json:"number" bson:"number"`             // Số hóa đơn, tăng liên tục theo ký hiệu
+	IssuedAt     time.Time          `json:"issuedAt" bson:"issuedAt"`         // Giờ GMT+7
+	Buyer        EInvoiceBuyer      `json:"buyer" bson:"buyer"`
+	TotalAmount  float64            `json:"totalAmount" bson:"totalAmount"` // Tổng tiền chưa thuế
+	TotalTax     float64            `json:"totalTax" bson:"totalTax"`
+	TotalPayment float64            `json:"totalPayment" bson:"totalPayment"`
+	SignedBy     string             `json:"signedBy" bson:"signedBy"` // Chủ thể chứng thư số đã ký
+	XML          string             `json:"-" bson:"xml"`             // XML đã ký, lấy qua GET /api/invoices/:id/einvoice/xml
+	CreatedBy    string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+
+	// Active = true với hóa đơn điện tử đang có hiệu lực của hóa đơn (mỗi hóa đơn tối đa một bản)
+	Active     bool                `json:"active" bson:"active"`
+	ReplacesID *primitive.ObjectID `json:"replacesId,omitempty" bson:"replacesId,omitempty"` // Hóa đơn điện tử bị thay thế
+	ReplacedBy *primitive.ObjectID `json:"replacedBy,omitempty" bson:"replacedBy,omitempty"`
+	Reason     string              `json:"reason,omitempty" bson:"reason,omitempty"` // Lý do huỷ / thay thế
+
+	// Trạng thái gửi nhà cung cấp hóa đơn điện tử
+	Provider         string               `json:"provider" bson:"provider"`
+	SubmissionStatus string               `json:"submissionStatus" bson:"submissionStatus"`               // pending | issued | cancelled | replaced | failed
+	PendingAction    string               `json:"pendingAction,omitempty" bson:"pendingAction,omitempty"` // Thao tác đang chờ gửi: issue | replace | cancel
+	Attempts         int                  `json:"attempts" bson:"attempts"`                               // Số lần đã gửi thao tác đang chờ
+	NextAttemptAt    *time.Time           `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"` // nil -> không tự gửi lại
+	LastError        string               `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	ProviderRef      string               `json:"providerRef,omitempty" bson:"providerRef,omitempty"`           // Mã giao dịch phía nhà cung cấp
+	TaxAuthorityCode string               `json:"taxAuthorityCode,omitempty" bson:"taxAuthorityCode,omitempty"` // Mã của cơ quan thuế
+	LookupCode       string               `json:"lookupCode,omitempty" bson:"lookupCode,omitempty"`             // Mã tra cứu cho người mua
+	Submissions      []EInvoiceSubmission `json:"submissions" bson:"submissions"`                               // Nhật ký các lần gửi
+}
+
+// Trạng thái gửi hóa đơn điện tử
+const (
+	EInvoicePending   = "pending"   // Chưa phát hành thành công
+	EInvoiceIssued    = "issued"    // Đã phát hành
+	EInvoiceCancelled = "cancelled" // Đã huỷ
+	EInvoiceReplaced  = "replaced"  // Đã bị hóa đơn khác thay thế
+	EInvoiceFailed    = "failed"    // Bị từ chối hoặc hết số lần gửi lại, cần xử lý thủ công
+)
+
+// Thao tác gửi nhà cung cấp
+const (
+	EInvoiceActionIssue   = "issue"
+	EInvoiceActionReplace = "replace"
+	EInvoiceActionCancel  = "cancel"
+)
+
+// EInvoiceSubmission là một lần gửi thao tác tới nhà cung cấp
+type EInvoiceSubmission struct {
+	Action  string    `json:"action" bson:"action"`
+	At      time.Time `json:"at" bson:"at"`
+	Success bool      `json:"success" bson:"success"`
+	Message string    `json:"message,omitempty" bson:"message,omitempty"`
+}
+
+// EInvoiceSuccess là kết quả nhà cung cấp trả về khi thao tác thành công
+type EInvoiceSuccess struct {
+	Status           string // Trạng thái mới: issued | cancelled
+	Active           bool
+	ProviderRef      string
+	TaxAuthorityCode string
+	LookupCode       string
+	Message          string
+}
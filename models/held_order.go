@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HeldOrder là giỏ hàng tạm giữ tại quầy để phục vụ khách tiếp theo, có thể lấy lại hoặc chuyển thành hóa đơn
+type HeldOrder struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Label         string             `json:"label" bson:"label"` // Nhãn để nhận biết, ví dụ: "Bàn 3", "Chị áo đỏ"
+	Items         []InvoiceItem      `json:"items" bson:"items"`
+	Note          string             `json:"note" bson:"note,omitempty"`
+	PaymentMethod string             `json:"paymentMethod" bson:"paymentMethod,omitempty"` // Dùng khi chuyển thành hóa đơn nếu request không gửi lại
+	Discount      float64            `json:"discount" bson:"discount,omitempty"`
+	CreatedBy     string             `json:"createdBy" bson:"createdBy"` // ID user lấy từ JWT
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"` // Giờ GMT+7, dùng cho TTL tự xoá
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// IdempotencyRecord lưu kết quả của một request có header Idempotency-Key để phát lại khi client gửi lại
+type IdempotencyRecord struct {
+	ID          string    `bson:"_id"`                   // <userID>:<Idempotency-Key>
+	Fingerprint string    `bson:"fingerprint"`           // SHA-256 của method + path + body
+	Completed   bool      `bson:"completed"`             // false khi request đầu tiên còn đang xử lý
+	LockedUntil time.Time `bson:"lockedUntil,omitempty"` // Hạn giữ key khi chưa hoàn tất, quá hạn thì lần gửi lại được xử lý lại
+	StatusCode  int       `bson:"statusCode,omitempty"`
+	ContentType string    `bson:"contentType,omitempty"`
+	Response    []byte    `bson:"response,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt"` // Dùng cho TTL index
+}
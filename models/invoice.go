@@ -18,11 +18,72 @@ type Invoice struct {
 	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"` // Giờ GMT+7
 	Items     []InvoiceItem      `json:"items" bson:"items"`
 	Note      string             `json:"note" bson:"note,omitempty"`
+	Status    string             `json:"status" bson:"status,omitempty"`       // completed | void | returned (hóa đơn cũ không có trường này được coi là completed)
+	Version   int64              `json:"version" bson:"version"`               // Tăng mỗi lần cập nhật, dùng cho optimistic locking
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt,omitempty"` // Giờ GMT+7
+	UpdatedBy string             `json:"updatedBy" bson:"updatedBy,omitempty"` // ID user sửa gần nhất
+
+	CreatedBy     string `json:"createdBy" bson:"createdBy,omitempty"`         // ID user tạo hóa đơn (lấy từ JWT)
+	CreatedByName string `json:"createdByName" bson:"createdByName,omitempty"` // Username tại thời điểm tạo
+
+	ClientID        string     `json:"clientId,omitempty" bson:"clientId,omitempty"`               // UUID do máy POS sinh khi tạo hóa đơn offline
+	ClientCreatedAt *time.Time `json:"clientCreatedAt,omitempty" bson:"clientCreatedAt,omitempty"` // Thời điểm bán thực tế trên máy POS
+
+	QuotationID   *primitive.ObjectID `json:"quotationId,omitempty" bson:"quotationId,omitempty"` // Báo giá gốc nếu hóa đơn chuyển từ báo giá
+	QuotationCode string              `json:"quotationCode,omitempty" bson:"quotationCode,omitempty"`
+
+	PaymentMethod string              `json:"paymentMethod" bson:"paymentMethod,omitempty"` // cash | card | transfer | ewallet
+	Discount      float64             `json:"discount" bson:"discount,omitempty"`           // Số tiền giảm giá trên toàn hóa đơn
+	ShiftID       *primitive.ObjectID `json:"shiftId,omitempty" bson:"shiftId,omitempty"`   // Ca thu ngân đang mở khi bán
+
+	VoidedAt   *time.Time `json:"voidedAt,omitempty" bson:"voidedAt,omitempty"` // Huỷ hóa đơn (không thu tiền)
+	VoidedBy   string     `json:"voidedBy,omitempty" bson:"voidedBy,omitempty"`
+	VoidReason string     `json:"voidReason,omitempty" bson:"voidReason,omitempty"`
+
+	ReturnedAt    *time.Time          `json:"returnedAt,omitempty" bson:"returnedAt,omitempty"` // Khách trả hàng, hoàn tiền
+	ReturnedBy    string              `json:"returnedBy,omitempty" bson:"returnedBy,omitempty"`
+	ReturnReason  string              `json:"returnReason,omitempty" bson:"returnReason,omitempty"`
+	ReturnShiftID *primitive.ObjectID `json:"returnShiftId,omitempty" bson:"returnShiftId,omitempty"` // Ca chi tiền hoàn
+	RefundAmount  float64             `json:"refundAmount,omitempty" bson:"refundAmount,omitempty"`
 }
 
+// Trạng thái hóa đơn
+const (
+	InvoiceStatusCompleted = "completed"
+	InvoiceStatusVoid      = "void"
+	InvoiceStatusReturned  = "returned"
+)
+
+// Phương thức thanh toán
+const (
+	PaymentCash     = "cash"
+	PaymentCard     = "card"
+	PaymentTransfer = "transfer"
+	PaymentEWallet  = "ewallet"
+)
+
+// PaymentMethods là danh sách phương thức thanh toán hợp lệ
+var PaymentMethods = []string{PaymentCash, PaymentCard, PaymentTransfer, PaymentEWallet}
+
 type InvoiceItem struct {
 	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
 	Name      string             `json:"name" bson:"name"`
 	Quantity  int                `json:"quantity" bson:"quantity"`
 	Price     float64            `json:"price" bson:"price"` // đơn giá
+	Unit      string             `json:"unit,omitempty" bson:"unit,omitempty"`       // Đơn vị tính
+	VATRate   string             `json:"vatRate,omitempty" bson:"vatRate,omitempty"` // Thuế suất GTGT đã gồm trong đơn giá (0%, 5%, 8%, 10%, KCT, KKKNT); trống -> theo cài đặt cửa hàng
+}
+
+// InvoiceTotal tính tổng tiền hàng của danh sách sản phẩm (số lượng x đơn giá, chưa trừ giảm giá)
+func InvoiceTotal(items []InvoiceItem) float64 {
+	var total float64
+	for _, item := range items {
+		total += float64(item.Quantity) * item.Price
+	}
+	return total
+}
+
+// InvoiceNetTotal là số tiền khách phải trả: tổng tiền hàng trừ giảm giá
+func InvoiceNetTotal(invoice Invoice) float64 {
+	return InvoiceTotal(invoice.Items) - invoice.Discount
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvoiceRevision lưu lại trạng thái của hóa đơn TRƯỚC mỗi lần chỉnh sửa.
+// Bản ghi chỉ được thêm mới, không bao giờ cập nhật hay xoá.
+type InvoiceRevision struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	InvoiceID   primitive.ObjectID `json:"invoiceId" bson:"invoiceId"`
+	Revision    int64              `json:"revision" bson:"revision"` // Version của hóa đơn trước lần sửa (hóa đơn mới bắt đầu từ 1, hóa đơn cũ chưa có version từ 0)
+	Items       []InvoiceItem      `json:"items" bson:"items"`       // Danh sách sản phẩm trước khi sửa
+	Note        string             `json:"note" bson:"note"`
+	TotalAmount float64            `json:"totalAmount" bson:"totalAmount"`
+	EditedBy    string             `json:"editedBy" bson:"editedBy"` // ID user lấy từ JWT
+	EditedAt    time.Time          `json:"editedAt" bson:"editedAt"` // Giờ GMT+7
+	Reason      string             `json:"reason" bson:"reason,omitempty"`
+}
+
+// FieldChange mô tả thay đổi của một trường giữa hai phiên bản hóa đơn
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// ProductStats là thống kê bán hàng của một sản phẩm trong khoảng lọc
+type ProductStats struct {
+	Name     string  `json:"name" bson:"_id"`
+	Quantity int     `json:"quantity" bson:"quantity"`
+	Revenue  float64 `json:"revenue" bson:"revenue"`
+}
+
+// InvoiceStats là thống kê trên TOÀN BỘ hóa đơn khớp bộ lọc (không chỉ trang hiện tại)
+type InvoiceStats struct {
+	InvoiceCount int64                    `json:"invoiceCount"`
+	TotalAmount  float64                  `json:"totalAmount"`
+	Products     map[string]*ProductStats `json:"productStats"` // Key là tên sản phẩm
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt là nhật ký một lần đăng nhập (thành công hoặc thất bại)
+type LoginAttempt struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Username  string             `json:"username" bson:"username"`
+	UserID    string             `json:"userId,omitempty" bson:"userId,omitempty"` // Rỗng nếu username không tồn tại
+	IP        string             `json:"ip" bson:"ip"`
+	UserAgent string             `json:"userAgent" bson:"userAgent"`
+	Method    string             `json:"method,omitempty" bson:"method,omitempty"`     // pin nếu đăng nhập bằng PIN
+	DeviceID  string             `json:"deviceId,omitempty" bson:"deviceId,omitempty"` // Máy POS của lần đăng nhập bằng PIN
+	Success   bool               `json:"success" bson:"success"`
+	Reason    string             `json:"reason" bson:"reason"` // Một trong các hằng Login* bên dưới
+	At        time.Time          `json:"at" bson:"at"`
+}
+
+// Kết quả đăng nhập ghi trong LoginAttempt.Reason
+const (
+	LoginSuccess            = "success"
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginMFAChallenge       = "mfa_challenge"    // Đúng mật khẩu, chờ mã 2FA
+	LoginInvalidMFACode     = "invalid_mfa_code" // Sai mã 2FA ở bước thứ hai
+	LoginInvalidPIN         = "invalid_pin"      // Sai PIN hoặc người dùng chưa đặt PIN
+	LoginInvalidDevice      = "invalid_device"   // Device token không hợp lệ hoặc máy đã bị thu hồi
+	LoginDisabled           = "disabled"         // Đúng mật khẩu nhưng tài khoản đã bị vô hiệu hoá
+	LoginPasswordExpired    = "password_expired" // Mật khẩu tạm do admin đặt lại đã hết hạn
+	LoginThrottled          = "throttled"        // Đang trong thời gian chờ sau nhiều lần sai
+	LoginLocked             = "locked"           // Tài khoản đang bị khoá tạm thời
+)
+
+// LoginThrottle đếm số lần đăng nhập sai liên tiếp theo username hoặc IP
+type LoginThrottle struct {
+	Key          string    `json:"key" bson:"_id"` // user:<username> | ip:<ip> | pin:<username> | device:<deviceId>
+	Failures     int       `json:"failures" bson:"failures"`
+	BlockedUntil time.Time `json:"blockedUntil" bson:"blockedUntil"` // Chưa đến thời điểm này thì không cho thử tiếp
+	Locked       bool      `json:"locked" bson:"locked"`             // Khoá tài khoản (key user:) hoặc khoá PIN (key pin:)
+	ExpiresAt    time.Time `json:"expiresAt" bson:"expiresAt"`       // Không sai thêm đến lúc này thì bộ đếm tự xoá (TTL)
+}
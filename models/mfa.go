@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserMFA là cấu hình xác thực hai lớp TOTP của một người dùng, lưu riêng khỏi users để API danh sách user
+// không bao giờ trả về khoá bí mật
+type UserMFA struct {
+	UserID      string     `json:"userId" bson:"_id"`
+	Secret      string     `json:"-" bson:"secret"` // Khoá TOTP base32
+	Enabled     bool       `json:"enabled" bson:"enabled"`
+	EnabledAt   *time.Time `json:"enabledAt,omitempty" bson:"enabledAt,omitempty"`
+	BackupCodes []string   `json:"-" bson:"backupCodes"` // Hash SHA-256 của mã dự phòng chưa dùng
+	LastStep    int64      `json:"-" bson:"lastStep"`    // Bước thời gian của mã TOTP dùng gần nhất, chống dùng lại mã
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// MFAStatus là trạng thái 2FA trả về cho người dùng
+type MFAStatus struct {
+	Enabled              bool       `json:"enabled"`
+	Required             bool       `json:"required"` // Vai trò của người dùng bắt buộc 2FA
+	EnabledAt            *time.Time `json:"enabledAt,omitempty"`
+	BackupCodesRemaining int        `json:"backupCodesRemaining"`
+}
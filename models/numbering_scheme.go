@@ -0,0 +1,17 @@
+package models
+
+// NumberingScheme cấu hình cách sinh mã chứng từ (hóa đơn, báo giá, ...) của cửa hàng
+type NumberingScheme struct {
+	DocType     string `json:"docType" bson:"docType"`         // Loại chứng từ: invoice, ...
+	Prefix      string `json:"prefix" bson:"prefix"`           // Tiền tố, ví dụ: HD
+	DateFormat  string `json:"dateFormat" bson:"dateFormat"`   // Ghép từ YYYY, YY, MM, DD và dấu "-", ví dụ: YYYYMMDD
+	SeqWidth    int    `json:"seqWidth" bson:"seqWidth"`       // Số chữ số tối thiểu của số thứ tự (tự dài thêm khi vượt)
+	ResetPeriod string `json:"resetPeriod" bson:"resetPeriod"` // daily | monthly | yearly | never
+}
+
+const (
+	ResetDaily   = "daily"
+	ResetMonthly = "monthly"
+	ResetYearly  = "yearly"
+	ResetNever   = "never"
+)
@@ -1,9 +1,16 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Product struct {
-	ID    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name  string             `json:"name" bson:"name"`
-	Price float64            `json:"price" bson:"price"`
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Price     float64            `json:"price" bson:"price"`
+	Version   int64              `json:"version" bson:"version"`               // Tăng mỗi lần cập nhật, dùng cho optimistic locking
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt,omitempty"` // Giờ GMT+7
+	UpdatedBy string             `json:"updatedBy" bson:"updatedBy,omitempty"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái báo giá
+const (
+	QuotationSent     = "sent"     // Đã gửi khách, đang chờ phản hồi
+	QuotationAccepted = "accepted" // Khách đồng ý
+	QuotationRejected = "rejected" // Khách từ chối
+	QuotationExpired  = "expired"  // Quá hạn hiệu lực mà chưa chốt (tính khi đọc từ sent + validUntil)
+)
+
+// Quotation là báo giá gửi khách hàng doanh nghiệp trước khi bán, có thể chuyển thành hóa đơn
+type Quotation struct {
+	ID              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Code            string              `json:"code" bson:"code"` // Mã báo giá: BGXXXXXX
+	CustomerName    string              `json:"customerName" bson:"customerName"`
+	CustomerPhone   string              `json:"customerPhone" bson:"customerPhone,omitempty"`
+	CustomerEmail   string              `json:"customerEmail" bson:"customerEmail,omitempty"`
+	CustomerAddress string              `json:"customerAddress" bson:"customerAddress,omitempty"`
+	CustomerTaxCode string              `json:"customerTaxCode" bson:"customerTaxCode,omitempty"` // Mã số thuế khách hàng
+	Items           []InvoiceItem       `json:"items" bson:"items"`
+	Note            string              `json:"note" bson:"note,omitempty"`
+	ValidUntil      time.Time           `json:"validUntil" bson:"validUntil"` // Hết hiệu lực sau thời điểm này
+	Status          string              `json:"status" bson:"status"`
+	InvoiceID       *primitive.ObjectID `json:"invoiceId,omitempty" bson:"invoiceId,omitempty"` // Hóa đơn được chuyển từ báo giá
+	InvoiceCode     string              `json:"invoiceCode,omitempty" bson:"invoiceCode,omitempty"`
+	CreatedBy       string              `json:"createdBy" bson:"createdBy"`
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"` // Giờ GMT+7
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt,omitempty"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Role là nhóm quyền gán cho người dùng qua User.Role (theo Name)
+type Role struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"` // Khoá của vai trò, ghi trong User.Role
+	Description string             `json:"description" bson:"description"`
+	Permissions []string           `json:"permissions" bson:"permissions"`
+	System      bool               `json:"system" bson:"system"`         // Vai trò mặc định, không xoá được
+	RequireMFA  bool               `json:"requireMfa" bson:"requireMfa"` // Bắt buộc xác thực hai lớp (TOTP) khi đăng nhập
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+	UpdatedBy   string             `json:"updatedBy,omitempty" bson:"updatedBy,omitempty"`
+}
+
+// RoleAdmin luôn có toàn bộ quyền, không sửa được danh sách quyền để tránh tự khoá hệ thống
+const RoleAdmin = "admin"
+
+// Quyền truy cập API
+const (
+	PermProductRead    = "product.read"
+	PermProductWrite   = "product.write"
+	PermInvoiceRead    = "invoice.read"
+	PermInvoiceCreate  = "invoice.create"
+	PermInvoiceUpdate  = "invoice.update"
+	PermInvoiceVoid    = "invoice.void"   // Huỷ, trả hàng
+	PermInvoiceDelete  = "invoice.delete" // Xoá hẳn hóa đơn đã huỷ, mặc định chỉ admin
+	PermInvoiceExport  = "invoice.export"
+	PermEInvoiceIssue  = "einvoice.issue" // Lập, thay thế, huỷ hóa đơn điện tử
+	PermQuotationRead  = "quotation.read"
+	PermQuotationWrite = "quotation.write"
+	PermShiftOperate   = "shift.operate" // Mở / đóng ca của chính mình
+	PermShiftRead      = "shift.read"    // Xem ca của mọi thu ngân
+	PermReportRead     = "report.read"
+	PermSettingsWrite  = "settings.write"
+	PermUserManage     = "user.manage"
+	PermRoleManage     = "role.manage"
+	PermDeviceManage   = "device.manage"
+	PermAPIKeyManage   = "apikey.manage"
+	PermAuditRead      = "audit.read"
+)
+
+// Permissions liệt kê toàn bộ quyền kèm mô tả, trả về cho màn hình phân quyền
+var Permissions = []PermissionInfo{
+	{PermProductRead, "Xem sản phẩm"},
+	{PermProductWrite, "Thêm, sửa, xoá sản phẩm"},
+	{PermInvoiceRead, "Xem hóa đơn"},
+	{PermInvoiceCreate, "Bán hàng: tạo hóa đơn, giỏ tạm giữ, đồng bộ offline"},
+	{PermInvoiceUpdate, "Sửa hóa đơn"},
+	{PermInvoiceVoid, "Huỷ, trả hàng"},
+	{PermInvoiceDelete, "Xoá hẳn hóa đơn đã huỷ"},
+	{PermInvoiceExport, "Xuất hóa đơn ra CSV/XLSX"},
+	{PermEInvoiceIssue, "Lập, thay thế, huỷ hóa đơn điện tử"},
+	{PermQuotationRead, "Xem báo giá"},
+	{PermQuotationWrite, "Tạo, cập nhật, chuyển báo giá thành hóa đơn"},
+	{PermShiftOperate, "Mở, đóng ca thu ngân của mình"},
+	{PermShiftRead, "Xem ca và báo cáo Z của mọi thu ngân"},
+	{PermReportRead, "Xem báo cáo doanh số"},
+	{PermSettingsWrite, "Sửa thông tin cửa hàng, cấu hình đánh số"},
+	{PermUserManage, "Quản lý người dùng"},
+	{PermRoleManage, "Quản lý vai trò và phân quyền"},
+	{PermDeviceManage, "Đăng ký, thu hồi máy POS đăng nhập bằng PIN"},
+	{PermAPIKeyManage, "Tạo, thu hồi API key cho tích hợp"},
+	{PermAuditRead, "Xem nhật ký thao tác"},
+}
+
+// PINPermissions là các quyền tối đa của phiên đăng nhập bằng PIN (thao tác bán hàng tại quầy).
+// Quyền thực tế là giao của danh sách này với quyền của vai trò.
+var PINPermissions = []string{
+	PermProductRead, PermInvoiceRead, PermInvoiceCreate, PermQuotationRead, PermShiftOperate,
+}
+
+// PermissionInfo là một quyền và mô tả
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// IsPermission kiểm tra tên quyền có tồn tại không
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllPermissions trả về tên toàn bộ quyền
+func AllPermissions() []string {
+	names := make([]string, len(Permissions))
+	for i, p := range Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// DefaultRoles là các vai trò được tạo sẵn khi khởi động lần đầu
+func DefaultRoles() []Role {
+	return []Role{
+		{
+			Name:        RoleAdmin,
+			Description: "Quản trị viên, toàn quyền",
+			Permissions: AllPermissions(),
+			System:      true,
+		},
+		{
+			Name:        "manager",
+			Description: "Quản lý cửa hàng",
+			Permissions: []string{
+				PermProductRead, PermProductWrite,
+				PermInvoiceRead, PermInvoiceCreate, PermInvoiceUpdate, PermInvoiceVoid, PermInvoiceExport,
+				PermEInvoiceIssue, PermQuotationRead, PermQuotationWrite,
+				PermShiftOperate, PermShiftRead, PermReportRead, PermSettingsWrite,
+			},
+			System: true,
+		},
+		{
+			Name:        "user",
+			Description: "Thu ngân",
+			Permissions: []string{
+				PermProductRead, PermInvoiceRead, PermInvoiceCreate,
+				PermQuotationRead, PermShiftOperate,
+			},
+			System: true,
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session là một phiên đăng nhập. Mỗi lần refresh phát hành refresh token mới trong cùng phiên (cùng "họ" token);
+// thu hồi phiên làm mọi access token và refresh token của phiên hết hiệu lực.
+type Session struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	UserID        string             `json:"userId" bson:"userId"`
+	UserAgent     string             `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	IP            string             `json:"ip,omitempty" bson:"ip,omitempty"`
+	Method        string             `json:"method,omitempty" bson:"method,omitempty"`     // password | pin
+	DeviceID      string             `json:"deviceId,omitempty" bson:"deviceId,omitempty"` // Máy POS của phiên đăng nhập bằng PIN
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	LastUsedAt    time.Time          `json:"lastUsedAt" bson:"lastUsedAt"` // Lần refresh gần nhất
+	ExpiresAt     time.Time          `json:"expiresAt" bson:"expiresAt"`   // Hết hạn refresh token hiện tại, dùng cho TTL index
+	RevokedAt     *time.Time         `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	RevokedReason string             `json:"revokedReason,omitempty" bson:"revokedReason,omitempty"`
+}
+
+// RefreshToken lưu hash SHA-256 của refresh token (không lưu token gốc).
+// Token đã dùng để refresh được giữ lại với UsedAt để phát hiện bị dùng lại.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	SessionID primitive.ObjectID `bson:"sessionId"`
+	TokenHash string             `bson:"tokenHash"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"` // Dùng cho TTL index
+	UsedAt    *time.Time         `bson:"usedAt,omitempty"`
+}
+
+// Cách đăng nhập của phiên (claim "amr" của access token)
+const (
+	AuthPassword = "password"
+	AuthPIN      = "pin"    // PIN trên máy POS đã đăng ký: quyền rút gọn (PINPermissions), phiên ngắn, không có refresh token
+	AuthAPIKey   = "apikey" // Request của tích hợp gửi API key, không có phiên; quyền theo APIKey.Permissions
+)
+
+// Lý do thu hồi phiên
+const (
+	RevokeLogout          = "logout"
+	RevokeLogoutAll       = "logout_all"
+	RevokeTokenReuse      = "refresh_token_reuse"
+	RevokePasswordChanged = "password_changed"
+	RevokeRoleChanged     = "role_changed"
+	RevokeUserDeleted     = "user_deleted"
+	RevokeUserDisabled    = "user_disabled"
+	RevokePasswordReset   = "password_reset"   // Admin đặt lại mật khẩu tạm
+	RevokeDefaultPassword = "default_password" // Tài khoản seed sẵn còn dùng mật khẩu mặc định
+	RevokeMFAReset        = "mfa_reset"        // Admin tắt 2FA của người dùng
+	RevokeMFARequired     = "mfa_required"     // Vai trò bắt buộc 2FA nhưng người dùng chưa đăng ký
+	RevokeDeviceRevoked   = "device_revoked"
+	RevokePINChanged      = "pin_changed"
+)
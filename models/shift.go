@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái ca thu ngân
+const (
+	ShiftOpen   = "open"
+	ShiftClosed = "closed"
+)
+
+// Shift là ca làm việc của thu ngân, mở với tiền đầu ca và đóng với số tiền mặt đếm được.
+// Sau khi đóng, ca và báo cáo Z không thể sửa.
+type Shift struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CashierID    string             `json:"cashierId" bson:"cashierId"`
+	CashierName  string             `json:"cashierName" bson:"cashierName"`
+	Status       string             `json:"status" bson:"status"`             // open | closed
+	OpeningFloat float64            `json:"openingFloat" bson:"openingFloat"` // Tiền mặt đầu ca
+	OpenedAt     time.Time          `json:"openedAt" bson:"openedAt"`         // Giờ GMT+7
+	ClosedAt     *time.Time         `json:"closedAt,omitempty" bson:"closedAt,omitempty"`
+	ClosedBy     string             `json:"closedBy,omitempty" bson:"closedBy,omitempty"`
+	CountedCash  *float64           `json:"countedCash,omitempty" bson:"countedCash,omitempty"` // Tiền mặt đếm được khi đóng ca
+	Note         string             `json:"note,omitempty" bson:"note,omitempty"`
+	Report       *ZReport           `json:"report,omitempty" bson:"report,omitempty"` // Báo cáo Z chốt lúc đóng ca
+
+	PendingWrites int `json:"-" bson:"pendingWrites,omitempty"` // Số hóa đơn đang được ghi vào ca, đóng ca chờ về 0
+}
+
+// PaymentTotal là số hóa đơn và số tiền theo một phương thức thanh toán
+type PaymentTotal struct {
+	Method       string  `json:"method" bson:"_id"`
+	InvoiceCount int64   `json:"invoiceCount" bson:"invoiceCount"`
+	Amount       float64 `json:"amount" bson:"amount"`
+}
+
+// ZReport là báo cáo cuối ca: doanh số theo phương thức thanh toán, huỷ, trả hàng, giảm giá và đối soát tiền mặt
+type ZReport struct {
+	GeneratedAt time.Time `json:"generatedAt" bson:"generatedAt"`
+
+	InvoiceCount int64          `json:"invoiceCount" bson:"invoiceCount"`
+	GrossSales   float64        `json:"grossSales" bson:"grossSales"` // Tổng tiền hàng trước giảm giá
+	Discounts    float64        `json:"discounts" bson:"discounts"`
+	NetSales     float64        `json:"netSales" bson:"netSales"`
+	Payments     []PaymentTotal `json:"payments" bson:"payments"` // Doanh số theo phương thức thanh toán
+
+	VoidCount  int64   `json:"voidCount" bson:"voidCount"`
+	VoidAmount float64 `json:"voidAmount" bson:"voidAmount"`
+
+	ReturnCount  int64          `json:"returnCount" bson:"returnCount"`
+	ReturnAmount float64        `json:"returnAmount" bson:"returnAmount"`
+	Returns      []PaymentTotal `json:"returns" bson:"returns"` // Tiền hoàn theo phương thức thanh toán
+
+	OpeningFloat float64  `json:"openingFloat" bson:"openingFloat"`
+	CashSales    float64  `json:"cashSales" bson:"cashSales"`
+	CashRefunds  float64  `json:"cashRefunds" bson:"cashRefunds"`
+	ExpectedCash float64  `json:"expectedCash" bson:"expectedCash"` // Đầu ca + bán tiền mặt - hoàn tiền mặt
+	CountedCash  *float64 `json:"countedCash,omitempty" bson:"countedCash,omitempty"`
+	Difference   *float64 `json:"difference,omitempty" bson:"difference,omitempty"` // Đếm được - dự kiến (âm là thiếu)
+}
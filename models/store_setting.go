@@ -1,11 +1,27 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type StoreSetting struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	StoreName string             `json:"storeName" bson:"storeName"`
-	Address		string							`json:"address" bson:"address"`
+	Address   string             `json:"address" bson:"address"`
 	Phone     string             `json:"phone" bson:"phone"`
-	LogoUrl   string             `json:"logoUrl" bson:"logoUrl"` // URL ảnh logo
+	LogoUrl   string             `json:"logoUrl" bson:"logoUrl"`           // URL ảnh logo
+	TaxCode   string             `json:"taxCode" bson:"taxCode,omitempty"` // Mã số thuế người bán
+	Email     string             `json:"email" bson:"email,omitempty"`
+	Version   int64              `json:"version" bson:"version"`               // Tăng mỗi lần cập nhật, dùng cho optimistic locking
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt,omitempty"` // Giờ GMT+7
+	UpdatedBy string             `json:"updatedBy" bson:"updatedBy,omitempty"`
+
+	Numbering map[string]NumberingScheme `json:"numbering,omitempty" bson:"numbering,omitempty"` // Cấu hình đánh số theo loại chứng từ
+
+	// Hóa đơn điện tử (Nghị định 123/2020, Thông tư 78/2021)
+	EInvoiceTemplate string `json:"einvoiceTemplate" bson:"einvoiceTemplate,omitempty"` // Ký hiệu mẫu số, ví dụ "1" (hóa đơn GTGT)
+	EInvoiceSeries   string `json:"einvoiceSeries" bson:"einvoiceSeries,omitempty"`     // Ký hiệu hóa đơn, ví dụ "C25TAA"
+	DefaultVATRate   string `json:"defaultVatRate" bson:"defaultVatRate,omitempty"`     // Thuế suất cho sản phẩm không ghi thuế suất: 0%, 5%, 8%, 10%, KCT, KKKNT
 }
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tombstone đánh dấu một bản ghi đã bị xoá để client offline đồng bộ việc xoá
+type Tombstone struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Collection string             `json:"collection" bson:"collection"` // Ví dụ: "products"
+	DocID      primitive.ObjectID `json:"id" bson:"docId"`
+	DeletedAt  time.Time          `json:"deletedAt" bson:"deletedAt"`
+}
@@ -1,10 +1,32 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Username string             `bson:"username" json:"username"`
 	Password string             `bson:"password,omitempty" json:"password,omitempty"`
 	Role     string             `bson:"role" json:"role"`
+	PIN      string             `bson:"pin,omitempty" json:"-"` // Hash PIN đăng nhập nhanh trên máy POS đã đăng ký
+	FullName string             `bson:"fullName,omitempty" json:"fullName,omitempty"`
+	Phone    string             `bson:"phone,omitempty" json:"phone,omitempty"`
+
+	// Tài khoản bị vô hiệu hoá không đăng nhập được nhưng vẫn giữ lại để hóa đơn, ca cũ tra được thu ngân
+	Disabled   bool       `bson:"disabled" json:"disabled"`
+	DisabledAt *time.Time `bson:"disabledAt,omitempty" json:"disabledAt,omitempty"`
+
+	// Mật khẩu tạm do admin đặt lại: phải đổi mật khẩu ngay sau khi đăng nhập, hết hạn sau PasswordExpiresAt
+	MustChangePassword bool       `bson:"mustChangePassword,omitempty" json:"mustChangePassword,omitempty"`
+	PasswordExpiresAt  *time.Time `bson:"passwordExpiresAt,omitempty" json:"passwordExpiresAt,omitempty"`
+	PasswordChangedAt  *time.Time `bson:"passwordChangedAt,omitempty" json:"passwordChangedAt,omitempty"`
+	// Hash các mật khẩu gần nhất (mới nhất trước) để chặn dùng lại, giữ tối đa PASSWORD_HISTORY mục
+	PasswordHistory []string `bson:"passwordHistory,omitempty" json:"-"`
+
+	LastLoginAt *time.Time `bson:"lastLoginAt,omitempty" json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `bson:"createdAt,omitempty" json:"createdAt,omitempty"`
+	UpdatedAt   time.Time  `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
 }
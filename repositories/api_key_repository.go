@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// apiKeyTouchInterval là khoảng tối thiểu giữa hai lần ghi lastUsedAt để không ghi DB ở mọi request
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyRepository lưu API key cho tích hợp máy - máy
+type APIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyRepository(db *mongo.Database) *APIKeyRepository {
+	return &APIKeyRepository{collection: db.Collection("api_keys")}
+}
+
+// EnsureIndexes đảm bảo mỗi hash chỉ thuộc một khoá
+func (r *APIKeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "keyHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = time.Now()
+	if _, err := r.collection.InsertOne(ctx, key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List liệt kê API key, khoá mới tạo trước (kể cả khoá đã thu hồi, hết hạn)
+func (r *APIKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindActive tìm khoá chưa thu hồi, chưa hết hạn theo hash; không có -> mongo.ErrNoDocuments
+func (r *APIKeyRepository) FindActive(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{
+		"keyHash":   keyHash,
+		"revokedAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}).Decode(&key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Touch ghi nhận lần dùng khoá, tối đa mỗi phút một lần
+func (r *APIKeyRepository) Touch(ctx context.Context, id primitive.ObjectID, ip string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"lastUsedAt": bson.M{"$exists": false}},
+			bson.M{"lastUsedAt": bson.M{"$lt": now.Add(-apiKeyTouchInterval)}},
+			bson.M{"lastIp": bson.M{"$ne": ip}},
+		}},
+		bson.M{"$set": bson.M{"lastUsedAt": now, "lastIp": ip}},
+	)
+	return err
+}
+
+// Revoke thu hồi khoá; khoá không có hoặc đã thu hồi -> mongo.ErrNoDocuments
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return mongo.ErrNoDocuments
+	}
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// RevokeCreatedBy thu hồi mọi khoá còn hiệu lực do người dùng userID tạo
+func (r *APIKeyRepository) RevokeCreatedBy(ctx context.Context, userID, reason string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"createdBy": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+	return err
+}
@@ -0,0 +1,248 @@
+package repositories
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditGapTimeout: seq đã cấp mà sau khoảng này vẫn chưa có mục (request ghi lỗi giữa chừng) thì được lấp
+// bằng mục audit.missing để chuỗi tiếp tục được niêm phong
+const auditGapTimeout = time.Minute
+
+// auditSealBatch là số mục tối đa đọc ra mỗi lượt niêm phong
+const auditSealBatch = 500
+
+// AuditRepository ghi và tra cứu nhật ký thao tác (collection audit_logs). Không có hàm sửa / xoá.
+// Ghi (Append) chỉ cấp seq và thêm mục nên các request ghi song song không phải chờ nhau; chuỗi hash được
+// tính sau bởi Seal theo đúng thứ tự seq.
+type AuditRepository struct {
+	collection *mongo.Collection
+	sequence   *mongo.Collection
+	key        []byte
+	appended   chan struct{}
+}
+
+// NewAuditRepository tạo repository với khoá HMAC key (AUDIT_HMAC_KEY), khoá không lưu trong database
+func NewAuditRepository(db *mongo.Database, key []byte) *AuditRepository {
+	return &AuditRepository{
+		collection: db.Collection("audit_logs"),
+		sequence:   db.Collection("audit_sequence"),
+		key:        key,
+		appended:   make(chan struct{}, 1),
+	}
+}
+
+// AuditFilter là bộ lọc tra cứu nhật ký thao tác; trường rỗng / nil thì bỏ qua
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From, To   *time.Time
+}
+
+// EnsureIndexes tạo unique index cho seq (giữ chuỗi hash tuần tự), index cho các bộ lọc tra cứu
+// và đưa bộ đếm seq lên ít nhất bằng seq lớn nhất đang có
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "time", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+	var last models.AuditLog
+	err := r.collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.sequence.UpdateOne(ctx, bson.M{"_id": "audit_logs"}, bson.M{"$max": bson.M{"seq": last.Seq}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// Append cấp seq kế tiếp (tăng nguyên tử) và thêm mục nhật ký chưa niêm phong vào cuối chuỗi.
+// Lỗi sau khi đã cấp seq để lại một seq trống, Seal lấp bằng mục audit.missing sau auditGapTimeout.
+func (r *AuditRepository) Append(ctx context.Context, entry models.AuditLog) error {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.sequence.FindOneAndUpdate(ctx,
+		bson.M{"_id": "audit_logs"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return err
+	}
+	entry.Seq, entry.PrevHash, entry.Hash = counter.Seq, "", ""
+	if _, err := r.collection.InsertOne(ctx, entry); err != nil {
+		return err
+	}
+	select {
+	case r.appended <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Appended báo có mục mới được ghi trong tiến trình này, để Seal chạy ngay thay vì chờ lượt định kỳ
+func (r *AuditRepository) Appended() <-chan struct{} {
+	return r.appended
+}
+
+// Seal niêm phong lần lượt theo seq các mục chưa có hash: PrevHash là hash của mục trước, Hash là HMAC của mục.
+// Gặp seq còn trống thì dừng chờ mục đó, quá auditGapTimeout thì lấp bằng mục audit.missing.
+// Nhiều tiến trình cùng niêm phong vẫn an toàn: hash chỉ phụ thuộc nội dung và mục trước nên mọi bên tính ra như nhau.
+func (r *AuditRepository) Seal(ctx context.Context) error {
+	var last models.AuditLog
+	err := r.collection.FindOne(ctx,
+		bson.M{"hash": bson.M{"$ne": ""}},
+		options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}),
+	).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	for {
+		cursor, err := r.collection.Find(ctx,
+			bson.M{"seq": bson.M{"$gt": last.Seq}},
+			options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(auditSealBatch),
+		)
+		if err != nil {
+			return err
+		}
+		var entries []models.AuditLog
+		if err := cursor.All(ctx, &entries); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		for _, entry := range entries {
+			if entry.Seq != last.Seq+1 {
+				if time.Since(entry.Time) < auditGapTimeout {
+					return nil
+				}
+				if err := r.fillGap(ctx, last.Seq+1, entry.Seq); err != nil {
+					return err
+				}
+				break // Đọc lại từ mục vừa lấp
+			}
+			if entry.Hash == "" {
+				entry.PrevHash = last.Hash
+				entry.Hash = entry.ComputeHash(r.key)
+				if _, err := r.collection.UpdateOne(ctx,
+					bson.M{"_id": entry.ID, "hash": ""},
+					bson.M{"$set": bson.M{"prevHash": entry.PrevHash, "hash": entry.Hash}},
+				); err != nil {
+					return err
+				}
+			}
+			last = entry
+		}
+	}
+}
+
+// fillGap ghi mục audit.missing cho các seq từ from đến trước to; seq đã có mục (ghi muộn) thì bỏ qua
+func (r *AuditRepository) fillGap(ctx context.Context, from, to int64) error {
+	for seq := from; seq < to; seq++ {
+		gap := models.NewAuditLog(models.AuditMissing, "")
+		gap.Seq = seq
+		if _, err := r.collection.InsertOne(ctx, gap); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List trả về nhật ký thao tác mới nhất trước theo bộ lọc
+func (r *AuditRepository) List(ctx context.Context, filter AuditFilter, page, limit int64) ([]models.AuditLog, int64, error) {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actorId"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetType != "" {
+		query["targetType"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		query["targetId"] = filter.TargetID
+	}
+	if filter.From != nil || filter.To != nil {
+		timeRange := bson.M{}
+		if filter.From != nil {
+			timeRange["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			timeRange["$lte"] = *filter.To
+		}
+		query["time"] = timeRange
+	}
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	logs := []models.AuditLog{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// Verify duyệt toàn bộ nhật ký theo seq tăng dần, tính lại HMAC từng mục và kiểm tra liên kết với mục trước.
+// Dừng ở mục đầu tiên không khớp (bị sửa), thiếu seq (bị xoá) hoặc PrevHash sai (bị chèn / xoá).
+// Các mục cuối chưa niêm phong được đếm vào Pending; HeadSeq, HeadHash là mục cuối đã niêm phong.
+func (r *AuditRepository) Verify(ctx context.Context) (models.AuditVerification, error) {
+	result := models.AuditVerification{Valid: true}
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+	prevHash, expected := "", int64(1)
+	for cursor.Next(ctx) {
+		var entry models.AuditLog
+		if err := cursor.Decode(&entry); err != nil {
+			return result, err
+		}
+		switch {
+		case entry.Hash == "":
+			result.Pending++
+			continue
+		case result.Pending > 0:
+			result.Reason = "sealed entry after an unsealed one"
+		case entry.Seq != expected:
+			result.Reason = "missing entry before this sequence"
+		case entry.PrevHash != prevHash:
+			result.Reason = "previous hash does not match"
+		case !hmac.Equal([]byte(entry.ComputeHash(r.key)), []byte(entry.Hash)):
+			result.Reason = "entry content does not match its hash"
+		}
+		if result.Reason != "" {
+			result.Valid, result.BrokenAt = false, entry.Seq
+			return result, nil
+		}
+		result.Checked++
+		result.HeadSeq, result.HeadHash = entry.Seq, entry.Hash
+		prevHash, expected = entry.Hash, expected+1
+	}
+	return result, cursor.Err()
+}
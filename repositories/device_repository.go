@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeviceRepository lưu máy POS được phép đăng nhập bằng PIN
+type DeviceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewDeviceRepository(db *mongo.Database) *DeviceRepository {
+	return &DeviceRepository{collection: db.Collection("devices")}
+}
+
+// EnsureIndexes đảm bảo mỗi device token chỉ thuộc một máy
+func (r *DeviceRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *DeviceRepository) Create(ctx context.Context, device models.Device) (*models.Device, error) {
+	device.ID = primitive.NewObjectID()
+	device.CreatedAt = time.Now()
+	if _, err := r.collection.InsertOne(ctx, device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// List liệt kê máy POS, máy mới đăng ký trước (kể cả máy đã thu hồi)
+func (r *DeviceRepository) List(ctx context.Context) ([]models.Device, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	devices := []models.Device{}
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Authenticate tìm máy còn hiệu lực theo hash device token và ghi nhận lần dùng; không có -> mongo.ErrNoDocuments
+func (r *DeviceRepository) Authenticate(ctx context.Context, tokenHash, ip string) (*models.Device, error) {
+	var device models.Device
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"tokenHash": tokenHash, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"lastUsedAt": time.Now(), "lastIp": ip}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&device)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// Revoke thu hồi máy, device token không dùng được nữa; máy không có hoặc đã thu hồi -> mongo.ErrNoDocuments
+func (r *DeviceRepository) Revoke(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return mongo.ErrNoDocuments
+	}
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
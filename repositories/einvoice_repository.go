@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EInvoiceRepository lưu hóa đơn điện tử đã ký; số hóa đơn theo từng ký hiệu lấy từ chính các bản ghi đã lưu
+type EInvoiceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewEInvoiceRepository(db *mongo.Database) *EInvoiceRepository {
+	return &EInvoiceRepository{
+		collection: db.Collection("einvoices"),
+	}
+}
+
+// EnsureIndexes đảm bảo mỗi hóa đơn chỉ có một hóa đơn điện tử đang hiệu lực và số hóa đơn không trùng trong cùng ký hiệu
+//
+// Bản đầu dùng unique index invoiceId_1 (một hóa đơn điện tử mỗi hóa đơn), không cho lưu bản thay thế hay bản
+// lập lại sau khi huỷ nên được xoá trước khi tạo index mới.
+func (r *EInvoiceRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.collection.Indexes().DropOne(ctx, "invoiceId_1"); err != nil {
+		var serverErr mongo.ServerError
+		if !errors.As(err, &serverErr) || !serverErr.HasErrorCode(indexNotFound) && !serverErr.HasErrorCode(namespaceNotFound) {
+			return err
+		}
+	}
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "invoiceId", Value: 1}, {Key: "active", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			Keys: bson.D{{Key: "invoiceId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys:    bson.D{{Key: "templateCode", Value: 1}, {Key: "series", Value: 1}, {Key: "number", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Worker gửi lại tìm bản ghi đến hạn
+			Keys:    bson.D{{Key: "nextAttemptAt", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	return err
+}
+
+// LastNumber trả về số hóa đơn lớn nhất đã lưu của mẫu số + ký hiệu, 0 nếu chưa có. Số bắt đầu từ 1 với mỗi
+// ký hiệu mới (ký hiệu chứa 2 số cuối của năm nên đổi ký hiệu đầu năm là bắt đầu lại từ 1).
+func (r *EInvoiceRepository) LastNumber(ctx context.Context, templateCode, series string) (int64, error) {
+	var last struct {
+		Number int64 `bson:"number"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}).SetProjection(bson.M{"number": 1})
+	err := r.collection.FindOne(ctx, bson.M{"templateCode": templateCode, "series": series}, opts).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return last.Number, err
+}
+
+// NumberTaken kiểm tra số hóa đơn đã được bản ghi khác dùng chưa
+func (r *EInvoiceRepository) NumberTaken(ctx context.Context, templateCode, series string, number int64) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"templateCode": templateCode, "series": series, "number": number})
+	return count > 0, err
+}
+
+// Create lưu hóa đơn điện tử đã ký
+func (r *EInvoiceRepository) Create(ctx context.Context, einvoice models.EInvoice) (*models.EInvoice, error) {
+	einvoice.ID = primitive.NewObjectID()
+	einvoice.CreatedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	if _, err := r.collection.InsertOne(ctx, einvoice); err != nil {
+		return nil, err
+	}
+	return &einvoice, nil
+}
+
+// FindByInvoice lấy hóa đơn điện tử mới nhất của một hóa đơn (bản đang hiệu lực, bản thay thế đang chờ
+// hoặc bản đã huỷ gần nhất)
+func (r *EInvoiceRepository) FindByInvoice(ctx context.Context, invoiceID primitive.ObjectID) (*models.EInvoice, error) {
+	var einvoice models.EInvoice
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	if err := r.collection.FindOne(ctx, bson.M{"invoiceId": invoiceID}, opts).Decode(&einvoice); err != nil {
+		return nil, err
+	}
+	return &einvoice, nil
+}
+
+// History liệt kê các hóa đơn điện tử của một hóa đơn, mới nhất trước
+func (r *EInvoiceRepository) History(ctx context.Context, invoiceID primitive.ObjectID) ([]models.EInvoice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetProjection(bson.M{"xml": 0})
+	cursor, err := r.collection.Find(ctx, bson.M{"invoiceId": invoiceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var records []models.EInvoice
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (r *EInvoiceRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.EInvoice, error) {
+	var einvoice models.EInvoice
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&einvoice); err != nil {
+		return nil, err
+	}
+	return &einvoice, nil
+}
+
+// Claim nhận một thao tác đang chờ gửi đã đến hạn (id = nil -> bản ghi bất kỳ). Hạn gửi lại được dời thêm lease
+// để worker khác không gửi trùng; nếu tiến trình dừng giữa chừng thì hết lease thao tác được gửi lại.
+// Không có thao tác đến hạn -> mongo.ErrNoDocuments.
+func (r *EInvoiceRepository) Claim(ctx context.Context, id *primitive.ObjectID, lease time.Duration) (*models.EInvoice, error) {
+	now := time.Now()
+	filter := bson.M{
+		"pendingAction": bson.M{"$exists": true},
+		"nextAttemptAt": bson.M{"$lte": now},
+	}
+	if id != nil {
+		filter["_id"] = *id
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	var einvoice models.EInvoice
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{
+		"$set": bson.M{"nextAttemptAt": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}, opts).Decode(&einvoice)
+	if err != nil {
+		return nil, err
+	}
+	return &einvoice, nil
+}
+
+// RecordSuccess ghi nhận thao tác action gửi thành công và xoá thao tác đang chờ
+func (r *EInvoiceRepository) RecordSuccess(ctx context.Context, id primitive.ObjectID, action string, result models.EInvoiceSuccess) error {
+	set := bson.M{
+		"submissionStatus": result.Status,
+		"active":           result.Active,
+		"attempts":         0,
+	}
+	if result.ProviderRef != "" {
+		set["providerRef"] = result.ProviderRef
+	}
+	if result.TaxAuthorityCode != "" {
+		set["taxAuthorityCode"] = result.TaxAuthorityCode
+	}
+	if result.LookupCode != "" {
+		set["lookupCode"] = result.LookupCode
+	}
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "pendingAction": action},
+		bson.M{
+			"$set":   set,
+			"$unset": bson.M{"pendingAction": "", "nextAttemptAt": "", "lastError": ""},
+			"$push":  bson.M{"submissions": submission(action, true, result.Message)},
+		},
+	)
+	return err
+}
+
+// RecordFailure ghi nhận lần gửi lỗi. nextAttemptAt = nil -> dừng gửi lại (lỗi không gửi lại được hoặc đã hết
+// số lần); bản ghi chưa từng phát hành chuyển sang failed, thao tác vẫn giữ để gửi lại thủ công qua Retry.
+func (r *EInvoiceRepository) RecordFailure(ctx context.Context, id primitive.ObjectID, action, message string, nextAttemptAt *time.Time) error {
+	update := bson.M{
+		"$push": bson.M{"submissions": submission(action, false, message)},
+	}
+	if nextAttemptAt != nil {
+		update["$set"] = bson.M{"lastError": message, "nextAttemptAt": *nextAttemptAt}
+	} else {
+		update["$unset"] = bson.M{"nextAttemptAt": ""}
+		update["$set"] = bson.M{"lastError": message}
+	}
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "pendingAction": action}, update); err != nil {
+		return err
+	}
+	if nextAttemptAt == nil {
+		_, err := r.collection.UpdateOne(ctx,
+			bson.M{"_id": id, "submissionStatus": models.EInvoicePending},
+			bson.M{"$set": bson.M{"submissionStatus": models.EInvoiceFailed}},
+		)
+		return err
+	}
+	return nil
+}
+
+// RequestCancel đặt thao tác huỷ cho hóa đơn điện tử đã phát hành, gửi ngay ở lần xử lý tiếp theo
+func (r *EInvoiceRepository) RequestCancel(ctx context.Context, id primitive.ObjectID, reason string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "submissionStatus": models.EInvoiceIssued, "active": true, "pendingAction": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"pendingAction": models.EInvoiceActionCancel,
+			"reason":        reason,
+			"attempts":      0,
+			"nextAttemptAt": time.Now(),
+		}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// CancelLocal huỷ hóa đơn điện tử chưa phát hành được (đang chờ hoặc bị từ chối) mà không gửi nhà cung cấp
+func (r *EInvoiceRepository) CancelLocal(ctx context.Context, id primitive.ObjectID, reason string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "submissionStatus": bson.M{"$in": []string{models.EInvoicePending, models.EInvoiceFailed}}},
+		bson.M{
+			"$set":   bson.M{"submissionStatus": models.EInvoiceCancelled, "active": false, "reason": reason},
+			"$unset": bson.M{"pendingAction": "", "nextAttemptAt": ""},
+			"$push":  bson.M{"submissions": submission(models.EInvoiceActionCancel, true, "cancelled locally before issue")},
+		},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// Retry gửi lại thao tác đã dừng gửi lại (lỗi không gửi lại được hoặc hết số lần)
+func (r *EInvoiceRepository) Retry(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "pendingAction": bson.M{"$exists": true}, "nextAttemptAt": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"attempts":      0,
+			"nextAttemptAt": time.Now(),
+			"submissionStatus": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$submissionStatus", models.EInvoiceFailed}}, models.EInvoicePending, "$submissionStatus",
+			}},
+		}}}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// MarkReplaced đánh dấu hóa đơn điện tử id đã bị replacement thay thế và hết hiệu lực
+func (r *EInvoiceRepository) MarkReplaced(ctx context.Context, id, replacement primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"submissionStatus": models.EInvoiceReplaced, "active": false, "replacedBy": replacement}},
+	)
+	return err
+}
+
+// SyncStatus cập nhật trạng thái theo kết quả tra cứu nhà cung cấp
+func (r *EInvoiceRepository) SyncStatus(ctx context.Context, id primitive.ObjectID, status string, active bool) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"submissionStatus": status, "active": active}},
+	)
+	return err
+}
+
+func submission(action string, success bool, message string) models.EInvoiceSubmission {
+	return models.EInvoiceSubmission{
+		Action:  action,
+		At:      time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+		Success: success,
+		Message: message,
+	}
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HeldOrderRepository quản lý giỏ hàng tạm giữ; giỏ bị bỏ quên tự xoá sau ttl
+type HeldOrderRepository struct {
+	collection *mongo.Collection
+	ttl        time.Duration
+}
+
+func NewHeldOrderRepository(db *mongo.Database, ttl time.Duration) *HeldOrderRepository {
+	return &HeldOrderRepository{
+		collection: db.Collection("held_orders"),
+		ttl:        ttl,
+	}
+}
+
+// EnsureIndexes tạo TTL index để MongoDB tự xoá giỏ hàng tạm giữ quá hạn
+func (r *HeldOrderRepository) EnsureIndexes(ctx context.Context) error {
+	return ensureTTLIndex(ctx, r.collection, "createdAt", r.ttl)
+}
+
+// notExpired lọc bỏ giỏ đã quá hạn nhưng TTL monitor chưa kịp xoá
+func (r *HeldOrderRepository) notExpired(filter bson.M) bson.M {
+	filter["createdAt"] = bson.M{"$gt": time.Now().Add(-r.ttl)}
+	return filter
+}
+
+// Create lưu giỏ hàng tạm giữ mới
+func (r *HeldOrderRepository) Create(ctx context.Context, order models.HeldOrder) (*models.HeldOrder, error) {
+	order.ID = primitive.NewObjectID()
+	order.CreatedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	if _, err := r.collection.InsertOne(ctx, order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List trả về các giỏ đang tạm giữ, mới nhất trước. createdBy rỗng -> của tất cả thu ngân.
+func (r *HeldOrderRepository) List(ctx context.Context, createdBy string) ([]models.HeldOrder, error) {
+	filter := bson.M{}
+	if createdBy != "" {
+		filter["createdBy"] = createdBy
+	}
+	opts := options.Find().SetSort(bson.M{"createdAt": -1})
+	cursor, err := r.collection.Find(ctx, r.notExpired(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.HeldOrder{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// FindByID lấy giỏ tạm giữ theo ID
+func (r *HeldOrderRepository) FindByID(ctx context.Context, id string) (*models.HeldOrder, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var order models.HeldOrder
+	if err := r.collection.FindOne(ctx, r.notExpired(bson.M{"_id": objID})).Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Take lấy giỏ ra khỏi danh sách tạm giữ (xoá nguyên tử) để chỉ một quầy lấy được
+func (r *HeldOrderRepository) Take(ctx context.Context, id string) (*models.HeldOrder, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var order models.HeldOrder
+	if err := r.collection.FindOneAndDelete(ctx, r.notExpired(bson.M{"_id": objID})).Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Restore đưa giỏ trở lại danh sách tạm giữ (khi chuyển thành hóa đơn thất bại)
+func (r *HeldOrderRepository) Restore(ctx context.Context, order models.HeldOrder) error {
+	_, err := r.collection.InsertOne(ctx, order)
+	return err
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IdempotencyRepository lưu các Idempotency-Key đã dùng, tự hết hạn sau ttl.
+// Request đang xử lý chỉ giữ key trong thời gian lease: quá lease (ví dụ server bị tắt giữa chừng) thì lần gửi lại được xử lý lại.
+type IdempotencyRepository struct {
+	collection *mongo.Collection
+	ttl        time.Duration
+	lease      time.Duration
+}
+
+func NewIdempotencyRepository(db *mongo.Database, ttl, lease time.Duration) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		collection: db.Collection("idempotency_keys"),
+		ttl:        ttl,
+		lease:      lease,
+	}
+}
+
+// EnsureIndexes tạo TTL index để MongoDB tự xoá key hết hạn (cập nhật khi đổi IDEMPOTENCY_TTL_HOURS)
+func (r *IdempotencyRepository) EnsureIndexes(ctx context.Context) error {
+	return ensureTTLIndex(ctx, r.collection, "createdAt", r.ttl)
+}
+
+// Reserve giữ chỗ cho key. Nếu key đã tồn tại (và chưa hết hạn) thì trả về bản ghi cũ với created = false.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, id, fingerprint string) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyRecord{
+		ID:          id,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		LockedUntil: now.Add(r.lease),
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := r.collection.InsertOne(ctx, record)
+		if err == nil {
+			return &record, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, false, err
+		}
+
+		var existing models.IdempotencyRecord
+		if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+			if err == mongo.ErrNoDocuments {
+				continue // vừa bị xoá, thử giữ chỗ lại
+			}
+			return nil, false, err
+		}
+		// TTL monitor của MongoDB chạy định kỳ nên key hết hạn có thể chưa bị xoá ngay
+		if time.Since(existing.CreatedAt) < r.ttl {
+			if existing.Completed || existing.Fingerprint != fingerprint || time.Now().Before(r.lockedUntil(existing)) {
+				return &existing, false, nil
+			}
+			// Request đầu đã quá lease mà chưa hoàn tất: nhận lại key, chỉ một request thắng nhờ điều kiện lockedUntil cũ
+			filter := bson.M{"_id": id, "completed": false, "lockedUntil": existing.LockedUntil}
+			if existing.LockedUntil.IsZero() {
+				filter["lockedUntil"] = bson.M{"$exists": false}
+			}
+			res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lockedUntil": time.Now().Add(r.lease)}})
+			if err != nil {
+				return nil, false, err
+			}
+			if res.ModifiedCount == 1 {
+				return &existing, true, nil
+			}
+			return &existing, false, nil
+		}
+		if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "createdAt": existing.CreatedAt}); err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, mongo.ErrNoDocuments
+}
+
+// lockedUntil là hạn giữ key của request đang xử lý (bản ghi cũ chưa có trường này tính từ createdAt)
+func (r *IdempotencyRepository) lockedUntil(record models.IdempotencyRecord) time.Time {
+	if record.LockedUntil.IsZero() {
+		return record.CreatedAt.Add(r.lease)
+	}
+	return record.LockedUntil
+}
+
+// Complete lưu response của request đầu tiên để phát lại cho các lần gửi lại
+func (r *IdempotencyRepository) Complete(ctx context.Context, id string, statusCode int, contentType string, response []byte) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"completed":   true,
+		"statusCode":  statusCode,
+		"contentType": contentType,
+		"response":    response,
+	}})
+	return err
+}
+
+// Release xoá key khi request thất bại phía server để client có thể thử lại
+func (r *IdempotencyRepository) Release(ctx context.Context, id string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"go-fiber-api/models"
@@ -12,55 +14,77 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrInvoiceNotEditable được trả về khi sửa hóa đơn đã huỷ hoặc đã trả hàng
+var ErrInvoiceNotEditable = errors.New("invoice is void or returned")
+
+// ErrDiscountExceedsTotal được trả về khi sửa sản phẩm làm tổng tiền nhỏ hơn giảm giá đã lưu của hóa đơn
+var ErrDiscountExceedsTotal = errors.New("discount exceeds invoice total")
+
+// maxCodeAttempts là số lần thử cấp lại mã khi mã vừa sinh đã tồn tại (ví dụ vừa đổi cấu hình đánh số)
+const maxCodeAttempts = 5
+
 type InvoiceRepository struct {
 	collection *mongo.Collection
+	numbering  *NumberingRepository
 }
 
 func NewInvoiceRepository(db *mongo.Database) *InvoiceRepository {
 	return &InvoiceRepository{
 		collection: db.Collection("invoices"),
+		numbering:  NewNumberingRepository(db),
 	}
 }
 
-// generateInvoiceCode tạo mã hóa đơn dạng HD<YYYYMMDD><SEQ>
-func generateInvoiceCode(db *mongo.Database) (string, error) {
-	// Thay vì LoadLocation, dùng FixedZone để đảm bảo không bị nil
-	loc := time.FixedZone("GMT+7", 7*60*60)
-	now := time.Now().In(loc)
-
-	dayKey := now.Format("20060102") // YYYYMMDD, ví dụ: 20250610
-	counterID := fmt.Sprintf("invoice-%s", dayKey)
-
-	filter := bson.M{"_id": counterID}
-	update := bson.M{"$inc": bson.M{"seq": 1}}
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-
-	var result struct {
-		Seq int `bson:"seq"`
-	}
-	err := db.Collection("counters").FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&result)
-	if err != nil {
-		return "", err
-	}
+// EnsureIndexes tạo index phục vụ lọc/thống kê theo ngày, trạng thái và unique index trên code, clientId
+// (clientId chỉ có ở hóa đơn đồng bộ từ máy POS offline)
+func (r *InvoiceRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "shiftId", Value: 1}}},
+		{Keys: bson.D{{Key: "createdBy", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "returnShiftId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"clientId": bson.M{"$type": "string"}}),
+		},
+	})
+	return err
+}
 
-	code := fmt.Sprintf("HD%s%04d", dayKey, result.Seq) // HD202506100001
-	return code, nil
+// isDuplicateCode kiểm tra lỗi trùng khoá có phải do unique index trên code hay không
+func isDuplicateCode(err error) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "code_1")
 }
 
-// Create tạo hóa đơn mới, lưu thời gian theo GMT+7 và sinh mã hóa đơn tự động
+// Create tạo hóa đơn mới, lưu thời gian theo GMT+7 và sinh mã hóa đơn theo cấu hình đánh số
 func (r *InvoiceRepository) Create(ctx context.Context, invoice models.Invoice) (*models.Invoice, error) {
 	loc := time.FixedZone("GMT+7", 7*60*60) // luôn đảm bảo đúng múi giờ GMT+7
 	invoice.CreatedAt = time.Now().In(loc)
 
-	code, err := generateInvoiceCode(r.collection.Database())
-	if err != nil {
-		return nil, err
-	}
-	invoice.Code = code
+	invoice.Status = models.InvoiceStatusCompleted
+	invoice.Version = 1
 
-	res, err := r.collection.InsertOne(ctx, invoice)
-	if err != nil {
-		return nil, err
+	var res *mongo.InsertOneResult
+	for attempt := 1; ; attempt++ {
+		code, err := r.numbering.Next(ctx, "invoice")
+		if err != nil {
+			return nil, err
+		}
+		invoice.Code = code
+
+		res, err = r.collection.InsertOne(ctx, invoice)
+		if err == nil {
+			break
+		}
+		if !isDuplicateCode(err) || attempt == maxCodeAttempts {
+			return nil, err
+		}
 	}
 
 	// Gán lại ID sau khi insert
@@ -71,146 +95,307 @@ func (r *InvoiceRepository) Create(ctx context.Context, invoice models.Invoice)
 	return &invoice, nil
 }
 
-// DeleteMany xoá nhiều hóa đơn theo ID
+// FindByClientID lấy hóa đơn theo UUID do máy POS sinh
+func (r *InvoiceRepository) FindByClientID(ctx context.Context, clientID string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.collection.FindOne(ctx, bson.M{"clientId": clientID}).Decode(&invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// DeleteMany xoá nhiều hóa đơn theo ID, chỉ xoá hóa đơn đã huỷ (void)
 func (r *InvoiceRepository) DeleteMany(ctx context.Context, ids []string) error {
 	var objIDs []primitive.ObjectID
 	for _, id := range ids {
 		objID, _ := primitive.ObjectIDFromHex(id)
 		objIDs = append(objIDs, objID)
 	}
-	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": objIDs}, "status": models.InvoiceStatusVoid})
 	return err
 }
 
-// ListByDateRange lọc hóa đơn theo khoảng ngày
-func (r *InvoiceRepository) ListByDateRange(ctx context.Context, from, to time.Time) ([]models.Invoice, error) {
-	filter := bson.M{
-		"createdAt": bson.M{
-			"$gte": from,
-			"$lte": to,
-		},
-	}
-	cursor, err := r.collection.Find(ctx, filter)
+// FindByID lấy hóa đơn theo ID
+func (r *InvoiceRepository) FindByID(ctx context.Context, id string) (*models.Invoice, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
-	var result []models.Invoice
-	if err = cursor.All(ctx, &result); err != nil {
+	var invoice models.Invoice
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&invoice); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return &invoice, nil
 }
 
-// ListByDateRangePaginated lọc hóa đơn theo khoảng ngày + phân trang
-func (r *InvoiceRepository) ListByDateRangePaginated(ctx context.Context, from, to time.Time, page, limit int64) ([]models.Invoice, int64, error) {
+// Update cập nhật hóa đơn completed (sản phẩm, ghi chú) và trả về trạng thái hóa đơn TRƯỚC khi cập nhật.
+// invoice.Version là version client đã đọc; nếu hóa đơn đã bị người khác sửa thì trả về ErrVersionConflict,
+// hóa đơn đã huỷ / trả hàng trả về ErrInvoiceNotEditable, tổng tiền mới nhỏ hơn giảm giá đã lưu trả về ErrDiscountExceedsTotal.
+func (r *InvoiceRepository) Update(ctx context.Context, id string, invoice models.Invoice) (*models.Invoice, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	total := models.InvoiceTotal(invoice.Items)
 	filter := bson.M{
-		"createdAt": bson.M{
-			"$gte": from,
-			"$lte": to,
+		"_id":     objID,
+		"version": versionFilter(invoice.Version),
+		"status":  bson.M{"$in": bson.A{models.InvoiceStatusCompleted, nil}},
+		// Giảm giá đã lưu không được lớn hơn tổng tiền mới, tránh tổng sau giảm giá bị âm
+		"$expr": bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$discount", 0}}, total}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"items":     invoice.Items,
+			"note":      invoice.Note,
+			"updatedAt": time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+			"updatedBy": invoice.UpdatedBy,
 		},
+		"$inc": bson.M{"version": 1},
 	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
 
-	opts := options.Find().SetSort(bson.M{"createdAt": -1})
-	if limit > 0 {
-		opts.SetSkip((page - 1) * limit)
-		opts.SetLimit(limit)
+	var previous models.Invoice
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		// Phân biệt hóa đơn không tồn tại, đã huỷ / trả hàng với version đã cũ
+		current, findErr := r.FindByID(ctx, id)
+		if findErr != nil {
+			return nil, err
+		}
+		if current.Status == models.InvoiceStatusVoid || current.Status == models.InvoiceStatusReturned {
+			return nil, ErrInvoiceNotEditable
+		}
+		if current.Version == invoice.Version && current.Discount > total {
+			return nil, ErrDiscountExceedsTotal
+		}
+		return nil, ErrVersionConflict
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &previous, nil
+}
 
-	cursor, err := r.collection.Find(ctx, filter, opts)
+// Restore đưa hóa đơn về đúng trạng thái previous (kể cả version) nếu nó vẫn là bản vừa được Update từ previous.
+// Dùng để hoàn tác lần sửa khi không ghi được revision; trả về mongo.ErrNoDocuments nếu hóa đơn đã bị sửa tiếp.
+func (r *InvoiceRepository) Restore(ctx context.Context, previous models.Invoice) error {
+	filter := bson.M{"_id": previous.ID, "version": previous.Version + 1}
+	res, err := r.collection.ReplaceOne(ctx, filter, previous)
 	if err != nil {
-		return nil, 0, err
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
 	}
+	return nil
+}
 
-	var invoices []models.Invoice
-	if err := cursor.All(ctx, &invoices); err != nil {
-		return nil, 0, err
+// invoiceGrossExpr là biểu thức aggregation tính tổng tiền hàng một hóa đơn (tổng số lượng x đơn giá)
+var invoiceGrossExpr = bson.M{"$sum": bson.M{"$map": bson.M{
+	"input": bson.M{"$ifNull": bson.A{"$items", bson.A{}}},
+	"as":    "item",
+	"in":    bson.M{"$multiply": bson.A{"$$item.quantity", "$$item.price"}},
+}}}
+
+// invoiceDiscountExpr là số tiền giảm giá của hóa đơn (0 nếu không có)
+var invoiceDiscountExpr = bson.M{"$ifNull": bson.A{"$discount", 0}}
+
+// invoiceTotalExpr là biểu thức aggregation tính số tiền khách trả (tiền hàng trừ giảm giá)
+var invoiceTotalExpr = bson.M{"$subtract": bson.A{invoiceGrossExpr, invoiceDiscountExpr}}
+
+// invoiceItemCountExpr là biểu thức aggregation tính tổng số lượng sản phẩm của một hóa đơn
+var invoiceItemCountExpr = bson.M{"$sum": bson.M{"$ifNull": bson.A{"$items.quantity", bson.A{}}}}
+
+// lineTotalExpr là thành tiền một dòng sản phẩm sau $unwind: "$items"
+var lineTotalExpr = bson.M{"$multiply": bson.A{"$items.quantity", "$items.price"}}
+
+// Void huỷ hóa đơn đang ở trạng thái completed. Trả về mongo.ErrNoDocuments nếu không có hóa đơn phù hợp.
+func (r *InvoiceRepository) Void(ctx context.Context, id primitive.ObjectID, by, reason string) (*models.Invoice, error) {
+	now := time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	update := bson.M{
+		"$set": bson.M{
+			"status":     models.InvoiceStatusVoid,
+			"voidedAt":   now,
+			"voidedBy":   by,
+			"voidReason": reason,
+			"updatedAt":  now,
+			"updatedBy":  by,
+		},
+		"$inc": bson.M{"version": 1},
 	}
+	return r.transition(ctx, id, update)
+}
 
-	total, _ := r.collection.CountDocuments(ctx, filter)
-	return invoices, total, nil
+// Return ghi nhận khách trả hàng cho hóa đơn completed, hoàn lại số tiền khách đã trả trong ca shiftID
+func (r *InvoiceRepository) Return(ctx context.Context, id primitive.ObjectID, by, reason string, shiftID *primitive.ObjectID) (*models.Invoice, error) {
+	now := time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	// Dùng update dạng pipeline để tính số tiền hoàn từ chính hóa đơn trong cùng một thao tác
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":        models.InvoiceStatusReturned,
+			"returnedAt":    now,
+			"returnedBy":    by,
+			"returnReason":  reason,
+			"returnShiftId": shiftID,
+			"refundAmount":  invoiceTotalExpr,
+			"updatedAt":     now,
+			"updatedBy":     by,
+			"version":       bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}}},
+	}
+	return r.transition(ctx, id, update)
+}
+
+// transition chuyển trạng thái hóa đơn completed (hoặc hóa đơn cũ chưa có trạng thái)
+func (r *InvoiceRepository) transition(ctx context.Context, id primitive.ObjectID, update any) (*models.Invoice, error) {
+	filter := bson.M{"_id": id, "status": bson.M{"$in": bson.A{models.InvoiceStatusCompleted, nil}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var invoice models.Invoice
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
 }
 
-// ListPaginated phân trang danh sách hóa đơn
-func (r *InvoiceRepository) ListPaginated(ctx context.Context, page, limit int64) ([]models.Invoice, int64, error) {
-	opts := options.Find().SetSort(bson.M{"createdAt": -1})
+// InvoiceFilter là bộ lọc dùng chung cho danh sách, thống kê và xuất hóa đơn
+type InvoiceFilter struct {
+	From      *time.Time // Từ thời điểm (bao gồm)
+	To        *time.Time // Đến thời điểm (bao gồm)
+	Code      string     // Lọc theo mã hóa đơn chứa chuỗi này, không phân biệt hoa thường
+	Status    string     // completed | void | returned
+	CreatedBy string     // ID user tạo hóa đơn
+}
+
+// toBSON chuyển bộ lọc sang điều kiện MongoDB
+func (f InvoiceFilter) toBSON() bson.M {
+	filter := bson.M{}
+	if f.From != nil || f.To != nil {
+		createdAt := bson.M{}
+		if f.From != nil {
+			createdAt["$gte"] = *f.From
+		}
+		if f.To != nil {
+			createdAt["$lte"] = *f.To
+		}
+		filter["createdAt"] = createdAt
+	}
+	if f.Code != "" {
+		// Tìm gần đúng như trước: chuỗi con bất kỳ vị trí, không phân biệt hoa thường (ký tự đặc biệt của regex được escape)
+		filter["code"] = bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(f.Code), Options: "i"}}
+	}
+	if f.CreatedBy != "" {
+		filter["createdBy"] = f.CreatedBy
+	}
+	switch f.Status {
+	case "":
+	case models.InvoiceStatusCompleted:
+		filter["status"] = bson.M{"$in": bson.A{models.InvoiceStatusCompleted, nil}}
+	default:
+		filter["status"] = f.Status
+	}
+	return filter
+}
+
+// List trả về hóa đơn khớp bộ lọc, mới nhất trước, có phân trang (limit = 0 -> lấy toàn bộ)
+func (r *InvoiceRepository) List(ctx context.Context, f InvoiceFilter, page, limit int64) ([]models.Invoice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
 	if limit > 0 {
 		opts.SetSkip((page - 1) * limit)
 		opts.SetLimit(limit)
 	}
-
-	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	cursor, err := r.collection.Find(ctx, f.toBSON(), opts)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-
-	var invoices []models.Invoice
+	invoices := []models.Invoice{}
 	if err := cursor.All(ctx, &invoices); err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-
-	total, _ := r.collection.CountDocuments(ctx, bson.M{})
-	return invoices, total, nil
+	return invoices, nil
 }
 
-// Update cập nhật hóa đơn (sản phẩm, ghi chú)
-func (r *InvoiceRepository) Update(ctx context.Context, id string, invoice models.Invoice) error {
-	objID, err := primitive.ObjectIDFromHex(id)
+// Each duyệt lần lượt hóa đơn khớp bộ lọc (cũ nhất trước) bằng cursor, không nạp toàn bộ vào bộ nhớ.
+// Dừng và trả về lỗi đầu tiên của fn.
+func (r *InvoiceRepository) Each(ctx context.Context, f InvoiceFilter, fn func(invoice *models.Invoice) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetBatchSize(500)
+	cursor, err := r.collection.Find(ctx, f.toBSON(), opts)
 	if err != nil {
 		return err
 	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"items": invoice.Items,
-			"note":  invoice.Note,
-		},
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var invoice models.Invoice
+		if err := cursor.Decode(&invoice); err != nil {
+			return err
+		}
+		if err := fn(&invoice); err != nil {
+			return err
+		}
 	}
-	_, err = r.collection.UpdateByID(ctx, objID, update)
-	return err
+	return cursor.Err()
 }
 
-// ListByCode lọc hóa đơn theo mã code (tìm gần đúng)
-func (r *InvoiceRepository) ListByCode(ctx context.Context, code string) ([]models.Invoice, error) {
-	filter := bson.M{
-		"code": bson.M{
-			"$regex": primitive.Regex{Pattern: code, Options: "i"},
-		},
+// Count đếm số hóa đơn khớp bộ lọc (dùng cho phân trang)
+func (r *InvoiceRepository) Count(ctx context.Context, f InvoiceFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, f.toBSON())
+}
+
+// Stats thống kê số hóa đơn, tổng tiền và doanh số từng sản phẩm trên toàn bộ hóa đơn khớp bộ lọc.
+// Tính bằng aggregation phía MongoDB nên không phụ thuộc vào phân trang.
+// Không lọc trạng thái thì chỉ tính hóa đơn completed: hóa đơn huỷ, trả hàng không phải doanh thu.
+func (r *InvoiceRepository) Stats(ctx context.Context, f InvoiceFilter) (*models.InvoiceStats, error) {
+	if f.Status == "" {
+		f.Status = models.InvoiceStatusCompleted
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: f.toBSON()}},
+		{{Key: "$facet", Value: bson.M{
+			"totals": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":          nil,
+					"invoiceCount": bson.M{"$sum": 1},
+					"totalAmount":  bson.M{"$sum": invoiceTotalExpr},
+				}},
+			},
+			"products": bson.A{
+				bson.M{"$unwind": "$items"},
+				bson.M{"$group": bson.M{
+					"_id":      "$items.name",
+					"quantity": bson.M{"$sum": "$items.quantity"},
+					"revenue":  bson.M{"$sum": lineTotalExpr},
+				}},
+			},
+		}}},
 	}
-	cursor, err := r.collection.Find(ctx, filter)
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
 	if err != nil {
 		return nil, err
 	}
-	var result []models.Invoice
+	var result []struct {
+		Totals []struct {
+			InvoiceCount int64   `bson:"invoiceCount"`
+			TotalAmount  float64 `bson:"totalAmount"`
+		} `bson:"totals"`
+		Products []models.ProductStats `bson:"products"`
+	}
 	if err := cursor.All(ctx, &result); err != nil {
 		return nil, err
 	}
-	return result, nil
-}
 
-// ListByCodeAndDatePaginated lọc theo mã + ngày + phân trang
-func (r *InvoiceRepository) ListByCodeAndDatePaginated(ctx context.Context, code string, from, to time.Time, page, limit int64) ([]models.Invoice, int64, error) {
-	filter := bson.M{
-		"code": bson.M{"$regex": primitive.Regex{Pattern: code, Options: "i"}},
-		"createdAt": bson.M{
-			"$gte": from,
-			"$lte": to,
-		},
-	}
-	opts := options.Find().SetSort(bson.M{"createdAt": -1})
-	if limit > 0 {
-		opts.SetSkip((page - 1) * limit)
-		opts.SetLimit(limit)
+	stats := &models.InvoiceStats{Products: map[string]*models.ProductStats{}}
+	if len(result) == 0 {
+		return stats, nil
 	}
-
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, 0, err
+	if len(result[0].Totals) > 0 {
+		stats.InvoiceCount = result[0].Totals[0].InvoiceCount
+		stats.TotalAmount = result[0].Totals[0].TotalAmount
 	}
-
-	var invoices []models.Invoice
-	if err := cursor.All(ctx, &invoices); err != nil {
-		return nil, 0, err
+	for i := range result[0].Products {
+		product := result[0].Products[i]
+		stats.Products[product.Name] = &product
 	}
-	total, _ := r.collection.CountDocuments(ctx, filter)
-	return invoices, total, nil
+	return stats, nil
 }
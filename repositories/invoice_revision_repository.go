@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InvoiceRevisionRepository quản lý lịch sử chỉnh sửa hóa đơn (chỉ ghi thêm, không sửa/xoá)
+type InvoiceRevisionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewInvoiceRevisionRepository(db *mongo.Database) *InvoiceRevisionRepository {
+	return &InvoiceRevisionRepository{
+		collection: db.Collection("invoice_revisions"),
+	}
+}
+
+// EnsureIndexes tạo unique index (invoiceId, revision): mỗi số revision của một hóa đơn chỉ có một bản ghi
+func (r *InvoiceRevisionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "invoiceId", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Create ghi thêm một bản revision. revision.Revision do người gọi đặt bằng version của hóa đơn trước khi sửa:
+// cập nhật hóa đơn theo version (optimistic locking) bảo đảm mỗi version chỉ được sửa một lần nên số revision không trùng.
+func (r *InvoiceRevisionRepository) Create(ctx context.Context, revision models.InvoiceRevision) (*models.InvoiceRevision, error) {
+	revision.ID = primitive.NewObjectID()
+	if _, err := r.collection.InsertOne(ctx, revision); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// ListByInvoice trả về toàn bộ revision của một hóa đơn, sắp xếp từ cũ đến mới
+func (r *InvoiceRevisionRepository) ListByInvoice(ctx context.Context, invoiceID primitive.ObjectID) ([]models.InvoiceRevision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "revision", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"invoiceId": invoiceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []models.InvoiceRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginPolicy cấu hình chống dò mật khẩu và dò PIN
+type LoginPolicy struct {
+	MaxFailures     int           // Sai liên tiếp bấy nhiêu lần theo username -> khoá tài khoản
+	LockDuration    time.Duration // Thời gian khoá tài khoản
+	PINMaxFailures  int           // Sai PIN liên tiếp bấy nhiêu lần -> khoá đăng nhập bằng PIN của người dùng
+	PINLockDuration time.Duration // Thời gian khoá PIN (đăng nhập bằng mật khẩu vẫn dùng được)
+}
+
+const (
+	userFreeAttempts = 3                // Số lần sai theo username trước khi phải chờ
+	userMaxBackoff   = 5 * time.Minute  // Thời gian chờ tối đa giữa hai lần thử theo username
+	ipFreeAttempts   = 10               // Số lần sai theo IP trước khi phải chờ (nhiều người dùng chung IP cửa hàng)
+	ipMaxBackoff     = 15 * time.Minute // Thời gian chờ tối đa theo IP
+	failureWindow    = time.Hour        // Không sai thêm trong khoảng này thì bộ đếm được xoá
+	loginHistoryTTL  = 90 * 24 * time.Hour
+
+	// PIN chỉ có 4-6 số nên được đếm riêng, chặt hơn mật khẩu
+	pinFreeAttempts    = 2
+	pinMaxBackoff      = 5 * time.Minute
+	deviceFreeAttempts = 10 // Nhiều thu ngân dùng chung một máy
+	deviceMaxBackoff   = 15 * time.Minute
+)
+
+// LoginAttemptRepository theo dõi số lần đăng nhập sai theo username và IP (chờ tăng dần theo cấp số nhân,
+// khoá tài khoản tạm thời) và lưu nhật ký đăng nhập
+type LoginAttemptRepository struct {
+	attempts  *mongo.Collection
+	throttles *mongo.Collection
+	policy    LoginPolicy
+}
+
+func NewLoginAttemptRepository(db *mongo.Database, policy LoginPolicy) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		attempts:  db.Collection("login_attempts"),
+		throttles: db.Collection("login_throttles"),
+		policy:    policy,
+	}
+}
+
+// EnsureIndexes tạo index tra cứu nhật ký và TTL index xoá nhật ký cũ, bộ đếm đã hết hạn
+func (r *LoginAttemptRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.attempts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "at", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "at", Value: -1}}},
+		{Keys: bson.D{{Key: "at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(loginHistoryTTL.Seconds()))},
+	}); err != nil {
+		return err
+	}
+	_, err := r.throttles.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func userKey(username string) string { return "user:" + strings.ToLower(strings.TrimSpace(username)) }
+func ipKey(ip string) string         { return "ip:" + ip }
+func pinKey(username string) string  { return "pin:" + strings.ToLower(strings.TrimSpace(username)) }
+func deviceKey(id string) string     { return "device:" + id }
+
+// Reserve giữ một lượt thử đăng nhập cho username và IP trước khi kiểm tra mật khẩu: lượt thử được tính
+// trước là một lần sai (tăng bộ đếm, đặt thời gian chờ cho lần sau) trong cùng một lệnh cập nhật nguyên tử,
+// nên các request song song không cùng lọt qua một lượt. Trả về thời điểm được thử lại (zero nếu được thử)
+// và locked = true nếu tài khoản đang bị khoá. Đúng mật khẩu -> gọi Release để hoàn lại lượt đã giữ.
+func (r *LoginAttemptRepository) Reserve(ctx context.Context, username, ip string) (time.Time, bool, error) {
+	return r.reservePair(ctx, userKey(username), r.userLimit(), ipKey(ip), ipLimit)
+}
+
+// Release hoàn lại lượt thử đã giữ bằng Reserve khi mật khẩu / mã 2FA đúng
+func (r *LoginAttemptRepository) Release(ctx context.Context, username, ip string) error {
+	return r.releasePair(ctx, userKey(username), r.userLimit(), ipKey(ip), ipLimit)
+}
+
+// ReservePIN giữ một lượt thử đăng nhập bằng PIN theo username và trên máy POS, như Reserve (locked = PIN bị khoá).
+// Bộ đếm PIN tách khỏi bộ đếm mật khẩu: dò PIN không khoá đăng nhập bằng mật khẩu và ngược lại.
+func (r *LoginAttemptRepository) ReservePIN(ctx context.Context, username, deviceID string) (time.Time, bool, error) {
+	return r.reservePair(ctx, pinKey(username), r.pinLimit(), deviceKey(deviceID), deviceLimit)
+}
+
+// ReleasePIN hoàn lại lượt thử đã giữ bằng ReservePIN khi PIN đúng
+func (r *LoginAttemptRepository) ReleasePIN(ctx context.Context, username, deviceID string) error {
+	return r.releasePair(ctx, pinKey(username), r.pinLimit(), deviceKey(deviceID), deviceLimit)
+}
+
+// reservePair giữ lượt thử cho key theo người dùng rồi key theo nguồn (IP / máy POS)
+func (r *LoginAttemptRepository) reservePair(ctx context.Context, key string, limit throttleLimit, sourceKey string, sourceLimit throttleLimit) (time.Time, bool, error) {
+	until, locked, err := r.reserve(ctx, key, limit)
+	if err != nil || !until.IsZero() {
+		return until, locked, err
+	}
+	until, locked, err = r.reserve(ctx, sourceKey, sourceLimit)
+	if err == nil && !until.IsZero() {
+		// Nguồn đang bị chặn: lượt thử không diễn ra nên trả lại lượt đã giữ theo người dùng
+		err = r.release(ctx, key, limit)
+	}
+	return until, locked, err
+}
+
+func (r *LoginAttemptRepository) releasePair(ctx context.Context, key string, limit throttleLimit, sourceKey string, sourceLimit throttleLimit) error {
+	if err := r.release(ctx, key, limit); err != nil {
+		return err
+	}
+	return r.release(ctx, sourceKey, sourceLimit)
+}
+
+func (r *LoginAttemptRepository) blocked(ctx context.Context, keys ...string) (time.Time, bool, error) {
+	cursor, err := r.throttles.Find(ctx, bson.M{
+		"_id":          bson.M{"$in": keys},
+		"blockedUntil": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var throttles []models.LoginThrottle
+	if err := cursor.All(ctx, &throttles); err != nil {
+		return time.Time{}, false, err
+	}
+	var until time.Time
+	locked := false
+	for _, t := range throttles {
+		if t.BlockedUntil.After(until) {
+			until = t.BlockedUntil
+		}
+		locked = locked || t.Locked
+	}
+	return until, locked, nil
+}
+
+// RecordPINSuccess xoá bộ đếm sai PIN của username (đăng nhập PIN đúng hoặc đặt PIN mới)
+func (r *LoginAttemptRepository) RecordPINSuccess(ctx context.Context, username string) error {
+	_, err := r.throttles.DeleteOne(ctx, bson.M{"_id": pinKey(username)})
+	return err
+}
+
+// RecordSuccess xoá bộ đếm sai của username. Bộ đếm theo IP giữ nguyên để một tài khoản đúng
+// không xoá được dấu vết dò mật khẩu tài khoản khác từ cùng IP.
+func (r *LoginAttemptRepository) RecordSuccess(ctx context.Context, username string) error {
+	_, err := r.throttles.DeleteOne(ctx, bson.M{"_id": userKey(username)})
+	return err
+}
+
+// Unlock mở khoá tài khoản và PIN, xoá bộ đếm sai của username (admin)
+func (r *LoginAttemptRepository) Unlock(ctx context.Context, username string) error {
+	_, err := r.throttles.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": bson.A{userKey(username), pinKey(username)}}})
+	return err
+}
+
+// Log lưu nhật ký một lần đăng nhập
+func (r *LoginAttemptRepository) Log(ctx context.Context, attempt models.LoginAttempt) error {
+	attempt.ID = primitive.NewObjectID()
+	attempt.At = time.Now()
+	_, err := r.attempts.InsertOne(ctx, attempt)
+	return err
+}
+
+// List trả về nhật ký đăng nhập mới nhất trước, lọc theo username / IP nếu có
+func (r *LoginAttemptRepository) List(ctx context.Context, username, ip string, page, limit int64) ([]models.LoginAttempt, int64, error) {
+	filter := bson.M{}
+	if username != "" {
+		filter["username"] = username
+	}
+	if ip != "" {
+		filter["ip"] = ip
+	}
+	total, err := r.attempts.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cursor, err := r.attempts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	attempts := []models.LoginAttempt{}
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, 0, err
+	}
+	return attempts, total, nil
+}
+
+// throttleLimit là ngưỡng chờ / khoá của một loại bộ đếm
+type throttleLimit struct {
+	free        int           // Số lần sai trước khi phải chờ
+	maxBackoff  time.Duration // Thời gian chờ tối đa
+	maxFailures int           // Sai bấy nhiêu lần thì khoá (0 = không khoá)
+	lockFor     time.Duration // Thời gian khoá
+}
+
+var (
+	ipLimit     = throttleLimit{free: ipFreeAttempts, maxBackoff: ipMaxBackoff}
+	deviceLimit = throttleLimit{free: deviceFreeAttempts, maxBackoff: deviceMaxBackoff}
+)
+
+func (r *LoginAttemptRepository) userLimit() throttleLimit {
+	return throttleLimit{free: userFreeAttempts, maxBackoff: userMaxBackoff, maxFailures: r.policy.MaxFailures, lockFor: r.policy.LockDuration}
+}
+
+func (r *LoginAttemptRepository) pinLimit() throttleLimit {
+	return throttleLimit{free: pinFreeAttempts, maxBackoff: pinMaxBackoff, maxFailures: r.policy.PINMaxFailures, lockFor: r.policy.PINLockDuration}
+}
+
+// reserve tăng bộ đếm của key và đặt thời gian chờ nếu key đang không bị chặn. Key đang bị chặn thì điều kiện
+// lọc không khớp, upsert trùng _id -> đọc lại thời điểm được thử lại.
+func (r *LoginAttemptRepository) reserve(ctx context.Context, key string, limit throttleLimit) (time.Time, bool, error) {
+	for {
+		now := time.Now()
+		_, err := r.throttles.UpdateOne(ctx,
+			bson.M{"_id": key, "blockedUntil": bson.M{"$not": bson.M{"$gt": now}}},
+			limit.apply(now, 1),
+			options.Update().SetUpsert(true),
+		)
+		if !mongo.IsDuplicateKeyError(err) {
+			return time.Time{}, false, err
+		}
+		until, locked, err := r.blocked(ctx, key)
+		if err != nil || !until.IsZero() {
+			return until, locked, err
+		}
+		// Vừa hết thời gian chờ giữa hai lệnh: thử giữ lại
+	}
+}
+
+// release giảm bộ đếm của key một lần và tính lại thời gian chờ theo số lần sai còn lại
+func (r *LoginAttemptRepository) release(ctx context.Context, key string, limit throttleLimit) error {
+	_, err := r.throttles.UpdateOne(ctx, bson.M{"_id": key}, limit.apply(time.Now(), -1))
+	return err
+}
+
+// apply là pipeline cập nhật cộng delta vào bộ đếm rồi tính lại khoá / thời gian chờ, chạy trong một lệnh để bộ đếm
+// và thời gian chờ luôn khớp nhau: sai đủ maxFailures lần (nếu > 0) -> khoá lockFor; miễn free lần đầu, sau đó
+// chờ 1, 2, 4... giây, tối đa maxBackoff
+func (l throttleLimit) apply(now time.Time, delta int) mongo.Pipeline {
+	var locked any = false
+	if l.maxFailures > 0 {
+		locked = bson.M{"$gte": bson.A{"$failures", l.maxFailures}}
+	}
+	wait := bson.M{"$multiply": bson.A{1000, bson.M{"$min": bson.A{
+		bson.M{"$pow": bson.A{2, bson.M{"$subtract": bson.A{"$failures", l.free + 1}}}},
+		l.maxBackoff.Seconds(),
+	}}}}
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"failures": bson.M{"$max": bson.A{0, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, delta}}}}}}},
+		{{Key: "$set", Value: bson.M{"locked": locked}}},
+		{{Key: "$set", Value: bson.M{"blockedUntil": bson.M{"$switch": bson.M{
+			"branches": bson.A{
+				bson.M{"case": "$locked", "then": now.Add(l.lockFor)},
+				bson.M{"case": bson.M{"$lte": bson.A{"$failures", l.free}}, "then": time.Time{}},
+			},
+			"default": bson.M{"$add": bson.A{now, wait}},
+		}}}}},
+		{{Key: "$set", Value: bson.M{"expiresAt": bson.M{"$max": bson.A{now.Add(failureWindow), "$blockedUntil"}}}}},
+	}
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrMFAAlreadyEnabled: người dùng đã bật 2FA, phải tắt trước khi đăng ký khoá mới
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+
+// MFARepository lưu khoá TOTP và mã dự phòng (collection user_mfa, _id = ID người dùng)
+type MFARepository struct {
+	collection *mongo.Collection
+}
+
+func NewMFARepository(db *mongo.Database) *MFARepository {
+	return &MFARepository{collection: db.Collection("user_mfa")}
+}
+
+// Find lấy cấu hình 2FA của người dùng; chưa đăng ký -> mongo.ErrNoDocuments
+func (r *MFARepository) Find(ctx context.Context, userID string) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	if err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&mfa); err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// Setup lưu khoá TOTP mới ở trạng thái chờ kích hoạt (thay khoá chờ kích hoạt cũ nếu có).
+// Đã bật 2FA -> ErrMFAAlreadyEnabled.
+func (r *MFARepository) Setup(ctx context.Context, userID, secret string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": bson.M{"$ne": true}},
+		bson.M{
+			"$set":         bson.M{"secret": secret, "enabled": false, "backupCodes": []string{}, "lastStep": 0, "updatedAt": now},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrMFAAlreadyEnabled // Bản ghi đã bật không khớp filter, upsert trùng _id
+	}
+	return err
+}
+
+// Enable kích hoạt khoá đang chờ bằng mã TOTP ở bước step và lưu hash mã dự phòng.
+// Trả về false nếu không có khoá chờ kích hoạt hoặc mã đã được dùng.
+func (r *MFARepository) Enable(ctx context.Context, userID string, step int64, backupHashes []string) (bool, error) {
+	now := time.Now()
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": false, "lastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"enabled": true, "enabledAt": now, "lastStep": step, "backupCodes": backupHashes, "updatedAt": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// UseStep ghi nhận mã TOTP ở bước step đã được dùng. Trả về false nếu mã cùng bước hoặc bước mới hơn
+// đã được dùng trước đó (chống phát lại mã bị nhìn trộm).
+func (r *MFARepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": true, "lastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"lastStep": step, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// UseBackupCode xoá mã dự phòng (theo hash) khỏi danh sách; false nếu mã không có hoặc đã dùng
+func (r *MFARepository) UseBackupCode(ctx context.Context, userID, hash string) (bool, error) {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": true, "backupCodes": hash},
+		bson.M{"$pull": bson.M{"backupCodes": hash}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// ReplaceBackupCodes thay toàn bộ mã dự phòng, mã cũ hết hiệu lực
+func (r *MFARepository) ReplaceBackupCodes(ctx context.Context, userID string, hashes []string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": true},
+		bson.M{"$set": bson.M{"backupCodes": hashes, "updatedAt": time.Now()}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// Delete tắt 2FA: xoá khoá và mã dự phòng
+func (r *MFARepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
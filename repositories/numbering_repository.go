@@ -0,0 +1,273 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultNumberingSchemes là cấu hình mặc định khi cửa hàng chưa tự cấu hình.
+// Hóa đơn giữ nguyên định dạng cũ HD<YYYYMMDD><SEQ> reset mỗi ngày, báo giá BG<YYYYMM><SEQ> reset mỗi tháng.
+var DefaultNumberingSchemes = map[string]models.NumberingScheme{
+	"invoice":   {DocType: "invoice", Prefix: "HD", DateFormat: "YYYYMMDD", SeqWidth: 4, ResetPeriod: models.ResetDaily},
+	"quotation": {DocType: "quotation", Prefix: "BG", DateFormat: "YYYYMM", SeqWidth: 4, ResetPeriod: models.ResetMonthly},
+}
+
+var (
+	prefixPattern     = regexp.MustCompile(`^[A-Za-z0-9_-]{0,10}$`)
+	dateFormatPattern = regexp.MustCompile(`^(YYYY|YY|MM|DD|-)*$`)
+)
+
+// NumberingRepository sinh mã chứng từ theo cấu hình lưu trong store_settings và bộ đếm trong counters
+type NumberingRepository struct {
+	settings *mongo.Collection
+	counters *mongo.Collection
+	migrated sync.Map // Khoá bộ đếm đã kiểm tra chuyển từ bộ đếm dạng cũ
+}
+
+func NewNumberingRepository(db *mongo.Database) *NumberingRepository {
+	return &NumberingRepository{
+		settings: db.Collection("store_settings"),
+		counters: db.Collection("counters"),
+	}
+}
+
+// ValidateNumberingScheme kiểm tra cấu hình đánh số hợp lệ và không sinh trùng mã giữa các kỳ reset
+func ValidateNumberingScheme(scheme models.NumberingScheme) error {
+	if _, ok := DefaultNumberingSchemes[scheme.DocType]; !ok {
+		return fmt.Errorf("unknown document type %q", scheme.DocType)
+	}
+	if !prefixPattern.MatchString(scheme.Prefix) {
+		return errors.New("prefix must be at most 10 letters, digits, '-' or '_'")
+	}
+	if !dateFormatPattern.MatchString(scheme.DateFormat) {
+		return errors.New("dateFormat may only contain YYYY, YY, MM, DD and '-'")
+	}
+	if scheme.SeqWidth < 1 || scheme.SeqWidth > 10 {
+		return errors.New("seqWidth must be between 1 and 10")
+	}
+
+	// Mã phải chứa đủ phần ngày của kỳ reset, nếu không các kỳ khác nhau sẽ sinh ra cùng một mã
+	hasYear := strings.Contains(scheme.DateFormat, "YY")
+	hasMonth := strings.Contains(scheme.DateFormat, "MM")
+	hasDay := strings.Contains(scheme.DateFormat, "DD")
+	switch scheme.ResetPeriod {
+	case models.ResetDaily:
+		if !hasYear || !hasMonth || !hasDay {
+			return errors.New("daily reset requires year, month and day in dateFormat")
+		}
+	case models.ResetMonthly:
+		if !hasYear || !hasMonth {
+			return errors.New("monthly reset requires year and month in dateFormat")
+		}
+	case models.ResetYearly:
+		if !hasYear {
+			return errors.New("yearly reset requires year in dateFormat")
+		}
+	case models.ResetNever:
+	default:
+		return errors.New("resetPeriod must be daily, monthly, yearly or never")
+	}
+	return nil
+}
+
+// Get lấy cấu hình đánh số của loại chứng từ (mặc định nếu cửa hàng chưa cấu hình)
+func (r *NumberingRepository) Get(ctx context.Context, docType string) (models.NumberingScheme, error) {
+	scheme, ok := DefaultNumberingSchemes[docType]
+	if !ok {
+		return scheme, fmt.Errorf("unknown document type %q", docType)
+	}
+	var setting models.StoreSetting
+	err := r.settings.FindOne(ctx, bson.M{}).Decode(&setting)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return scheme, err
+	}
+	if custom, ok := setting.Numbering[docType]; ok {
+		custom.DocType = docType
+		return custom, nil
+	}
+	return scheme, nil
+}
+
+// List trả về cấu hình đánh số của tất cả loại chứng từ
+func (r *NumberingRepository) List(ctx context.Context) ([]models.NumberingScheme, error) {
+	docTypes := make([]string, 0, len(DefaultNumberingSchemes))
+	for docType := range DefaultNumberingSchemes {
+		docTypes = append(docTypes, docType)
+	}
+	sort.Strings(docTypes)
+
+	schemes := make([]models.NumberingScheme, 0, len(docTypes))
+	for _, docType := range docTypes {
+		scheme, err := r.Get(ctx, docType)
+		if err != nil {
+			return nil, err
+		}
+		schemes = append(schemes, scheme)
+	}
+	return schemes, nil
+}
+
+// Save lưu cấu hình đánh số vào thông tin cửa hàng nếu version của store setting vẫn là version client đã đọc,
+// trả về version mới. Trả về ErrVersionConflict nếu thông tin cửa hàng đã bị sửa trước đó.
+func (r *NumberingRepository) Save(ctx context.Context, scheme models.NumberingScheme, updatedBy string, version int64) (int64, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"numbering." + scheme.DocType: scheme,
+			"updatedAt":                   time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+			"updatedBy":                   updatedBy,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var saved models.StoreSetting
+	err := r.settings.FindOneAndUpdate(ctx, bson.M{"version": versionFilter(version)}, update, opts).Decode(&saved)
+	if err == nil {
+		return saved.Version, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	// Không khớp version: nếu đã có thông tin cửa hàng thì là xung đột, nếu chưa có thì tạo mới
+	count, err := r.settings.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 || version != 0 {
+		return 0, ErrVersionConflict
+	}
+	opts.SetUpsert(true)
+	if err := r.settings.FindOneAndUpdate(ctx, bson.M{}, update, opts).Decode(&saved); err != nil {
+		return 0, err
+	}
+	return saved.Version, nil
+}
+
+// Next cấp mã chứng từ tiếp theo (tăng bộ đếm của cấu hình và kỳ hiện tại)
+func (r *NumberingRepository) Next(ctx context.Context, docType string) (string, error) {
+	scheme, err := r.Get(ctx, docType)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	id := counterID(scheme, now)
+	if err := r.migrateLegacyCounter(ctx, scheme, now); err != nil {
+		return "", err
+	}
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$inc": bson.M{"seq": 1}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var result struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := r.counters.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result); err != nil {
+		return "", err
+	}
+	return formatCode(scheme, now, result.Seq), nil
+}
+
+// Preview trả về count mã tiếp theo sẽ được cấp mà không tăng bộ đếm
+func (r *NumberingRepository) Preview(ctx context.Context, scheme models.NumberingScheme, count int) ([]string, error) {
+	now := time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+
+	var current struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOne(ctx, bson.M{"_id": counterID(scheme, now)}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		// Bộ đếm chưa có: lần cấp đầu tiên sẽ tiếp nối bộ đếm dạng cũ (nếu còn)
+		filter := bson.M{"_id": legacyCounterID(scheme, now), "migratedTo": bson.M{"$exists": false}}
+		err = r.counters.FindOne(ctx, filter).Decode(&current)
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	codes := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		codes = append(codes, formatCode(scheme, now, current.Seq+int64(i)))
+	}
+	return codes, nil
+}
+
+// migrateLegacyCounter chuyển bộ đếm dạng cũ (chỉ theo loại chứng từ và kỳ, không theo tiền tố / định dạng ngày)
+// sang bộ đếm của cấu hình đang dùng, để lần cấp đầu tiên sau khi nâng cấp không lặp lại mã đã cấp trong kỳ.
+// Mỗi bộ đếm cũ chỉ được chuyển một lần.
+func (r *NumberingRepository) migrateLegacyCounter(ctx context.Context, scheme models.NumberingScheme, now time.Time) error {
+	id := counterID(scheme, now)
+	if _, ok := r.migrated.Load(id); ok {
+		return nil
+	}
+	var legacy struct {
+		Seq int64 `bson:"seq"`
+	}
+	legacyID := legacyCounterID(scheme, now)
+	err := r.counters.FindOne(ctx, bson.M{"_id": legacyID, "migratedTo": bson.M{"$exists": false}}).Decode(&legacy)
+	if err == nil {
+		if _, err := r.counters.InsertOne(ctx, bson.M{"_id": id, "seq": legacy.Seq}); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		if _, err := r.counters.UpdateOne(ctx, bson.M{"_id": legacyID}, bson.M{"$set": bson.M{"migratedTo": id}}); err != nil {
+			return err
+		}
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+	r.migrated.Store(id, true)
+	return nil
+}
+
+// counterID là khoá bộ đếm theo loại chứng từ, tiền tố, định dạng ngày và kỳ reset, ví dụ: invoice:HD:YYYYMMDD:20250610.
+// Đổi tiền tố hoặc định dạng ngày sẽ bắt đầu bộ đếm mới; quay lại cấu hình cũ thì tiếp tục bộ đếm cũ nên không cấp trùng mã.
+// Độ dài số thứ tự không nằm trong khoá vì đổi độ dài vẫn có thể sinh ra mã trùng với mã đã cấp.
+func counterID(scheme models.NumberingScheme, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s:%s", scheme.DocType, scheme.Prefix, scheme.DateFormat, periodKey(scheme.ResetPeriod, now))
+}
+
+// legacyCounterID là khoá bộ đếm của bản cũ, ví dụ: invoice-20250610
+func legacyCounterID(scheme models.NumberingScheme, now time.Time) string {
+	if period := periodKey(scheme.ResetPeriod, now); period != "" {
+		return scheme.DocType + "-" + period
+	}
+	return scheme.DocType
+}
+
+// periodKey là kỳ reset chứa thời điểm now (rỗng nếu không reset)
+func periodKey(resetPeriod string, now time.Time) string {
+	switch resetPeriod {
+	case models.ResetDaily:
+		return now.Format("20060102")
+	case models.ResetMonthly:
+		return now.Format("200601")
+	case models.ResetYearly:
+		return now.Format("2006")
+	default:
+		return ""
+	}
+}
+
+// formatCode ghép tiền tố + phần ngày + số thứ tự. Số thứ tự vượt seqWidth thì tự dài thêm.
+func formatCode(scheme models.NumberingScheme, now time.Time, seq int64) string {
+	date := scheme.DateFormat
+	for _, token := range []struct{ from, to string }{
+		{"YYYY", fmt.Sprintf("%04d", now.Year())},
+		{"YY", fmt.Sprintf("%02d", now.Year()%100)},
+		{"MM", fmt.Sprintf("%02d", int(now.Month()))},
+		{"DD", fmt.Sprintf("%02d", now.Day())},
+	} {
+		date = strings.ReplaceAll(date, token.from, token.to)
+	}
+	return fmt.Sprintf("%s%s%0*d", scheme.Prefix, date, scheme.SeqWidth, seq)
+}
@@ -2,8 +2,9 @@ package repositories
 
 import (
 	"context"
-	"errors"
 	"go-fiber-api/models"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -12,32 +13,76 @@ import (
 
 type ProductRepository struct {
 	collection *mongo.Collection
+	tombstones *TombstoneRepository
 }
 
-func NewProductRepository(db *mongo.Database) *ProductRepository {
+func NewProductRepository(db *mongo.Database, tombstones *TombstoneRepository) *ProductRepository {
 	return &ProductRepository{
 		collection: db.Collection("products"),
+		tombstones: tombstones,
 	}
 }
 
-func (r *ProductRepository) Create(ctx context.Context, product models.Product) error {
-	product.ID = primitive.NewObjectID()
-	_, err := r.collection.InsertOne(ctx, product)
+// EnsureIndexes tạo index trên updatedAt phục vụ đồng bộ delta
+func (r *ProductRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "updatedAt", Value: 1}}})
 	return err
 }
 
-func (r *ProductRepository) Update(ctx context.Context, id string, product models.Product) error {
+func (r *ProductRepository) Create(ctx context.Context, product models.Product) (*models.Product, error) {
+	product.ID = primitive.NewObjectID()
+	product.Version = 1
+	product.UpdatedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	if _, err := r.collection.InsertOne(ctx, product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// FindByID lấy sản phẩm theo ID
+func (r *ProductRepository) FindByID(ctx context.Context, id string) (*models.Product, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var product models.Product
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Update cập nhật sản phẩm nếu product.Version khớp version hiện tại và trả về bản ghi sau cập nhật.
+// Trả về ErrVersionConflict nếu sản phẩm đã bị người khác sửa, mongo.ErrNoDocuments nếu không có sản phẩm.
+func (r *ProductRepository) Update(ctx context.Context, id string, product models.Product) (*models.Product, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": objID, "version": versionFilter(product.Version)}
+	update := bson.M{
+		"$set": bson.M{
+			"name":      product.Name,
+			"price":     product.Price,
+			"updatedAt": time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+			"updatedBy": product.UpdatedBy,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Product
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		if _, findErr := r.FindByID(ctx, id); findErr == nil {
+			return nil, ErrVersionConflict
+		}
+		return nil, err
 	}
-	filter := bson.M{"_id": objID}
-	update := bson.M{"$set": product}
-	res, err := r.collection.UpdateOne(ctx, filter, update)
-	if err != nil || res.MatchedCount == 0 {
-		return errors.New("update failed")
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &updated, nil
 }
 
 func (r *ProductRepository) DeleteMany(ctx context.Context, ids []string) error {
@@ -49,8 +94,32 @@ func (r *ProductRepository) DeleteMany(ctx context.Context, ids []string) error
 		}
 	}
 	filter := bson.M{"_id": bson.M{"$in": objIDs}}
-	_, err := r.collection.DeleteMany(ctx, filter)
-	return err
+	res, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return nil
+	}
+	// Ghi tombstone để máy POS offline biết sản phẩm đã bị xoá
+	return r.tombstones.Record(ctx, "products", objIDs)
+}
+
+// ListUpdatedSince trả về sản phẩm thay đổi từ thời điểm since (since rỗng -> toàn bộ sản phẩm)
+func (r *ProductRepository) ListUpdatedSince(ctx context.Context, since time.Time) ([]models.Product, error) {
+	filter := bson.M{}
+	if !since.IsZero() {
+		filter["updatedAt"] = bson.M{"$gte": since}
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"updatedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	products := []models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
 }
 
 func (r *ProductRepository) List(ctx context.Context, page, limit int64, search string) ([]models.Product, int64, error) {
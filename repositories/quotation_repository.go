@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type QuotationRepository struct {
+	collection *mongo.Collection
+	numbering  *NumberingRepository
+}
+
+func NewQuotationRepository(db *mongo.Database) *QuotationRepository {
+	return &QuotationRepository{
+		collection: db.Collection("quotations"),
+		numbering:  NewNumberingRepository(db),
+	}
+}
+
+// EnsureIndexes tạo unique index trên mã báo giá
+func (r *QuotationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// applyExpiry tính trạng thái hết hạn khi đọc: báo giá đang chờ (sent) đã quá validUntil được trả về là expired.
+// Không ghi lại vào database; các thao tác đổi trạng thái đều kiểm tra validUntil nên không cần cập nhật.
+func applyExpiry(quotation *models.Quotation, now time.Time) {
+	if quotation.Status == models.QuotationSent && quotation.ValidUntil.Before(now) {
+		quotation.Status = models.QuotationExpired
+	}
+}
+
+// statusFilter tạo điều kiện lọc theo trạng thái đã tính hết hạn (sent còn hiệu lực, expired gồm cả sent quá hạn)
+func statusFilter(status string, now time.Time) bson.M {
+	switch status {
+	case "":
+		return bson.M{}
+	case models.QuotationSent:
+		return bson.M{"status": models.QuotationSent, "validUntil": bson.M{"$gte": now}}
+	case models.QuotationExpired:
+		return bson.M{"$or": bson.A{
+			bson.M{"status": models.QuotationExpired},
+			bson.M{"status": models.QuotationSent, "validUntil": bson.M{"$lt": now}},
+		}}
+	default:
+		return bson.M{"status": status}
+	}
+}
+
+// Create tạo báo giá mới với mã theo cấu hình đánh số "quotation"
+func (r *QuotationRepository) Create(ctx context.Context, quotation models.Quotation) (*models.Quotation, error) {
+	quotation.ID = primitive.NewObjectID()
+	quotation.CreatedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	quotation.Status = models.QuotationSent
+	quotation.InvoiceID = nil
+	quotation.InvoiceCode = ""
+
+	for attempt := 1; ; attempt++ {
+		code, err := r.numbering.Next(ctx, "quotation")
+		if err != nil {
+			return nil, err
+		}
+		quotation.Code = code
+
+		_, err = r.collection.InsertOne(ctx, quotation)
+		if err == nil {
+			return &quotation, nil
+		}
+		if !isDuplicateCode(err) || attempt == maxCodeAttempts {
+			return nil, err
+		}
+	}
+}
+
+// FindByID lấy báo giá theo ID (đã tính trạng thái hết hạn)
+func (r *QuotationRepository) FindByID(ctx context.Context, id string) (*models.Quotation, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var quotation models.Quotation
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&quotation); err != nil {
+		return nil, err
+	}
+	applyExpiry(&quotation, time.Now())
+	return &quotation, nil
+}
+
+// ListPaginated danh sách báo giá mới nhất trước, lọc theo trạng thái (tùy chọn)
+func (r *QuotationRepository) ListPaginated(ctx context.Context, status string, page, limit int64) ([]models.Quotation, int64, error) {
+	now := time.Now()
+	filter := statusFilter(status, now)
+	opts := options.Find().SetSort(bson.M{"createdAt": -1})
+	if limit > 0 {
+		opts.SetSkip((page - 1) * limit)
+		opts.SetLimit(limit)
+	}
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	quotations := []models.Quotation{}
+	if err := cursor.All(ctx, &quotations); err != nil {
+		return nil, 0, err
+	}
+	for i := range quotations {
+		applyExpiry(&quotations[i], now)
+	}
+	total, _ := r.collection.CountDocuments(ctx, filter)
+	return quotations, total, nil
+}
+
+// SetStatus đổi trạng thái báo giá đang chờ (sent) sang accepted hoặc rejected
+func (r *QuotationRepository) SetStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.QuotationSent, "validUntil": bson.M{"$gte": time.Now()}},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ClaimForInvoice giữ báo giá cho hóa đơn sắp tạo để không chuyển hai lần.
+// Chỉ thành công với báo giá còn hiệu lực, ở trạng thái sent/accepted và chưa gắn hóa đơn.
+func (r *QuotationRepository) ClaimForInvoice(ctx context.Context, id, invoiceID primitive.ObjectID) (*models.Quotation, error) {
+	filter := bson.M{
+		"_id":        id,
+		"status":     bson.M{"$in": bson.A{models.QuotationSent, models.QuotationAccepted}},
+		"validUntil": bson.M{"$gte": time.Now()},
+		"invoiceId":  bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"status": models.QuotationAccepted, "invoiceId": invoiceID, "updatedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var quotation models.Quotation
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&quotation); err != nil {
+		return nil, err
+	}
+	return &quotation, nil
+}
+
+// CompleteInvoice ghi mã hóa đơn đã tạo vào báo giá
+func (r *QuotationRepository) CompleteInvoice(ctx context.Context, id primitive.ObjectID, invoiceCode string) error {
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"invoiceCode": invoiceCode}})
+	return err
+}
+
+// ReleaseInvoice bỏ liên kết hóa đơn khi tạo hóa đơn thất bại
+func (r *QuotationRepository) ReleaseInvoice(ctx context.Context, id, invoiceID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "invoiceId": invoiceID},
+		bson.M{"$unset": bson.M{"invoiceId": ""}},
+	)
+	return err
+}
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReportRepository chạy các aggregation báo cáo trên collection invoices
+type ReportRepository struct {
+	collection *mongo.Collection
+}
+
+func NewReportRepository(db *mongo.Database) *ReportRepository {
+	return &ReportRepository{
+		collection: db.Collection("invoices"),
+	}
+}
+
+// completedBetween lọc hóa đơn đã hoàn tất (bỏ hóa đơn huỷ) trong [from, to)
+func completedBetween(from, to time.Time) bson.M {
+	filter := InvoiceFilter{Status: models.InvoiceStatusCompleted}.toBSON()
+	filter["createdAt"] = bson.M{"$gte": from, "$lt": to}
+	return filter
+}
+
+// Summary tính doanh thu, số hóa đơn, số sản phẩm trong [from, to)
+func (r *ReportRepository) Summary(ctx context.Context, from, to time.Time) (models.SalesSummary, error) {
+	var summary models.SalesSummary
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: completedBetween(from, to)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          nil,
+			"revenue":      bson.M{"$sum": invoiceTotalExpr},
+			"invoiceCount": bson.M{"$sum": 1},
+			"itemCount":    bson.M{"$sum": invoiceItemCountExpr},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return summary, err
+	}
+	var result []struct {
+		Revenue      float64 `bson:"revenue"`
+		InvoiceCount int64   `bson:"invoiceCount"`
+		ItemCount    int64   `bson:"itemCount"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return summary, err
+	}
+	if len(result) == 0 {
+		return summary, nil
+	}
+	summary.Revenue = result[0].Revenue
+	summary.InvoiceCount = result[0].InvoiceCount
+	summary.ItemCount = result[0].ItemCount
+	if summary.InvoiceCount > 0 {
+		summary.AverageBasket = summary.Revenue / float64(summary.InvoiceCount)
+		summary.ItemsPerInvoice = float64(summary.ItemCount) / float64(summary.InvoiceCount)
+	}
+	return summary, nil
+}
+
+// TopProducts trả về limit sản phẩm bán chạy nhất trong [from, to), sortBy là "revenue" hoặc "quantity"
+func (r *ReportRepository) TopProducts(ctx context.Context, from, to time.Time, sortBy string, limit int64) ([]models.ProductStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: completedBetween(from, to)}},
+		{{Key: "$unwind", Value: "$items"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$items.name",
+			"quantity": bson.M{"$sum": "$items.quantity"},
+			"revenue":  bson.M{"$sum": lineTotalExpr},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: sortBy, Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	products := []models.ProductStats{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// Heatmap gom doanh thu, số hóa đơn theo thứ trong tuần và giờ trong ngày (GMT+7) trong [from, to)
+func (r *ReportRepository) Heatmap(ctx context.Context, from, to time.Time) (*models.SalesHeatmap, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: completedBetween(from, to)}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"weekday": bson.M{"$isoDayOfWeek": bson.M{"date": "$createdAt", "timezone": "+07:00"}},
+				"hour":    bson.M{"$hour": bson.M{"date": "$createdAt", "timezone": "+07:00"}},
+			},
+			"revenue":      bson.M{"$sum": invoiceTotalExpr},
+			"invoiceCount": bson.M{"$sum": 1},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var cells []struct {
+		ID struct {
+			Weekday int `bson:"weekday"` // 1 = Thứ Hai ... 7 = Chủ Nhật
+			Hour    int `bson:"hour"`
+		} `bson:"_id"`
+		Revenue      float64 `bson:"revenue"`
+		InvoiceCount int64   `bson:"invoiceCount"`
+	}
+	if err := cursor.All(ctx, &cells); err != nil {
+		return nil, err
+	}
+
+	heatmap := &models.SalesHeatmap{From: from, To: to}
+	for _, cell := range cells {
+		day, hour := cell.ID.Weekday-1, cell.ID.Hour
+		if day < 0 || day > 6 || hour < 0 || hour > 23 {
+			continue
+		}
+		heatmap.Revenue[day][hour] = cell.Revenue
+		heatmap.InvoiceCount[day][hour] = cell.InvoiceCount
+	}
+	return heatmap, nil
+}
+
+// BuildZReport tổng hợp báo cáo Z của một ca: doanh số theo phương thức thanh toán (hóa đơn bán trong ca,
+// kể cả hóa đơn sau đó bị trả), hóa đơn huỷ, hóa đơn trả hàng được hoàn trong ca và đối soát tiền mặt.
+// Hóa đơn cũ không có phương thức thanh toán được tính là tiền mặt.
+func (r *ReportRepository) BuildZReport(ctx context.Context, shift models.Shift) (*models.ZReport, error) {
+	methodExpr := bson.M{"$ifNull": bson.A{"$paymentMethod", models.PaymentCash}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"shiftId": shift.ID},
+			bson.M{"returnShiftId": shift.ID},
+		}}}},
+		{{Key: "$facet", Value: bson.M{
+			"sales": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{
+					"shiftId": shift.ID,
+					"status":  bson.M{"$in": bson.A{models.InvoiceStatusCompleted, models.InvoiceStatusReturned}},
+				}}},
+				{{Key: "$group", Value: bson.M{
+					"_id":          methodExpr,
+					"invoiceCount": bson.M{"$sum": 1},
+					"amount":       bson.M{"$sum": invoiceTotalExpr},
+					"gross":        bson.M{"$sum": invoiceGrossExpr},
+					"discount":     bson.M{"$sum": invoiceDiscountExpr},
+				}}},
+				{{Key: "$sort", Value: bson.M{"_id": 1}}},
+			},
+			"voids": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"shiftId": shift.ID, "status": models.InvoiceStatusVoid}}},
+				{{Key: "$group", Value: bson.M{
+					"_id":    nil,
+					"count":  bson.M{"$sum": 1},
+					"amount": bson.M{"$sum": invoiceTotalExpr},
+				}}},
+			},
+			"returns": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"returnShiftId": shift.ID, "status": models.InvoiceStatusReturned}}},
+				{{Key: "$group", Value: bson.M{
+					"_id":          methodExpr,
+					"invoiceCount": bson.M{"$sum": 1},
+					"amount":       bson.M{"$sum": bson.M{"$ifNull": bson.A{"$refundAmount", invoiceTotalExpr}}},
+				}}},
+				{{Key: "$sort", Value: bson.M{"_id": 1}}},
+			},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		Sales []struct {
+			models.PaymentTotal `bson:",inline"`
+			Gross               float64 `bson:"gross"`
+			Discount            float64 `bson:"discount"`
+		} `bson:"sales"`
+		Voids []struct {
+			Count  int64   `bson:"count"`
+			Amount float64 `bson:"amount"`
+		} `bson:"voids"`
+		Returns []models.PaymentTotal `bson:"returns"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	report := &models.ZReport{
+		GeneratedAt:  time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+		Payments:     []models.PaymentTotal{},
+		Returns:      []models.PaymentTotal{},
+		OpeningFloat: shift.OpeningFloat,
+	}
+	if len(result) > 0 {
+		for _, sale := range result[0].Sales {
+			report.Payments = append(report.Payments, sale.PaymentTotal)
+			report.InvoiceCount += sale.InvoiceCount
+			report.GrossSales += sale.Gross
+			report.Discounts += sale.Discount
+			report.NetSales += sale.Amount
+			if sale.Method == models.PaymentCash {
+				report.CashSales = sale.Amount
+			}
+		}
+		if len(result[0].Voids) > 0 {
+			report.VoidCount = result[0].Voids[0].Count
+			report.VoidAmount = result[0].Voids[0].Amount
+		}
+		for _, ret := range result[0].Returns {
+			report.Returns = append(report.Returns, ret)
+			report.ReturnCount += ret.InvoiceCount
+			report.ReturnAmount += ret.Amount
+			if ret.Method == models.PaymentCash {
+				report.CashRefunds = ret.Amount
+			}
+		}
+	}
+	report.ExpectedCash = report.OpeningFloat + report.CashSales - report.CashRefunds
+	return report, nil
+}
+
+// StaffSales gom hóa đơn tạo trong [from, to) theo người tạo: số hóa đơn, doanh thu, giảm giá,
+// hóa đơn huỷ và trả hàng. Sắp xếp theo doanh thu giảm dần.
+func (r *ReportRepository) StaffSales(ctx context.Context, from, to time.Time) ([]models.StaffSales, error) {
+	// Hóa đơn cũ không có trạng thái được coi là completed
+	isStatus := func(status string) bson.M {
+		return bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$status", models.InvoiceStatusCompleted}}, status}}
+	}
+	completed := isStatus(models.InvoiceStatusCompleted)
+	sumIf := func(cond bson.M, value any) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{cond, value, 0}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"createdAt": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$sort", Value: bson.M{"createdAt": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          bson.M{"$ifNull": bson.A{"$createdBy", ""}},
+			"username":     bson.M{"$last": "$createdByName"},
+			"invoiceCount": sumIf(completed, 1),
+			"revenue":      sumIf(completed, invoiceTotalExpr),
+			"discounts":    sumIf(completed, invoiceDiscountExpr),
+			"voidCount":    sumIf(isStatus(models.InvoiceStatusVoid), 1),
+			"voidAmount":   sumIf(isStatus(models.InvoiceStatusVoid), invoiceTotalExpr),
+			"returnCount":  sumIf(isStatus(models.InvoiceStatusReturned), 1),
+			"returnAmount": sumIf(isStatus(models.InvoiceStatusReturned), bson.M{"$ifNull": bson.A{"$refundAmount", invoiceTotalExpr}}),
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "revenue", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	staff := []models.StaffSales{}
+	if err := cursor.All(ctx, &staff); err != nil {
+		return nil, err
+	}
+	return staff, nil
+}
@@ -0,0 +1,262 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// roleCacheTTL là thời gian giữ quyền của vai trò trong bộ nhớ. Sửa vai trò qua API xoá cache ngay;
+// khi chạy nhiều instance, instance khác nhận thay đổi chậm nhất sau khoảng này.
+const roleCacheTTL = 30 * time.Second
+
+// RoleRepository lưu vai trò và quyền, có cache để middleware kiểm tra quyền không phải đọc DB mỗi request
+type RoleRepository struct {
+	collection *mongo.Collection
+
+	mu    sync.RWMutex
+	cache map[string]cachedRole
+}
+
+type cachedRole struct {
+	permissions map[string]bool
+	requireMFA  bool
+	expires     time.Time
+}
+
+func NewRoleRepository(db *mongo.Database) *RoleRepository {
+	return &RoleRepository{
+		collection: db.Collection("roles"),
+		cache:      map[string]cachedRole{},
+	}
+}
+
+// EnsureIndexes đảm bảo tên vai trò không trùng
+func (r *RoleRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *RoleRepository) List(ctx context.Context) ([]models.Role, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.collection.FindOne(ctx, bson.M{"name": name}).Decode(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Create tạo vai trò mới; trùng tên -> lỗi duplicate key
+func (r *RoleRepository) Create(ctx context.Context, role models.Role) (*models.Role, error) {
+	role.ID = primitive.NewObjectID()
+	role.UpdatedAt = time.Now()
+	if _, err := r.collection.InsertOne(ctx, role); err != nil {
+		return nil, err
+	}
+	r.invalidate(role.Name)
+	return &role, nil
+}
+
+// Update sửa mô tả và danh sách quyền của vai trò
+func (r *RoleRepository) Update(ctx context.Context, role models.Role) (*models.Role, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var saved models.Role
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"name": role.Name},
+		bson.M{"$set": bson.M{
+			"description": role.Description,
+			"permissions": role.Permissions,
+			"updatedAt":   time.Now(),
+			"updatedBy":   role.UpdatedBy,
+		}},
+		opts,
+	).Decode(&saved)
+	r.invalidate(role.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// SetRequireMFA bật / tắt bắt buộc 2FA cho vai trò (áp dụng được cho cả admin)
+func (r *RoleRepository) SetRequireMFA(ctx context.Context, name string, required bool, updatedBy string) (*models.Role, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var saved models.Role
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"name": name},
+		bson.M{"$set": bson.M{"requireMfa": required, "updatedAt": time.Now(), "updatedBy": updatedBy}},
+		opts,
+	).Decode(&saved)
+	r.invalidate(name)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Delete xoá vai trò không phải vai trò mặc định
+func (r *RoleRepository) Delete(ctx context.Context, name string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"name": name, "system": bson.M{"$ne": true}})
+	r.invalidate(name)
+	if err == nil && res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
+
+// EnsureDefaults tạo các vai trò mặc định còn thiếu và luôn cấp đủ quyền cho admin (kể cả quyền mới thêm)
+func (r *RoleRepository) EnsureDefaults(ctx context.Context) error {
+	for _, role := range models.DefaultRoles() {
+		role.UpdatedAt = time.Now()
+		update := bson.M{"$setOnInsert": role}
+		if role.Name == models.RoleAdmin {
+			update = bson.M{
+				"$setOnInsert": bson.M{"name": role.Name, "description": role.Description, "system": true, "updatedAt": role.UpdatedAt},
+				"$set":         bson.M{"permissions": role.Permissions},
+			}
+		}
+		if _, err := r.collection.UpdateOne(ctx, bson.M{"name": role.Name}, update, options.Update().SetUpsert(true)); err != nil {
+			return err
+		}
+		r.invalidate(role.Name)
+	}
+	return nil
+}
+
+// LegacyRoleDescription là mô tả của vai trò tạo tự động cho user có vai trò từ trước khi có bảng roles
+const LegacyRoleDescription = "Vai trò cũ, tạo tự động khi nâng cấp với quyền của thu ngân"
+
+// AdoptLegacyRoles xử lý user có vai trò không nằm trong collection roles (dữ liệu từ trước khi có phân quyền,
+// ví dụ "member"): user không có vai trò được gán vai trò thu ngân ("user"); vai trò khác được tạo với quyền của
+// thu ngân để user vẫn đăng nhập và bán hàng được, admin sửa quyền hoặc chuyển vai trò sau. Trả về tên các vai trò
+// đã tạo.
+func (r *RoleRepository) AdoptLegacyRoles(ctx context.Context) ([]string, error) {
+	users := r.collection.Database().Collection("users")
+	if _, err := users.UpdateMany(ctx,
+		bson.M{"$or": bson.A{bson.M{"role": ""}, bson.M{"role": bson.M{"$exists": false}}}},
+		bson.M{"$set": bson.M{"role": "user", "updatedAt": time.Now()}},
+	); err != nil {
+		return nil, err
+	}
+	names, err := users.Distinct(ctx, "role", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var cashier models.Role
+	for _, role := range models.DefaultRoles() {
+		if role.Name == "user" {
+			cashier = role
+		}
+	}
+
+	created := []string{}
+	for _, value := range names {
+		name, ok := value.(string)
+		if !ok || name == "" {
+			continue
+		}
+		res, err := r.collection.UpdateOne(ctx, bson.M{"name": name}, bson.M{"$setOnInsert": models.Role{
+			Name:        name,
+			Description: LegacyRoleDescription,
+			Permissions: cashier.Permissions,
+			UpdatedAt:   time.Now(),
+		}}, options.Update().SetUpsert(true))
+		if err != nil {
+			return created, err
+		}
+		if res.UpsertedCount > 0 {
+			created = append(created, name)
+			r.invalidate(name)
+		}
+	}
+	return created, nil
+}
+
+// HasPermission kiểm tra vai trò có một trong các quyền không. Vai trò không tồn tại -> không có quyền nào.
+func (r *RoleRepository) HasPermission(ctx context.Context, role string, permissions ...string) (bool, error) {
+	cached, err := r.cached(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if cached.permissions[p] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Permissions trả về danh sách quyền của vai trò (đã sắp xếp); vai trò không tồn tại -> danh sách rỗng
+func (r *RoleRepository) Permissions(ctx context.Context, role string) ([]string, error) {
+	cached, err := r.cached(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	permissions := make([]string, 0, len(cached.permissions))
+	for p := range cached.permissions {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// RequiresMFA kiểm tra vai trò có bắt buộc xác thực hai lớp không
+func (r *RoleRepository) RequiresMFA(ctx context.Context, role string) (bool, error) {
+	cached, err := r.cached(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	return cached.requireMFA, nil
+}
+
+func (r *RoleRepository) cached(ctx context.Context, name string) (cachedRole, error) {
+	r.mu.RLock()
+	cached, ok := r.cache[name]
+	r.mu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached, nil
+	}
+
+	cached = cachedRole{permissions: map[string]bool{}, expires: time.Now().Add(roleCacheTTL)}
+	role, err := r.FindByName(ctx, name)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return cachedRole{}, err
+	}
+	if role != nil {
+		for _, p := range role.Permissions {
+			cached.permissions[p] = true
+		}
+		cached.requireMFA = role.RequireMFA
+	}
+	r.mu.Lock()
+	r.cache[name] = cached
+	r.mu.Unlock()
+	return cached, nil
+}
+
+func (r *RoleRepository) invalidate(name string) {
+	r.mu.Lock()
+	delete(r.cache, name)
+	r.mu.Unlock()
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidRefreshToken: refresh token không tồn tại, hết hạn hoặc phiên đã bị thu hồi
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused: refresh token đã dùng bị gửi lại (có thể bị đánh cắp), cả phiên đã bị thu hồi
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionRepository lưu phiên đăng nhập và refresh token (dạng hash)
+type SessionRepository struct {
+	sessions *mongo.Collection
+	tokens   *mongo.Collection
+}
+
+func NewSessionRepository(db *mongo.Database) *SessionRepository {
+	return &SessionRepository{
+		sessions: db.Collection("sessions"),
+		tokens:   db.Collection("refresh_tokens"),
+	}
+}
+
+// EnsureIndexes tạo index tra cứu và TTL index xoá phiên, refresh token đã hết hạn
+func (r *SessionRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "deviceId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}); err != nil {
+		return err
+	}
+	_, err := r.tokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sessionId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create mở phiên mới với refresh token đầu tiên (tokenHash), hết hạn sau ttl.
+// tokenHash rỗng -> phiên không có refresh token (đăng nhập bằng PIN), hết hạn là phải đăng nhập lại.
+func (r *SessionRepository) Create(ctx context.Context, session models.Session, tokenHash string, ttl time.Duration) (*models.Session, error) {
+	now := time.Now()
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(ttl)
+	if _, err := r.sessions.InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+	if tokenHash == "" {
+		return &session, nil
+	}
+	if err := r.insertToken(ctx, session.ID, tokenHash, session.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate đổi refresh token oldHash lấy newHash trong cùng phiên. Token cũ được đánh dấu đã dùng;
+// gửi lại token đã dùng -> thu hồi cả phiên và trả về ErrRefreshTokenReused.
+func (r *SessionRepository) Rotate(ctx context.Context, oldHash, newHash string, ttl time.Duration) (*models.Session, error) {
+	now := time.Now()
+	var token models.RefreshToken
+	err := r.tokens.FindOneAndUpdate(ctx,
+		bson.M{"tokenHash": oldHash, "usedAt": bson.M{"$exists": false}, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Token đã dùng (hoặc hai request refresh cùng lúc) -> coi như bị đánh cắp, thu hồi cả họ token
+		if r.tokens.FindOne(ctx, bson.M{"tokenHash": oldHash, "usedAt": bson.M{"$exists": true}}).Decode(&token) == nil {
+			if err := r.revoke(ctx, bson.M{"_id": token.SessionID}, models.RevokeTokenReuse); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expires := now.Add(ttl)
+	var session models.Session
+	err = r.sessions.FindOneAndUpdate(ctx,
+		bson.M{"_id": token.SessionID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"lastUsedAt": now, "expiresAt": expires}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.insertToken(ctx, session.ID, newHash, expires); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IsActive kiểm tra phiên còn hiệu lực (chưa thu hồi, chưa hết hạn)
+func (r *SessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+	count, err := r.sessions.CountDocuments(ctx, bson.M{
+		"_id":       objID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// Revoke thu hồi một phiên của người dùng
+func (r *SessionRepository) Revoke(ctx context.Context, userID, sessionID, reason string) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+	return r.revoke(ctx, bson.M{"_id": objID, "userId": userID}, reason)
+}
+
+// RevokeUser thu hồi mọi phiên của người dùng
+func (r *SessionRepository) RevokeUser(ctx context.Context, userID, reason string) error {
+	return r.revoke(ctx, bson.M{"userId": userID}, reason)
+}
+
+// RevokeDevice thu hồi mọi phiên đăng nhập bằng PIN trên máy POS deviceID
+func (r *SessionRepository) RevokeDevice(ctx context.Context, deviceID, reason string) error {
+	return r.revoke(ctx, bson.M{"deviceId": deviceID}, reason)
+}
+
+// RevokeUserMethod thu hồi các phiên của người dùng đăng nhập bằng cách method (ví dụ đổi PIN -> thu hồi phiên PIN)
+func (r *SessionRepository) RevokeUserMethod(ctx context.Context, userID, method, reason string) error {
+	return r.revoke(ctx, bson.M{"userId": userID, "method": method}, reason)
+}
+
+// ListActive liệt kê phiên còn hiệu lực của người dùng, mới dùng gần nhất trước
+func (r *SessionRepository) ListActive(ctx context.Context, userID string) ([]models.Session, error) {
+	cursor, err := r.sessions.Find(ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) revoke(ctx context.Context, filter bson.M, reason string) error {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := r.sessions.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}})
+	return err
+}
+
+func (r *SessionRepository) insertToken(ctx context.Context, sessionID primitive.ObjectID, tokenHash string, expires time.Time) error {
+	_, err := r.tokens.InsertOne(ctx, models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+		CreatedAt: time.Now(),
+		ExpiresAt: expires,
+	})
+	return err
+}
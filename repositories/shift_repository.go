@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ShiftRepository quản lý ca làm việc của thu ngân
+type ShiftRepository struct {
+	collection *mongo.Collection
+}
+
+func NewShiftRepository(db *mongo.Database) *ShiftRepository {
+	return &ShiftRepository{
+		collection: db.Collection("shifts"),
+	}
+}
+
+// EnsureIndexes đảm bảo mỗi thu ngân chỉ có tối đa một ca đang mở
+func (r *ShiftRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "cashierId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.ShiftOpen}),
+		},
+		{Keys: bson.D{{Key: "cashierId", Value: 1}, {Key: "openedAt", Value: -1}}},
+	})
+	return err
+}
+
+// Open mở ca mới; trả về lỗi duplicate key nếu thu ngân đang có ca mở
+func (r *ShiftRepository) Open(ctx context.Context, shift models.Shift) (*models.Shift, error) {
+	shift.ID = primitive.NewObjectID()
+	shift.Status = models.ShiftOpen
+	shift.OpenedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	shift.ClosedAt, shift.ClosedBy, shift.CountedCash, shift.Report = nil, "", nil, nil
+	if _, err := r.collection.InsertOne(ctx, shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// FindOpenByCashier lấy ca đang mở của thu ngân
+func (r *ShiftRepository) FindOpenByCashier(ctx context.Context, cashierID string) (*models.Shift, error) {
+	var shift models.Shift
+	filter := bson.M{"cashierId": cashierID, "status": models.ShiftOpen}
+	if err := r.collection.FindOne(ctx, filter).Decode(&shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// FindByID lấy ca theo ID
+func (r *ShiftRepository) FindByID(ctx context.Context, id string) (*models.Shift, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var shift models.Shift
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// List trả về danh sách ca, mới nhất trước, kèm tổng số. cashierID rỗng -> tất cả thu ngân.
+func (r *ShiftRepository) List(ctx context.Context, cashierID string, page, limit int64) ([]models.Shift, int64, error) {
+	filter := bson.M{}
+	if cashierID != "" {
+		filter["cashierId"] = cashierID
+	}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(bson.M{"openedAt": -1})
+	if limit > 0 {
+		opts.SetSkip((page - 1) * limit).SetLimit(limit)
+	}
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	shifts := []models.Shift{}
+	if err := cursor.All(ctx, &shifts); err != nil {
+		return nil, 0, err
+	}
+	return shifts, total, nil
+}
+
+// AcquireOpen lấy ca đang mở của thu ngân và ghi nhận có một hóa đơn sắp được ghi vào ca.
+// Người gọi phải gọi ReleaseWrite sau khi ghi xong để ca có thể đóng.
+func (r *ShiftRepository) AcquireOpen(ctx context.Context, cashierID string) (*models.Shift, error) {
+	filter := bson.M{"cashierId": cashierID, "status": models.ShiftOpen}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var shift models.Shift
+	if err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"pendingWrites": 1}}, opts).Decode(&shift); err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// ReleaseWrite kết thúc lần ghi hóa đơn đã đăng ký bằng AcquireOpen
+func (r *ShiftRepository) ReleaseWrite(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$inc": bson.M{"pendingWrites": -1}})
+	return err
+}
+
+// closeWriteWait là thời gian tối đa đóng ca chờ các hóa đơn đang ghi vào ca.
+// Quá thời gian này (ví dụ server bị tắt giữa lúc ghi làm bộ đếm không về 0) thì vẫn đóng ca.
+const closeWriteWait = 5 * time.Second
+
+// Close chuyển ca đang mở sang đã đóng sau khi các hóa đơn đang ghi vào ca hoàn tất.
+// Từ lúc này không hóa đơn nào được gán thêm vào ca, báo cáo Z lập sau đó lưu bằng SetReport.
+// Trả về mongo.ErrNoDocuments nếu ca không tồn tại hoặc đã đóng.
+func (r *ShiftRepository) Close(ctx context.Context, id primitive.ObjectID, closedBy string, countedCash float64, note string) (*models.Shift, error) {
+	deadline := time.Now().Add(closeWriteWait)
+	for {
+		now := time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+		update := bson.M{"$set": bson.M{
+			"status":      models.ShiftClosed,
+			"closedAt":    now,
+			"closedBy":    closedBy,
+			"countedCash": countedCash,
+			"note":        note,
+		}}
+		filter := bson.M{"_id": id, "status": models.ShiftOpen}
+		if now.Before(deadline) {
+			filter["pendingWrites"] = bson.M{"$not": bson.M{"$gt": 0}}
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		var shift models.Shift
+		err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shift)
+		if err != mongo.ErrNoDocuments {
+			if err != nil {
+				return nil, err
+			}
+			return &shift, nil
+		}
+		// Không khớp: ca đã đóng / không tồn tại, hoặc còn hóa đơn đang ghi thì chờ rồi thử lại
+		count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id, "status": models.ShiftOpen})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, mongo.ErrNoDocuments
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// SetReport lưu báo cáo Z của ca đã đóng. Báo cáo đã lưu không bị ghi đè.
+func (r *ShiftRepository) SetReport(ctx context.Context, id primitive.ObjectID, report models.ZReport) (*models.Shift, error) {
+	filter := bson.M{"_id": id, "status": models.ShiftClosed, "report": bson.M{"$exists": false}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var shift models.Shift
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"report": report}}, opts).Decode(&shift)
+	if err == mongo.ErrNoDocuments {
+		// Báo cáo đã được lưu bởi request khác: trả về bản đã lưu
+		return r.FindByID(ctx, id.Hex())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
@@ -3,8 +3,10 @@ package repositories
 import (
 	"context"
 	"go-fiber-api/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options" // 👈 Bổ sung dòng này
 )
@@ -26,16 +28,50 @@ func (r *StoreSettingRepository) Get(ctx context.Context) (*models.StoreSetting,
 	return &result, err
 }
 
-// Ghi đè hoặc tạo mới (upsert)
-func (r *StoreSettingRepository) Upsert(ctx context.Context, setting models.StoreSetting) error {
-	_, err := r.collection.UpdateOne(ctx, bson.M{}, bson.M{
-		"$set": setting,
-	}, &options.UpdateOptions{
-		Upsert: boolPtr(true),
-	})
-	return err
-}
+// Ghi đè hoặc tạo mới (upsert) và trả về bản ghi sau khi lưu.
+// setting.Version là version client đã đọc; trả về ErrVersionConflict nếu thông tin cửa hàng đã bị sửa trước đó.
+func (r *StoreSettingRepository) Upsert(ctx context.Context, setting models.StoreSetting) (*models.StoreSetting, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"storeName":        setting.StoreName,
+			"address":          setting.Address,
+			"phone":            setting.Phone,
+			"logoUrl":          setting.LogoUrl,
+			"taxCode":          setting.TaxCode,
+			"email":            setting.Email,
+			"einvoiceTemplate": setting.EInvoiceTemplate,
+			"einvoiceSeries":   setting.EInvoiceSeries,
+			"defaultVatRate":   setting.DefaultVATRate,
+			"updatedAt":        time.Now().In(time.FixedZone("GMT+7", 7*60*60)),
+			"updatedBy":        setting.UpdatedBy,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var saved models.StoreSetting
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"version": versionFilter(setting.Version)}, update, opts).Decode(&saved)
+	if err == nil {
+		return &saved, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
 
-func boolPtr(b bool) *bool {
-	return &b
+	// Không khớp version: nếu đã có bản ghi thì là xung đột, nếu chưa có thì tạo mới
+	count, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 || setting.Version != 0 {
+		return nil, ErrVersionConflict
+	}
+	setting.ID = primitive.NewObjectID()
+	setting.Numbering = nil // Cấu hình đánh số chỉ sửa qua API riêng
+	setting.Version = 1
+	setting.UpdatedAt = time.Now().In(time.FixedZone("GMT+7", 7*60*60))
+	if _, err := r.collection.InsertOne(ctx, setting); err != nil {
+		return nil, err
+	}
+	return &setting, nil
 }
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-fiber-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TombstoneRepository lưu dấu vết các bản ghi đã xoá, tự hết hạn sau retention
+type TombstoneRepository struct {
+	collection *mongo.Collection
+	retention  time.Duration
+}
+
+func NewTombstoneRepository(db *mongo.Database, retention time.Duration) *TombstoneRepository {
+	return &TombstoneRepository{
+		collection: db.Collection("tombstones"),
+		retention:  retention,
+	}
+}
+
+// Retention là thời gian giữ tombstone; client có cursor cũ hơn phải đồng bộ lại toàn bộ
+func (r *TombstoneRepository) Retention() time.Duration {
+	return r.retention
+}
+
+// EnsureIndexes tạo TTL index trên deletedAt
+func (r *TombstoneRepository) EnsureIndexes(ctx context.Context) error {
+	return ensureTTLIndex(ctx, r.collection, "deletedAt", r.retention)
+}
+
+// Record ghi tombstone cho danh sách ID vừa bị xoá khỏi collection
+func (r *TombstoneRepository) Record(ctx context.Context, collection string, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := make([]any, 0, len(ids))
+	for _, id := range ids {
+		docs = append(docs, models.Tombstone{Collection: collection, DocID: id, DeletedAt: now})
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+// ListSince trả về các tombstone của collection được tạo từ thời điểm since
+func (r *TombstoneRepository) ListSince(ctx context.Context, collection string, since time.Time) ([]models.Tombstone, error) {
+	filter := bson.M{"collection": collection, "deletedAt": bson.M{"$gte": since}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"deletedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	tombstones := []models.Tombstone{}
+	if err := cursor.All(ctx, &tombstones); err != nil {
+		return nil, err
+	}
+	return tombstones, nil
+}
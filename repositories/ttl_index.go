@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mã lỗi MongoDB khi quản lý index
+const (
+	namespaceNotFound    = 26 // Collection chưa tồn tại
+	indexNotFound        = 27 // Index cần xoá không tồn tại
+	indexOptionsConflict = 85 // Index cùng khoá đã tồn tại với tuỳ chọn khác
+)
+
+// ensureTTLIndex tạo TTL index trên field. Nếu index đã có với thời gian hết hạn khác (đổi biến môi trường)
+// thì cập nhật expireAfterSeconds bằng collMod thay vì giữ giá trị cũ.
+func ensureTTLIndex(ctx context.Context, collection *mongo.Collection, field string, ttl time.Duration) error {
+	seconds := int32(ttl.Seconds())
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+	var serverErr mongo.ServerError
+	if err == nil || !errors.As(err, &serverErr) || !serverErr.HasErrorCode(indexOptionsConflict) {
+		return err
+	}
+	return collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "keyPattern", Value: bson.D{{Key: field, Value: 1}}},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}).Err()
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"go-fiber-api/config"
 	"go-fiber-api/models"
@@ -24,6 +25,8 @@ func FindUserByUsername(username string) (*models.User, error) {
 // CreateUser tạo mới một user
 func CreateUser(user *models.User) error {
 	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
 	_, err := config.DB.Collection("users").InsertOne(context.TODO(), user)
 	return err
 }
@@ -37,16 +40,24 @@ func IsUsernameExists(username string) (bool, error) {
 	return count > 0, nil
 }
 
-// Lấy danh sách user theo role (nếu có)
-func GetUsersByRole(role string) ([]models.User, error) {
+// Lấy danh sách user theo role (nếu có) và trạng thái: active | disabled | rỗng (tất cả)
+func GetUsersByRole(role, status string) ([]models.User, error) {
 	filter := bson.M{}
 	if role != "" {
 		filter["role"] = role
 	}
+	switch status {
+	case "active":
+		filter["disabled"] = bson.M{"$ne": true}
+	case "disabled":
+		filter["disabled"] = true
+	}
 
-	// Projection: loại bỏ trường password
+	// Projection: loại bỏ trường password, pin, lịch sử mật khẩu
 	projection := bson.M{
-		"password": 0, // 0 = không lấy trường này
+		"password":        0, // 0 = không lấy trường này
+		"pin":             0,
+		"passwordHistory": 0,
 	}
 
 	opts := options.Find().SetProjection(projection)
@@ -69,18 +80,85 @@ func GetUsersByRole(role string) ([]models.User, error) {
 	return users, nil
 }
 
-func UpdateUserPassword(id string, hashedPassword string) error {
+// UpdateUserPassword lưu mật khẩu mới do chính user đặt và lịch sử mật khẩu, bỏ cờ bắt buộc đổi mật khẩu
+func UpdateUserPassword(id string, hashedPassword string, history []string) error {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
+	now := time.Now()
 	filter := bson.M{"_id": objID}
-	update := bson.M{"$set": bson.M{"password": hashedPassword}}
+	update := bson.M{
+		"$set":   bson.M{"password": hashedPassword, "passwordHistory": history, "passwordChangedAt": now, "updatedAt": now},
+		"$unset": bson.M{"mustChangePassword": "", "passwordExpiresAt": ""},
+	}
 	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), filter, update)
 	return err
 }
 
-// UpdateUser cập nhật thông tin cơ bản của user (username, role)
+// SetTemporaryPassword lưu mật khẩu tạm do admin đặt lại: hết hạn lúc expires, phải đổi ngay sau khi đăng nhập
+func SetTemporaryPassword(id string, hashedPassword string, expires time.Time, history []string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{
+		"password":           hashedPassword,
+		"passwordHistory":    history,
+		"mustChangePassword": true,
+		"passwordExpiresAt":  expires,
+		"updatedAt":          time.Now(),
+	}}
+	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
+	return err
+}
+
+// RehashUserSecret thay hash mật khẩu ("password") hoặc PIN ("pin") bằng hash mới cùng giá trị sau khi đổi
+// thuật toán / tham số băm. Chỉ cập nhật nếu hash chưa bị đổi từ lúc đọc.
+func RehashUserSecret(id primitive.ObjectID, field, oldHash, newHash string) error {
+	filter := bson.M{"_id": id, field: oldHash}
+	_, err := config.DB.Collection("users").UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{field: newHash}})
+	return err
+}
+
+// TouchLastLogin ghi nhận thời điểm đăng nhập thành công gần nhất
+func TouchLastLogin(id primitive.ObjectID) error {
+	_, err := config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"lastLoginAt": time.Now()}})
+	return err
+}
+
+// UpdateUserPIN lưu hash PIN của user; hash rỗng -> xoá PIN
+func UpdateUserPIN(id string, hashedPIN string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"pin": hashedPIN}}
+	if hashedPIN == "" {
+		update = bson.M{"$unset": bson.M{"pin": ""}}
+	}
+	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
+	return err
+}
+
+// GetUsersWithPIN lấy danh sách user đã đặt PIN (chỉ id, username, role) cho màn hình chọn thu ngân trên máy POS
+func GetUsersWithPIN() ([]models.User, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"username": 1, "role": 1}).
+		SetSort(bson.D{{Key: "username", Value: 1}})
+	filter := bson.M{"pin": bson.M{"$exists": true}, "disabled": bson.M{"$ne": true}}
+	cursor, err := config.DB.Collection("users").Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(context.TODO(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUser cập nhật thông tin cơ bản của user (username, role, họ tên, số điện thoại)
 func UpdateUser(id string, user models.User) error {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -88,23 +166,42 @@ func UpdateUser(id string, user models.User) error {
 	}
 	filter := bson.M{"_id": objID}
 	update := bson.M{"$set": bson.M{
-		"username": user.Username,
-		"role":     user.Role,
+		"username":  user.Username,
+		"role":      user.Role,
+		"fullName":  user.FullName,
+		"phone":     user.Phone,
+		"updatedAt": time.Now(),
 	}}
 	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), filter, update)
 	return err
 }
 
-// DeleteUsers xoá nhiều user theo danh sách ID
-func DeleteUsers(ids []string) error {
+// DisableUsers vô hiệu hoá nhiều user theo danh sách ID (không xoá để hóa đơn, ca cũ vẫn tra được thu ngân)
+func DisableUsers(ids []string) error {
 	var objIDs []primitive.ObjectID
 	for _, id := range ids {
 		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
 			objIDs = append(objIDs, objID)
 		}
 	}
-	filter := bson.M{"_id": bson.M{"$in": objIDs}}
-	_, err := config.DB.Collection("users").DeleteMany(context.TODO(), filter)
+	now := time.Now()
+	filter := bson.M{"_id": bson.M{"$in": objIDs}, "disabled": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"disabled": true, "disabledAt": now, "updatedAt": now}}
+	_, err := config.DB.Collection("users").UpdateMany(context.TODO(), filter, update)
+	return err
+}
+
+// EnableUser kích hoạt lại user đã bị vô hiệu hoá
+func EnableUser(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set":   bson.M{"disabled": false, "updatedAt": time.Now()},
+		"$unset": bson.M{"disabledAt": ""},
+	}
+	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": objID}, update)
 	return err
 }
 
@@ -124,3 +221,51 @@ func FindUserByID(id string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// CountUsersByRole đếm số user đang được gán vai trò
+func CountUsersByRole(role string) (int64, error) {
+	return config.DB.Collection("users").CountDocuments(context.TODO(), bson.M{"role": role})
+}
+
+// RoleExists kiểm tra vai trò có trong collection roles không
+func RoleExists(role string) (bool, error) {
+	count, err := config.DB.Collection("roles").CountDocuments(context.TODO(), bson.M{"name": role})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindRole lấy vai trò theo tên; không có -> mongo.ErrNoDocuments
+func FindRole(name string) (*models.Role, error) {
+	var role models.Role
+	if err := config.DB.Collection("roles").FindOne(context.TODO(), bson.M{"name": name}).Decode(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CountActiveUsersByRole đếm user đang hoạt động có vai trò role, bỏ qua các user trong excludeIDs
+func CountActiveUsersByRole(role string, excludeIDs []string) (int64, error) {
+	var objIDs []primitive.ObjectID
+	for _, id := range excludeIDs {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	filter := bson.M{"role": role, "disabled": bson.M{"$ne": true}}
+	if len(objIDs) > 0 {
+		filter["_id"] = bson.M{"$nin": objIDs}
+	}
+	return config.DB.Collection("users").CountDocuments(context.TODO(), filter)
+}
+
+// RevokeUserSessions thu hồi mọi phiên đăng nhập của user (đổi mật khẩu, đổi vai trò, xoá user)
+func RevokeUserSessions(userID, reason string) error {
+	return NewSessionRepository(config.DB).RevokeUser(context.TODO(), userID, reason)
+}
+
+// RevokeUserAPIKeys thu hồi mọi API key do người dùng tạo (khi người dùng bị vô hiệu hoá)
+func RevokeUserAPIKeys(userID, reason string) error {
+	return NewAPIKeyRepository(config.DB).RevokeCreatedBy(context.TODO(), userID, reason)
+}
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrVersionConflict được trả về khi version client gửi lên không còn khớp với bản ghi hiện tại
+// (đã có người khác cập nhật trước).
+var ErrVersionConflict = errors.New("version conflict")
+
+// versionFilter tạo điều kiện so khớp version. Bản ghi cũ chưa có trường version được coi là version 0.
+func versionFilter(version int64) any {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
@@ -1,48 +1,344 @@
 package routes
 
 import (
+	"context"
+	"go-fiber-api/config"
 	"go-fiber-api/controllers"
+	"go-fiber-api/einvoice"
 	"go-fiber-api/middleware"
+	"go-fiber-api/models"
 	"go-fiber-api/repositories"
+	"go-fiber-api/utils"
+	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Setup cấu hình toàn bộ route cho ứng dụng
 func Setup(app *fiber.App, db *mongo.Database) {
-	app.Post("/login", controllers.Login) // POST /login -> đăng nhập
-	app.Get("/test", controllers.Hello)   // GET /test -> test không cần token
-	api := app.Group("/api", middleware.Protected())
-	api.Get("/test2", controllers.Hello)                // GET /api/test2 -> test có token
-	api.Put("/presigned_url", controllers.GetUploadUrl) // PUT /api/presigned_url -> lấy URL upload ảnh (logo,...)
+	app.Use(requestid.New()) // Header X-Request-ID, ghi kèm nhật ký thao tác
+	// Nhật ký thao tác: gắn cho mọi route, đặt trước Idempotency (xem middleware.AuditTrail)
+	auditRepo := repositories.NewAuditRepository(db, loadAuditKey())
+	requireIndexes("audit_logs", auditRepo)
+	go sealAuditLogs(auditRepo)
+	app.Use(middleware.AuditTrail(auditRepo))
+
+	// === Auth routes: access token ngắn hạn + refresh token xoay vòng theo phiên ===
+	sessionRepo := repositories.NewSessionRepository(db)
+	ensureIndexes("sessions", sessionRepo)
+	jwtKeys := loadJWTKeys()
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	ensureIndexes("api_keys", apiKeyRepo)
+	protected := middleware.Protected(jwtKeys, sessionRepo, apiKeyRepo) // Nhận access token hoặc header X-API-Key
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db, repositories.LoginPolicy{
+		MaxFailures:     config.GetEnvInt("LOGIN_MAX_FAILURES", 5),
+		LockDuration:    time.Duration(config.GetEnvInt("LOGIN_LOCK_MINUTES", 15)) * time.Minute,
+		PINMaxFailures:  config.GetEnvInt("PIN_MAX_FAILURES", 5),
+		PINLockDuration: time.Duration(config.GetEnvInt("PIN_LOCK_MINUTES", 30)) * time.Minute,
+	})
+	ensureIndexes("login_attempts", loginAttemptRepo)
+	roleRepo := repositories.NewRoleRepository(db)
+	mfaIssuer := os.Getenv("MFA_ISSUER")
+	if mfaIssuer == "" {
+		mfaIssuer = "POS"
+	}
+	mfaController := controllers.NewMFAController(repositories.NewMFARepository(db), roleRepo, mfaIssuer)
+	authController := controllers.NewAuthController(jwtKeys, sessionRepo, loginAttemptRepo, mfaController,
+		time.Duration(config.GetEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15))*time.Minute,
+		time.Duration(config.GetEnvInt("REFRESH_TOKEN_TTL_DAYS", 30))*24*time.Hour,
+	)
+	app.Post("/login", authController.Login)                   // POST /login -> đăng nhập, trả access token + refresh token (hoặc challengeToken nếu cần 2FA)
+	app.Post("/login/2fa", authController.LoginMFA)            // POST /login/2fa -> nhập mã TOTP / mã dự phòng để hoàn tất đăng nhập
+	app.Post("/login/2fa/setup", authController.LoginMFASetup) // POST /login/2fa/setup -> đăng ký 2FA khi vai trò bắt buộc mà chưa đăng ký
+	// Đăng nhập nhanh bằng PIN trên máy POS đã đăng ký (header X-Device-Token)
+	deviceRepo := repositories.NewDeviceRepository(db)
+	ensureIndexes("devices", deviceRepo)
+	pinController := controllers.NewPINController(authController, deviceRepo,
+		time.Duration(config.GetEnvInt("PIN_SESSION_TTL_MINUTES", 30))*time.Minute)
+	app.Post("/login/pin", pinController.Login)            // POST /login/pin -> đăng nhập bằng username + PIN, phiên ngắn, quyền bán hàng
+	app.Get("/login/pin/users", pinController.Users)       // GET /login/pin/users -> thu ngân đã đặt PIN (màn hình chọn người trên máy POS)
+	app.Post("/refresh", authController.Refresh)           // POST /refresh -> đổi refresh token lấy cặp token mới
+	app.Post("/logout", protected, authController.Logout)  // POST /logout?all=true -> thu hồi phiên hiện tại / mọi phiên
+	app.Get("/test", controllers.Hello)                    // GET /test -> test không cần token
+	app.Get("/.well-known/jwks.json", authController.JWKS) // GET /.well-known/jwks.json -> public key xác thực access token
+	// Đăng nhập bằng mật khẩu tạm (admin đặt lại, admin ban đầu) chỉ được đổi mật khẩu và xem thông tin của mình
+	api := app.Group("/api", protected, middleware.PasswordChange("/api/users/password", "/api/me"))
+	api.Get("/me", authController.Me)             // GET /api/me -> thông tin người dùng hiện tại, quyền của phiên
+	api.Get("/sessions", authController.Sessions) // GET /api/sessions -> phiên đăng nhập còn hiệu lực của mình
+
+	// === Phân quyền: mỗi route khai báo quyền cần có, vai trò và quyền lưu trong collection roles ===
+	ensureIndexes("roles", roleRepo)
+	if err := roleRepo.EnsureDefaults(context.TODO()); err != nil {
+		log.Printf("⚠️ Không tạo được vai trò mặc định: %v\n", err)
+	}
+	if created, err := roleRepo.AdoptLegacyRoles(context.TODO()); err != nil {
+		log.Printf("⚠️ Không chuyển được vai trò cũ của người dùng: %v\n", err)
+	} else if len(created) > 0 {
+		log.Printf("⚠️ Đã tạo vai trò %v (quyền thu ngân) cho người dùng có vai trò từ trước khi có phân quyền, kiểm tra lại tại /api/roles\n", created)
+	}
+	can := middleware.NewAuthorizer(roleRepo).Require
+	passwordOnly := middleware.PasswordSession() // Chặn phiên PIN ở route không khai báo quyền nhưng nhạy cảm
+
+	api.Get("/test2", controllers.Hello)                                                                        // GET /api/test2 -> test có token
+	api.Put("/presigned_url", can(models.PermProductWrite, models.PermSettingsWrite), controllers.GetUploadUrl) // PUT /api/presigned_url -> lấy URL upload ảnh (logo,...)
 
 	usersGroup := api.Group("/users")
 
-	usersGroup.Post("/", controllers.CreateUser)                // Tạo user mới
-	usersGroup.Get("/", controllers.GetUsersByRole)             // Lấy danh sách user theo role (?role=)
-	usersGroup.Put("/password", controllers.ChangeUserPassword) // Đổi mật khẩu (kiểm tra mật khẩu cũ)
-	usersGroup.Put("/", controllers.UpdateUser)                 // Cập nhật thông tin cơ bản của user
-	usersGroup.Delete("/", controllers.DeleteUsers)             // Xoá user, chỉ admin được phép
+	usersGroup.Post("/", can(models.PermUserManage), controllers.CreateUser)                          // Tạo user mới
+	usersGroup.Get("/", can(models.PermUserManage), controllers.GetUsersByRole)                       // Lấy danh sách user theo role, trạng thái (?role=&status=active|disabled)
+	usersGroup.Put("/password", passwordOnly, controllers.ChangeUserPassword)                         // Đổi mật khẩu của chính mình (kiểm tra mật khẩu cũ)
+	usersGroup.Put("/pin", passwordOnly, pinController.SetPIN)                                        // Đặt / đổi PIN đăng nhập trên máy POS (cần mật khẩu)
+	usersGroup.Delete("/pin", passwordOnly, pinController.RemovePIN)                                  // Xoá PIN
+	usersGroup.Get("/2fa", mfaController.Status)                                                      // Trạng thái 2FA của mình
+	usersGroup.Post("/2fa/setup", passwordOnly, mfaController.Setup)                                  // Tạo khoá TOTP, trả provisioning URI cho mã QR
+	usersGroup.Post("/2fa/enable", passwordOnly, mfaController.Enable)                                // Kích hoạt 2FA bằng mã đầu tiên, nhận mã dự phòng
+	usersGroup.Post("/2fa/disable", passwordOnly, mfaController.Disable)                              // Tắt 2FA (cần mật khẩu + mã)
+	usersGroup.Post("/2fa/backup-codes", passwordOnly, mfaController.BackupCodes)                     // Tạo lại mã dự phòng
+	usersGroup.Delete("/:id/2fa", can(models.PermUserManage), mfaController.Reset)                    // Tắt 2FA của người dùng mất thiết bị
+	usersGroup.Put("/", can(models.PermUserManage), controllers.UpdateUser)                           // Cập nhật thông tin cơ bản của user
+	usersGroup.Delete("/", can(models.PermUserManage), controllers.DeleteUsers)                       // Vô hiệu hoá user (không xoá hẳn)
+	usersGroup.Post("/:id/enable", can(models.PermUserManage), controllers.EnableUser)                // Kích hoạt lại user đã vô hiệu hoá
+	usersGroup.Post("/:id/reset-password", can(models.PermUserManage), controllers.ResetUserPassword) // Đặt mật khẩu tạm, bắt buộc đổi khi đăng nhập
+	usersGroup.Get("/login-attempts", can(models.PermUserManage), authController.LoginAttempts)       // Nhật ký đăng nhập (?username=&ip=&page=&limit=)
+	usersGroup.Post("/:id/unlock", can(models.PermUserManage), authController.Unlock)                 // Mở khoá tài khoản bị khoá do đăng nhập sai
+
+	// === Role routes (vai trò và quyền) ===
+	roleController := controllers.NewRoleController(roleRepo)
+	roles := api.Group("/roles")
+	roles.Get("/permissions", can(models.PermRoleManage, models.PermUserManage), roleController.Permissions) // GET /api/roles/permissions -> danh sách quyền
+	roles.Get("/", can(models.PermRoleManage, models.PermUserManage), roleController.List)                   // GET /api/roles -> danh sách vai trò và quyền
+	roles.Post("/", can(models.PermRoleManage), roleController.Create)                                       // POST /api/roles -> tạo vai trò
+	roles.Put("/:name", can(models.PermRoleManage), roleController.Update)                                   // PUT /api/roles/:name -> sửa quyền của vai trò
+	roles.Put("/:name/mfa", can(models.PermRoleManage), roleController.SetMFA)                               // PUT /api/roles/:name/mfa -> bắt buộc / bỏ bắt buộc 2FA
+	roles.Delete("/:name", can(models.PermRoleManage), roleController.Delete)                                // DELETE /api/roles/:name -> xoá vai trò chưa gán cho ai
+
+	// === Device routes (máy POS đăng nhập bằng PIN) ===
+	deviceController := controllers.NewDeviceController(deviceRepo, sessionRepo)
+	devices := api.Group("/devices")
+	devices.Get("/", can(models.PermDeviceManage), deviceController.List)         // GET /api/devices -> danh sách máy POS
+	devices.Post("/", can(models.PermDeviceManage), deviceController.Create)      // POST /api/devices -> đăng ký máy, trả device token (một lần)
+	devices.Delete("/:id", can(models.PermDeviceManage), deviceController.Revoke) // DELETE /api/devices/:id -> thu hồi máy và phiên PIN trên máy
+
+	// === API key routes (tích hợp máy - máy gửi header X-API-Key) ===
+	apiKeyController := controllers.NewAPIKeyController(apiKeyRepo)
+	apiKeys := api.Group("/api-keys")
+	apiKeys.Get("/", can(models.PermAPIKeyManage), apiKeyController.List)         // GET /api/api-keys -> danh sách API key
+	apiKeys.Post("/", can(models.PermAPIKeyManage), apiKeyController.Create)      // POST /api/api-keys -> tạo khoá, trả giá trị khoá (một lần)
+	apiKeys.Delete("/:id", can(models.PermAPIKeyManage), apiKeyController.Revoke) // DELETE /api/api-keys/:id -> thu hồi khoá
+
+	// === Nhật ký thao tác (chỉ ghi thêm, chuỗi HMAC chống sửa) ===
+	auditController := controllers.NewAuditController(auditRepo)
+	auditLogs := api.Group("/audit-logs")
+	auditLogs.Get("/", can(models.PermAuditRead), auditController.List)         // GET /api/audit-logs?actorId=&action=&targetType=&targetId=&from=&to=&page=&limit=
+	auditLogs.Get("/verify", can(models.PermAuditRead), auditController.Verify) // GET /api/audit-logs/verify -> kiểm tra chuỗi hash
+
+	// === Repositories dùng chung giữa các nhóm route ===
+	tombstoneRepo := repositories.NewTombstoneRepository(db, time.Duration(config.GetEnvInt("SYNC_TOMBSTONE_TTL_DAYS", 90))*24*time.Hour)
+	productRepo := repositories.NewProductRepository(db, tombstoneRepo)
+	invoiceRepo := repositories.NewInvoiceRepository(db)
+	settingRepo := repositories.NewStoreSettingRepository(db)
+	ensureIndexes("tombstones", tombstoneRepo)
+	ensureIndexes("products", productRepo)
+	requireIndexes("invoices", invoiceRepo)
+	einvoiceRepo := repositories.NewEInvoiceRepository(db)
+	requireIndexes("einvoices", einvoiceRepo)
+
 	// === Product routes ===
-	productController := controllers.NewProductController(repositories.NewProductRepository(db))
+	productController := controllers.NewProductController(productRepo)
 	products := api.Group("/products")
-	products.Get("/", productController.List)      // GET /api/products?page=1&limit=10&search=abc -> danh sách sản phẩm
-	products.Post("/", productController.Create)   // POST /api/products -> tạo sản phẩm
-	products.Put("/", productController.Update)    // PUT /api/products -> cập nhật sản phẩm (ID trong body)
-	products.Delete("/", productController.Delete) // DELETE /api/products?id=abc,def -> xóa nhiều sản phẩm
+	products.Get("/", can(models.PermProductRead), productController.List)       // GET /api/products?page=1&limit=10&search=abc -> danh sách sản phẩm
+	products.Post("/", can(models.PermProductWrite), productController.Create)   // POST /api/products -> tạo sản phẩm
+	products.Put("/", can(models.PermProductWrite), productController.Update)    // PUT /api/products -> cập nhật sản phẩm (ID trong body)
+	products.Delete("/", can(models.PermProductWrite), productController.Delete) // DELETE /api/products?id=abc,def -> xóa nhiều sản phẩm
 
 	// === Invoice routes ===
-	invoiceController := controllers.NewInvoiceController(repositories.NewInvoiceRepository(db))
+	shiftRepo := repositories.NewShiftRepository(db)
+	ensureIndexes("shifts", shiftRepo)
+	invoiceRevisionRepo := repositories.NewInvoiceRevisionRepository(db)
+	ensureIndexes("invoice_revisions", invoiceRevisionRepo)
+	invoiceController := controllers.NewInvoiceController(invoiceRepo, invoiceRevisionRepo, shiftRepo, einvoiceRepo)
+	// Idempotency-Key giúp máy POS mất mạng gửi lại request tạo hóa đơn mà không bị trùng
+	idempotencyRepo := repositories.NewIdempotencyRepository(db,
+		time.Duration(config.GetEnvInt("IDEMPOTENCY_TTL_HOURS", 24))*time.Hour,
+		time.Duration(config.GetEnvInt("IDEMPOTENCY_LEASE_SECONDS", 60))*time.Second)
+	ensureIndexes("idempotency_keys", idempotencyRepo)
+	idempotent := middleware.Idempotency(idempotencyRepo)
 	invoices := api.Group("/invoices")
-	invoices.Post("/", invoiceController.Create)      // POST /api/invoices -> tạo hóa đơn
-	invoices.Delete("/", invoiceController.Delete)    // DELETE /api/invoices?id=abc,def -> xóa hóa đơn
-	invoices.Get("/", invoiceController.FilterByDate) // GET /api/invoices?from=dd/mm/yyyy&to=dd/mm/yyyy&page=1&limit=10 -> lọc hóa đơn theo ngày
-	invoices.Put("/", invoiceController.Update)       // PUT /api/invoices -> cập nhật hóa đơn (ID trong body)
+	invoices.Post("/", can(models.PermInvoiceCreate), idempotent, invoiceController.Create) // POST /api/invoices -> tạo hóa đơn (hỗ trợ header Idempotency-Key)
+	invoices.Delete("/", can(models.PermInvoiceDelete), invoiceController.Delete)           // DELETE /api/invoices?id=abc,def -> xóa hẳn hóa đơn đã huỷ
+	invoices.Get("/", can(models.PermInvoiceRead), invoiceController.FilterByDate)          // GET /api/invoices?from=dd/mm/yyyy&to=dd/mm/yyyy&page=1&limit=10 -> lọc hóa đơn theo ngày
+	invoices.Get("/export", can(models.PermInvoiceExport), invoiceController.Export)        // GET /api/invoices/export?format=xlsx&layout=item&from=...&to=... -> xuất CSV/XLSX
+	invoices.Put("/", can(models.PermInvoiceUpdate), invoiceController.Update)              // PUT /api/invoices -> cập nhật hóa đơn (ID trong body)
+	invoices.Get("/:id/history", can(models.PermInvoiceRead), invoiceController.History)    // GET /api/invoices/:id/history -> lịch sử chỉnh sửa hóa đơn
+	invoices.Post("/:id/void", can(models.PermInvoiceVoid), invoiceController.Void)         // POST /api/invoices/:id/void -> huỷ hóa đơn
+	invoices.Post("/:id/return", can(models.PermInvoiceVoid), invoiceController.Return)     // POST /api/invoices/:id/return -> khách trả hàng, hoàn tiền
+
+	// === Report routes ===
+	reportRepo := repositories.NewReportRepository(db)
+	reportController := controllers.NewReportController(reportRepo)
+	reports := api.Group("/reports")
+	reports.Get("/dashboard", can(models.PermReportRead), reportController.Dashboard) // GET /api/reports/dashboard?weeks=4 -> tổng quan bán hàng
+	reports.Get("/staff", can(models.PermReportRead), reportController.Staff)         // GET /api/reports/staff?from=dd/mm/yyyy&to=dd/mm/yyyy -> doanh số theo nhân viên
+
+	// === Shift routes (ca thu ngân) ===
+	shiftController := controllers.NewShiftController(shiftRepo, reportRepo)
+	shifts := api.Group("/shifts")
+	shifts.Post("/open", can(models.PermShiftOperate), shiftController.Open)      // POST /api/shifts/open -> mở ca với tiền đầu ca
+	shifts.Get("/current", can(models.PermShiftOperate), shiftController.Current) // GET /api/shifts/current -> ca đang mở + báo cáo Z tạm tính
+	shifts.Post("/close", can(models.PermShiftOperate), shiftController.Close)    // POST /api/shifts/close -> đóng ca, chốt báo cáo Z
+	shifts.Get("/", can(models.PermShiftRead), shiftController.List)              // GET /api/shifts?cashierId=abc&page=1&limit=10 -> danh sách ca
+	shifts.Get("/:id", can(models.PermShiftRead), shiftController.Get)            // GET /api/shifts/:id -> chi tiết ca và báo cáo Z
+
+	// === Held order routes (giỏ hàng tạm giữ) ===
+	heldOrderRepo := repositories.NewHeldOrderRepository(db, time.Duration(config.GetEnvInt("HELD_ORDER_TTL_HOURS", 24))*time.Hour)
+	ensureIndexes("held_orders", heldOrderRepo)
+	heldOrderController := controllers.NewHeldOrderController(heldOrderRepo, invoiceController)
+	heldOrders := api.Group("/held-orders")
+	heldOrders.Get("/", can(models.PermInvoiceCreate), heldOrderController.List)                            // GET /api/held-orders?mine=true -> danh sách giỏ đang tạm giữ
+	heldOrders.Post("/", can(models.PermInvoiceCreate), heldOrderController.Create)                         // POST /api/held-orders -> tạm giữ giỏ hàng
+	heldOrders.Get("/:id", can(models.PermInvoiceCreate), heldOrderController.Get)                          // GET /api/held-orders/:id -> xem giỏ tạm giữ
+	heldOrders.Post("/:id/resume", can(models.PermInvoiceCreate), heldOrderController.Resume)               // POST /api/held-orders/:id/resume -> lấy giỏ ra để bán tiếp
+	heldOrders.Delete("/:id", can(models.PermInvoiceCreate), heldOrderController.Discard)                   // DELETE /api/held-orders/:id -> huỷ giỏ
+	heldOrders.Post("/:id/convert", can(models.PermInvoiceCreate), idempotent, heldOrderController.Convert) // POST /api/held-orders/:id/convert -> chuyển thành hóa đơn
+
+	// === Quotation routes (báo giá) ===
+	quotationRepo := repositories.NewQuotationRepository(db)
+	requireIndexes("quotations", quotationRepo)
+	quotationController := controllers.NewQuotationController(quotationRepo, settingRepo, invoiceController)
+	quotations := api.Group("/quotations")
+	quotations.Get("/", can(models.PermQuotationRead), quotationController.List)                             // GET /api/quotations?status=sent&page=1&limit=10 -> danh sách báo giá
+	quotations.Post("/", can(models.PermQuotationWrite), quotationController.Create)                         // POST /api/quotations -> tạo báo giá
+	quotations.Get("/:id", can(models.PermQuotationRead), quotationController.Get)                           // GET /api/quotations/:id -> chi tiết báo giá
+	quotations.Get("/:id/html", can(models.PermQuotationRead), quotationController.Render)                   // GET /api/quotations/:id/html -> bản in báo giá
+	quotations.Put("/:id/status", can(models.PermQuotationWrite), quotationController.UpdateStatus)          // PUT /api/quotations/:id/status -> khách đồng ý / từ chối
+	quotations.Post("/:id/convert", can(models.PermQuotationWrite), idempotent, quotationController.Convert) // POST /api/quotations/:id/convert -> chuyển thành hóa đơn
+
+	// === E-invoice routes (hóa đơn điện tử) ===
+	einvoiceSubmitter := newEInvoiceSubmitter(einvoiceRepo)
+	einvoiceController := controllers.NewEInvoiceController(einvoiceRepo, invoiceRepo, settingRepo, quotationRepo, loadEInvoiceSigner(), einvoiceSubmitter)
+	invoices.Post("/:id/einvoice", can(models.PermEInvoiceIssue), einvoiceController.Issue)           // POST /api/invoices/:id/einvoice -> lập, ký và gửi hóa đơn điện tử
+	invoices.Get("/:id/einvoice", can(models.PermInvoiceRead), einvoiceController.Get)                // GET /api/invoices/:id/einvoice -> thông tin hóa đơn điện tử, trạng thái gửi
+	invoices.Get("/:id/einvoice/xml", can(models.PermInvoiceRead), einvoiceController.XML)            // GET /api/invoices/:id/einvoice/xml -> tải XML đã ký
+	invoices.Get("/:id/einvoice/status", can(models.PermInvoiceRead), einvoiceController.Status)      // GET /api/invoices/:id/einvoice/status -> tra cứu trạng thái phía nhà cung cấp
+	invoices.Post("/:id/einvoice/replace", can(models.PermEInvoiceIssue), einvoiceController.Replace) // POST /api/invoices/:id/einvoice/replace -> lập hóa đơn thay thế
+	invoices.Post("/:id/einvoice/cancel", can(models.PermEInvoiceIssue), einvoiceController.Cancel)   // POST /api/invoices/:id/einvoice/cancel -> huỷ hóa đơn điện tử
+	invoices.Post("/:id/einvoice/retry", can(models.PermEInvoiceIssue), einvoiceController.Retry)     // POST /api/invoices/:id/einvoice/retry -> gửi lại thao tác bị dừng
 
 	// === Store setting routes ===
-	settingCtrl := controllers.NewStoreSettingController(repositories.NewStoreSettingRepository(db))
+	settingCtrl := controllers.NewStoreSettingController(settingRepo, repositories.NewNumberingRepository(db))
 	settings := api.Group("/settings")
-	settings.Get("/", settingCtrl.Get)    // GET /api/settings -> lấy thông tin cửa hàng
-	settings.Put("/", settingCtrl.Upsert) // PUT /api/settings -> cập nhật thông tin cửa hàng (tên, SĐT, logo)
+	settings.Get("/", settingCtrl.Get)                                                                       // GET /api/settings -> lấy thông tin cửa hàng
+	settings.Put("/", can(models.PermSettingsWrite), settingCtrl.Upsert)                                     // PUT /api/settings -> cập nhật thông tin cửa hàng (tên, SĐT, logo)
+	settings.Get("/numbering", can(models.PermSettingsWrite), settingCtrl.ListNumbering)                     // GET /api/settings/numbering -> cấu hình đánh số chứng từ
+	settings.Put("/numbering/:docType", can(models.PermSettingsWrite), settingCtrl.SaveNumbering)            // PUT /api/settings/numbering/invoice -> đổi cấu hình đánh số
+	settings.Get("/numbering/:docType/preview", can(models.PermSettingsWrite), settingCtrl.PreviewNumbering) // GET /api/settings/numbering/invoice/preview?count=5 -> xem trước mã tiếp theo
+
+	// === Offline sync routes ===
+	syncController := controllers.NewSyncController(invoiceController, invoiceRepo, productRepo, settingRepo, tombstoneRepo)
+	api.Post("/sync", can(models.PermInvoiceCreate), syncController.Sync) // POST /api/sync -> đẩy hóa đơn offline, kéo sản phẩm/giá/cài đặt thay đổi từ cursor
+}
+
+// loadJWTKeys đọc khoá ký access token từ JWT_KEYS_DIR (khoá ký chọn bằng JWT_ACTIVE_KID); cấu hình sai -> dừng server.
+// Chưa cấu hình JWT_KEYS_DIR cũng dừng server, trừ khi bật JWT_DEV_EPHEMERAL_KEY=true (chỉ dùng cho môi trường dev)
+// thì dùng khoá tạm sinh khi khởi động.
+func loadJWTKeys() *utils.KeyRing {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "go-fiber-api"
+	}
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if ephemeral, _ := strconv.ParseBool(os.Getenv("JWT_DEV_EPHEMERAL_KEY")); !ephemeral {
+			log.Fatalf("❌ Chưa cấu hình JWT_KEYS_DIR (đặt JWT_DEV_EPHEMERAL_KEY=true để dùng khoá tạm khi chạy dev)")
+		}
+		log.Println("⚠️ Chưa cấu hình JWT_KEYS_DIR, dùng khoá ký tạm thời (token mất hiệu lực khi khởi động lại)")
+		keys, err := utils.NewEphemeralKeyRing(issuer)
+		if err != nil {
+			log.Fatalf("❌ Không tạo được khoá ký JWT: %v", err)
+		}
+		return keys
+	}
+	keys, err := utils.LoadKeyRing(dir, os.Getenv("JWT_ACTIVE_KID"), issuer)
+	if err != nil {
+		log.Fatalf("❌ Không đọc được khoá ký JWT: %v", err)
+	}
+	log.Printf("🔑 Ký JWT bằng khoá %s\n", keys.ActiveKid())
+	return keys
+}
+
+// loadEInvoiceSigner đọc chứng thư số ký hóa đơn điện tử từ EINVOICE_CERT_FILE, EINVOICE_KEY_FILE (PEM).
+// Chưa cấu hình hoặc đọc lỗi -> trả về nil, API lập hóa đơn điện tử trả 503.
+func loadEInvoiceSigner() *einvoice.Signer {
+	certFile, keyFile := os.Getenv("EINVOICE_CERT_FILE"), os.Getenv("EINVOICE_KEY_FILE")
+	if certFile == "" || keyFile == "" {
+		return nil
+	}
+	signer, err := einvoice.LoadSigner(certFile, keyFile)
+	if err != nil {
+		log.Printf("⚠️ Không đọc được chứng thư số hóa đơn điện tử: %v\n", err)
+		return nil
+	}
+	return signer
+}
+
+// newEInvoiceSubmitter tạo nhà cung cấp hóa đơn điện tử theo EINVOICE_PROVIDER (bắt buộc, sai hoặc thiếu thì dừng server) và chạy worker
+// gửi lại mỗi EINVOICE_RETRY_INTERVAL_SECONDS giây, tối đa EINVOICE_MAX_ATTEMPTS lần cho mỗi thao tác.
+func newEInvoiceSubmitter(repo *repositories.EInvoiceRepository) *einvoice.Submitter {
+	provider, err := einvoice.NewProvider(os.Getenv("EINVOICE_PROVIDER"))
+	if err != nil {
+		log.Fatalf("❌ Cấu hình EINVOICE_PROVIDER không hợp lệ: %v", err)
+	}
+	submitter := einvoice.NewSubmitter(repo, provider, config.GetEnvInt("EINVOICE_MAX_ATTEMPTS", 5))
+	interval := time.Duration(config.GetEnvInt("EINVOICE_RETRY_INTERVAL_SECONDS", 60)) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go submitter.Run(context.Background(), interval)
+	return submitter
+}
+
+// loadAuditKey đọc khoá HMAC niêm phong nhật ký thao tác từ AUDIT_HMAC_KEY (ít nhất 32 byte, bắt buộc).
+// Khoá không nằm trong database nên người có quyền ghi database không tự tính lại được chuỗi hash.
+func loadAuditKey() []byte {
+	key := os.Getenv("AUDIT_HMAC_KEY")
+	if len(key) < 32 {
+		log.Fatal("❌ AUDIT_HMAC_KEY phải có ít nhất 32 byte")
+	}
+	return []byte(key)
+}
+
+// sealAuditLogs niêm phong nhật ký thao tác ngay khi có mục mới và định kỳ mỗi giây
+// (mục do tiến trình khác ghi, seq trống cần lấp)
+func sealAuditLogs(repo *repositories.AuditRepository) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-repo.Appended():
+		}
+		if err := repo.Seal(context.Background()); err != nil {
+			log.Printf("⚠️ Không niêm phong được nhật ký thao tác: %v\n", err)
+		}
+	}
+}
+
+// ensureIndexes tạo index cho repository; chỉ log cảnh báo nếu lỗi để server vẫn khởi động được
+func ensureIndexes(name string, repo interface{ EnsureIndexes(context.Context) error }) {
+	if err := repo.EnsureIndexes(context.TODO()); err != nil {
+		log.Printf("⚠️ Không tạo được index cho %s: %v\n", name, err)
+	}
+}
+
+// requireIndexes giống ensureIndexes nhưng dừng server nếu không tạo được index:
+// dùng cho unique index bảo đảm không cấp trùng mã chứng từ
+func requireIndexes(name string, repo interface{ EnsureIndexes(context.Context) error }) {
+	if err := repo.EnsureIndexes(context.TODO()); err != nil {
+		log.Fatalf("❌ Không tạo được index cho %s: %v\n", name, err)
+	}
 }
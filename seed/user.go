@@ -5,48 +5,96 @@ import (
 	"fmt"
 	"go-fiber-api/config"
 	"go-fiber-api/models"
+	"go-fiber-api/repositories"
 	"go-fiber-api/utils"
+	"log"
+	"os"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func seedUserIfNotExists(username, password, role string) {
-	collection := config.DB.Collection("users")
+// Mật khẩu mặc định của các tài khoản do bản cũ seed sẵn, chỉ dùng để phát hiện và bắt đổi mật khẩu
+var legacySeedPasswords = map[string]string{
+	"admin": "admin123",
+	"user":  "user123",
+}
 
-	// Kiểm tra user đã tồn tại chưa
-	var existing models.User
-	err := collection.FindOne(context.TODO(), bson.M{"username": username}).Decode(&existing)
-	if err != mongo.ErrNoDocuments {
-		fmt.Printf("✅ User '%s' already exists.\n", username)
+// SeedAdminUser tạo tài khoản admin đầu tiên khi database chưa có người dùng nào.
+// Username lấy từ ADMIN_USERNAME (mặc định admin), mật khẩu từ ADMIN_PASSWORD; bỏ trống thì sinh ngẫu nhiên và chỉ
+// ghi ra ADMIN_PASSWORD_FILE (không cấu hình hoặc ghi lỗi -> dừng server, mật khẩu không bao giờ được in ra log).
+// Tài khoản tạo ra phải đổi mật khẩu ở lần đăng nhập đầu tiên.
+func SeedAdminUser() {
+	count, err := config.DB.Collection("users").CountDocuments(context.TODO(), bson.M{})
+	if err != nil {
+		fmt.Println("❌ Failed to count users:", err)
+		return
+	}
+	if count > 0 {
+		flagLegacySeedUsers()
 		return
 	}
 
-	// Băm mật khẩu
+	username := strings.TrimSpace(os.Getenv("ADMIN_USERNAME"))
+	if username == "" {
+		username = "admin"
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	file := os.Getenv("ADMIN_PASSWORD_FILE")
+	generated := password == ""
+	if generated {
+		if file == "" {
+			log.Fatalf("❌ Database chưa có người dùng: cần ADMIN_PASSWORD hoặc ADMIN_PASSWORD_FILE để tạo admin ban đầu")
+		}
+		if password, err = utils.NewTemporaryPassword(); err != nil {
+			log.Fatalf("❌ Không sinh được mật khẩu admin: %v", err)
+		}
+		// Ghi file trước khi tạo tài khoản để không có admin mà không ai biết mật khẩu
+		if err := os.WriteFile(file, []byte(password+"\n"), 0o600); err != nil {
+			log.Fatalf("❌ Không ghi được ADMIN_PASSWORD_FILE %s: %v", file, err)
+		}
+	} else if err := utils.CurrentPasswordPolicy().Validate(password, username); err != nil {
+		log.Fatalf("❌ ADMIN_PASSWORD không đạt chính sách mật khẩu: %v", err)
+	}
+
 	hashedPwd, err := utils.HashPassword(password)
 	if err != nil {
 		fmt.Printf("❌ Failed to hash password for '%s': %v\n", username, err)
 		return
 	}
-
 	user := models.User{
-		Username: username,
-		Password: hashedPwd,
-		Role:     role,
+		Username:           username,
+		Password:           hashedPwd,
+		Role:               "admin",
+		MustChangePassword: true,
 	}
-
-	// Tạo mới người dùng
-	_, err = collection.InsertOne(context.TODO(), user)
-	if err != nil {
+	if err := repositories.CreateUser(&user); err != nil {
 		fmt.Printf("❌ Failed to seed user '%s': %v\n", username, err)
 		return
 	}
 
-	fmt.Printf("🚀 Seeded user successfully: username=%s, password=%s, role=%s\n", username, password, role)
+	if !generated {
+		fmt.Printf("🚀 Seeded admin '%s' with ADMIN_PASSWORD, password change required at first login\n", username)
+		return
+	}
+	fmt.Printf("🚀 Seeded admin '%s', initial password written to %s, password change required at first login\n", username, file)
 }
 
-func SeedAdminUser() {
-	
-	seedUserIfNotExists("admin", "admin123", "admin")
-	seedUserIfNotExists("user", "user123", "user")
+// flagLegacySeedUsers bắt đổi mật khẩu cho các tài khoản do bản cũ seed sẵn mà vẫn còn dùng mật khẩu mặc định
+func flagLegacySeedUsers() {
+	for username, password := range legacySeedPasswords {
+		user, err := repositories.FindUserByUsername(username)
+		if err != nil || user.MustChangePassword || !utils.CheckPasswordHash(password, user.Password) {
+			continue
+		}
+		filter := bson.M{"_id": user.ID}
+		if _, err := config.DB.Collection("users").UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"mustChangePassword": true}}); err != nil {
+			fmt.Printf("❌ Failed to flag user '%s': %v\n", username, err)
+			continue
+		}
+		if err := repositories.RevokeUserSessions(user.ID.Hex(), models.RevokeDefaultPassword); err != nil {
+			fmt.Printf("❌ Failed to revoke sessions of '%s': %v\n", username, err)
+		}
+		fmt.Printf("⚠️ User '%s' still uses the default password, password change required at next login\n", username)
+	}
 }
@@ -1,32 +1,54 @@
 package utils
 
 import (
-	"os"
-	"time"
-
-	"github.com/golang-jwt/jwt"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
 )
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+// NewSecretToken sinh token bí mật ngẫu nhiên (refresh token, device token) trả cho client và hash SHA-256 để lưu DB
+func NewSecretToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
-func GenerateJWT(id primitive.ObjectID, role string) (string, error) {
-	claims := jwt.MapClaims{
-		"id":   id.Hex(),
-		"role": role,
-		"exp":  time.Now().Add(12 * time.Hour).Unix(), // Hết hạn sau 12 giờ
+// NewTemporaryPassword sinh mật khẩu tạm 12 ký tự gồm chữ thường, chữ hoa và số (bỏ ký tự dễ nhầm)
+func NewTemporaryPassword() (string, error) {
+	classes := []string{"abcdefghjkmnpqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "23456789"}
+	all := strings.Join(classes, "")
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
+	password := make([]byte, len(buf))
+	for i, b := range buf {
+		set := all
+		if i < len(classes) {
+			set = classes[i] // Ba ký tự đầu đảm bảo đủ mỗi loại
+		}
+		password[i] = set[int(b)%len(set)]
+	}
+	// Đảo vị trí để ba ký tự bắt buộc không luôn nằm đầu
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	secret := os.Getenv("JWT_SECRET")
-	return token.SignedString([]byte(secret))
+// HashToken băm token bí mật (refresh token, device token) trước khi lưu hoặc tra cứu
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
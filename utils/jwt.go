@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Loại token ký bằng KeyRing (claim "typ"), để token của bước này không dùng được cho bước khác
+const (
+	TokenAccess       = "access"
+	TokenMFAChallenge = "mfa_challenge" // Đã đúng mật khẩu, chờ nhập mã 2FA
+)
+
+// TokenClaims là nội dung access token (và challenge token của bước đăng nhập 2FA)
+type TokenClaims struct {
+	UserID    string `json:"id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	Type      string `json:"typ"`
+	Method    string `json:"amr,omitempty"` // Cách đăng nhập của phiên: password | pin
+	// Đăng nhập bằng mật khẩu tạm: chỉ được đổi mật khẩu cho đến khi đổi xong (đổi mật khẩu thu hồi phiên)
+	MustChangePassword bool `json:"mcp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// signingKey là một khoá trong KeyRing. private = nil với khoá chỉ dùng để xác thực (khoá cũ đã ngừng ký).
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeyRing giữ khoá ký access token (RS256 hoặc EdDSA) và các khoá xác thực còn hiệu lực theo kid.
+//
+// Xoay khoá: thêm file khoá mới vào thư mục, khởi động lại với JWT_ACTIVE_KID là kid mới. Token ký bằng khoá cũ
+// vẫn được chấp nhận đến khi hết hạn vì khoá cũ vẫn nằm trong thư mục; sau đó có thể xoá khoá cũ
+// (hoặc chỉ giữ file public key).
+type KeyRing struct {
+	issuer string
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// LoadKeyRing đọc khoá từ dir: mỗi file <kid>.pem là private key (PKCS#8, PKCS#1 RSA) dùng được để ký,
+// mỗi file <kid>.pub.pem là public key (PKIX) chỉ dùng để xác thực. activeKid rỗng và chỉ có một private key
+// -> dùng khoá đó để ký.
+func LoadKeyRing(dir, activeKid, issuer string) (*KeyRing, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	ring := &KeyRing{issuer: issuer, keys: map[string]*signingKey{}}
+	var privateKids []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(file)
+		var key *signingKey
+		if kid, ok := strings.CutSuffix(name, ".pub.pem"); ok {
+			key, err = parsePublicKey(kid, data)
+		} else {
+			key, err = parsePrivateKey(strings.TrimSuffix(name, ".pem"), data)
+			if key != nil {
+				privateKids = append(privateKids, key.kid)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if existing, ok := ring.keys[key.kid]; ok && existing.private != nil {
+			continue // Đã có private key cùng kid, bỏ qua file public key
+		}
+		ring.keys[key.kid] = key
+	}
+
+	if activeKid == "" && len(privateKids) == 1 {
+		activeKid = privateKids[0]
+	}
+	active, ok := ring.keys[activeKid]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("no private key for active kid %q in %s", activeKid, dir)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+// NewEphemeralKeyRing tạo khoá Ed25519 tạm trong bộ nhớ, dùng khi chưa cấu hình thư mục khoá (môi trường dev).
+// Khởi động lại server thì access token cũ hết hiệu lực, client dùng refresh token để lấy token mới.
+func NewEphemeralKeyRing(issuer string) (*KeyRing, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key := &signingKey{
+		kid:     "ephemeral-" + time.Now().Format("20060102150405"),
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}
+	return &KeyRing{issuer: issuer, active: key, keys: map[string]*signingKey{key.kid: key}}, nil
+}
+
+// ActiveKid trả về kid của khoá đang dùng để ký
+func (r *KeyRing) ActiveKid() string { return r.active.kid }
+
+// GenerateJWT tạo access token ngắn hạn từ claims (người dùng, phiên "sid", cách đăng nhập "amr"...), ký bằng khoá
+// đang dùng và ghi kid vào header. Protected() từ chối token của phiên đã thu hồi.
+func (r *KeyRing) GenerateJWT(claims TokenClaims, ttl time.Duration) (string, error) {
+	claims.Type = TokenAccess
+	return r.sign(claims, ttl)
+}
+
+// GenerateChallenge tạo challenge token ngắn hạn sau khi đúng mật khẩu; client gửi kèm mã 2FA để hoàn tất đăng nhập.
+// Challenge token không có "sid" và có typ riêng nên không dùng thay access token được.
+func (r *KeyRing) GenerateChallenge(id primitive.ObjectID, role string, ttl time.Duration) (string, error) {
+	return r.sign(TokenClaims{UserID: id.Hex(), Role: role, Type: TokenMFAChallenge}, ttl)
+}
+
+func (r *KeyRing) sign(claims TokenClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    r.issuer,
+		Subject:   claims.UserID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.kid
+	return token.SignedString(r.active.private)
+}
+
+// Parse xác thực chữ ký (theo kid trong header, thuật toán phải khớp loại khoá), hạn, issuer và loại token (typ)
+func (r *KeyRing) Parse(tokenString, typ string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := r.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.public, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims := token.Claims.(*TokenClaims)
+	if !claims.VerifyIssuer(r.issuer, true) {
+		return nil, errors.New("invalid issuer")
+	}
+	if claims.Type != typ {
+		return nil, fmt.Errorf("unexpected token type %q", claims.Type)
+	}
+	return token, nil
+}
+
+// JWK là một public key theo định dạng JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+// JWKS trả về public key của mọi khoá còn hiệu lực để dịch vụ khác tự xác thực access token
+func (r *KeyRing) JWKS() []JWK {
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	keys := make([]JWK, 0, len(kids))
+	for _, kid := range kids {
+		key := r.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+func parsePrivateKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: private, public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: private, public: private.Public()}, nil
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+}
+
+func parsePublicKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, public: public}, nil
+	case ed25519.PublicKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, public: public}, nil
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FormatVND định dạng số tiền VND không có phần thập phân, phân cách hàng nghìn bằng dấu chấm: 1.250.000
+func FormatVND(amount float64) string {
+	digits := strconv.FormatInt(int64(math.Abs(math.Round(amount))), 10)
+	out := make([]byte, 0, len(digits)+len(digits)/3+1)
+	if amount <= -0.5 {
+		out = append(out, '-')
+	}
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, '.')
+		}
+		out = append(out, digits[i])
+	}
+	return string(out)
+}
+
+var vnDigits = [...]string{"không", "một", "hai", "ba", "bốn", "năm", "sáu", "bảy", "tám", "chín"}
+
+// VNDInWords đọc số tiền VND thành chữ tiếng Việt (làm tròn đến đồng), dùng cho dòng "Số tiền viết bằng chữ":
+// 1250000 -> "Một triệu hai trăm năm mươi nghìn đồng"
+func VNDInWords(amount float64) string {
+	n := int64(math.Abs(math.Round(amount)))
+	if n == 0 {
+		return "Không đồng"
+	}
+
+	// Tách thành các nhóm 3 chữ số từ phải sang trái
+	var groups []int64
+	for ; n > 0; n /= 1000 {
+		groups = append(groups, n%1000)
+	}
+	units := []string{"", "nghìn", "triệu"}
+
+	var words []string
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groups[i] != 0 {
+			// Nhóm đứng sau nhóm khác phải đọc đủ hàng trăm: 1.005.000 -> "một triệu không trăm linh năm nghìn"
+			words = append(words, readHundreds(groups[i], i < len(groups)-1)...)
+			if units[i%3] != "" {
+				words = append(words, units[i%3])
+			}
+		}
+		// "tỷ" đứng sau mỗi khối 3 nhóm: 1.200.000.000.000 -> "một nghìn hai trăm tỷ"
+		if i > 0 && i%3 == 0 && blockNonZero(groups, i) {
+			words = append(words, "tỷ")
+		}
+	}
+	if amount <= -0.5 {
+		words = append([]string{"âm"}, words...)
+	}
+	text := strings.Join(words, " ") + " đồng"
+	first, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToUpper(first)) + text[size:]
+}
+
+// readHundreds đọc một nhóm 3 chữ số; full = true thì đọc cả "không trăm", "linh"
+func readHundreds(n int64, full bool) []string {
+	hundreds, tens, ones := n/100, n/10%10, n%10
+	var words []string
+	if hundreds > 0 || full {
+		words = append(words, vnDigits[hundreds], "trăm")
+	}
+	switch {
+	case tens == 0 && ones == 0:
+		return words
+	case tens == 0:
+		if len(words) > 0 {
+			words = append(words, "linh")
+		}
+	case tens == 1:
+		words = append(words, "mười")
+	default:
+		words = append(words, vnDigits[tens], "mươi")
+	}
+	switch {
+	case ones == 0:
+	case ones == 1 && tens >= 2:
+		words = append(words, "mốt")
+	case ones == 5 && tens >= 1:
+		words = append(words, "lăm")
+	default:
+		words = append(words, vnDigits[ones])
+	}
+	return words
+}
+
+// blockNonZero kiểm tra khối 3 nhóm bắt đầu từ nhóm i (đơn vị tỷ) có khác 0 không
+func blockNonZero(groups []int64, i int) bool {
+	for j := i; j < i+3 && j < len(groups); j++ {
+		if groups[j] != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go-fiber-api/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Thuật toán băm mật khẩu (PASSWORD_HASH_ALGORITHM)
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// passwordHasher là cấu hình băm mật khẩu đọc từ biến môi trường ở lần dùng đầu tiên
+type passwordHasher struct {
+	algorithm    string
+	bcryptCost   int
+	argonTime    uint32
+	argonMemory  uint32 // KiB
+	argonThreads uint8
+}
+
+var hasher = sync.OnceValue(func() passwordHasher {
+	h := passwordHasher{
+		algorithm:    strings.ToLower(os.Getenv("PASSWORD_HASH_ALGORITHM")),
+		bcryptCost:   config.GetEnvInt("BCRYPT_COST", 12),
+		argonTime:    uint32(config.GetEnvInt("ARGON2_TIME", 3)),
+		argonMemory:  uint32(config.GetEnvInt("ARGON2_MEMORY_KB", 64*1024)),
+		argonThreads: uint8(config.GetEnvInt("ARGON2_THREADS", 2)),
+	}
+	if h.algorithm == "" {
+		h.algorithm = HashBcrypt
+	}
+	if h.algorithm != HashBcrypt && h.algorithm != HashArgon2id {
+		log.Printf("⚠️ PASSWORD_HASH_ALGORITHM=%s không hỗ trợ, dùng bcrypt\n", h.algorithm)
+		h.algorithm = HashBcrypt
+	}
+	if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+		h.bcryptCost = 12
+	}
+	if h.argonTime == 0 || h.argonMemory < 8*1024 || h.argonThreads == 0 {
+		h.argonTime, h.argonMemory, h.argonThreads = 3, 64*1024, 2
+	}
+	return h
+})
+
+// MaxPasswordBytes là độ dài tối đa của mật khẩu: bcrypt chỉ nhận tối đa 72 byte, giữ cùng giới hạn cho argon2id
+// để đổi PASSWORD_HASH_ALGORITHM không làm mật khẩu đang dùng trở thành không hợp lệ
+const MaxPasswordBytes = 72
+
+// HashPassword băm mật khẩu (hoặc PIN) bằng thuật toán đang cấu hình
+func HashPassword(password string) (string, error) {
+	return hasher().hash(password)
+}
+
+func (h passwordHasher) hash(password string) (string, error) {
+	if h.algorithm == HashArgon2id {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.argonTime, h.argonMemory, h.argonThreads, 32)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.argonMemory, h.argonTime, h.argonThreads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	return string(bytes), err
+}
+
+// CheckPasswordHash so mật khẩu với hash bcrypt hoặc argon2id (nhận dạng theo tiền tố của hash)
+func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.argonTime, params.argonMemory, params.argonThreads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// PasswordNeedsRehash cho biết hash được tạo bằng thuật toán khác hoặc tham số yếu hơn cấu hình hiện tại.
+// Gọi sau khi đăng nhập đúng để băm lại mật khẩu bằng HashPassword.
+func PasswordNeedsRehash(hash string) bool {
+	return hasher().needsRehash(hash)
+}
+
+func (h passwordHasher) needsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if h.algorithm != HashArgon2id {
+			return true
+		}
+		params, _, _, err := parseArgon2id(hash)
+		return err != nil || params.argonTime < h.argonTime || params.argonMemory < h.argonMemory || params.argonThreads < h.argonThreads
+	}
+	if h.algorithm != HashBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.bcryptCost
+}
+
+// parseArgon2id tách tham số, salt và key từ hash dạng $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func parseArgon2id(hash string) (passwordHasher, []byte, []byte, error) {
+	var params passwordHasher
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.argonMemory, &params.argonTime, &params.argonThreads); err != nil {
+		return params, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id key")
+	}
+	return params, salt, key, nil
+}
+
+// PasswordPolicy là chính sách mật khẩu đọc từ biến môi trường PASSWORD_*
+type PasswordPolicy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	History       int // Số mật khẩu gần nhất không được dùng lại (ngoài mật khẩu hiện tại)
+}
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = sync.OnceValue(func() map[string]bool {
+	list := map[string]bool{}
+	add := func(scanner *bufio.Scanner) {
+		for scanner.Scan() {
+			if word := strings.ToLower(strings.TrimSpace(scanner.Text())); word != "" && !strings.HasPrefix(word, "#") {
+				list[word] = true
+			}
+		}
+	}
+	add(bufio.NewScanner(strings.NewReader(commonPasswordList)))
+	// Danh sách bổ sung do quản trị cung cấp, mỗi dòng một mật khẩu
+	if file := os.Getenv("PASSWORD_BLOCKLIST_FILE"); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Printf("⚠️ Không đọc được PASSWORD_BLOCKLIST_FILE: %v\n", err)
+			return list
+		}
+		defer f.Close()
+		add(bufio.NewScanner(f))
+	}
+	return list
+})
+
+// CurrentPasswordPolicy trả về chính sách mật khẩu đang áp dụng
+var CurrentPasswordPolicy = sync.OnceValue(func() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     config.GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		RequireLower:  config.GetEnvInt("PASSWORD_REQUIRE_LOWER", 1) == 1,
+		RequireUpper:  config.GetEnvInt("PASSWORD_REQUIRE_UPPER", 1) == 1,
+		RequireDigit:  config.GetEnvInt("PASSWORD_REQUIRE_DIGIT", 1) == 1,
+		RequireSymbol: config.GetEnvInt("PASSWORD_REQUIRE_SYMBOL", 0) == 1,
+		History:       config.GetEnvInt("PASSWORD_HISTORY", 5),
+	}
+})
+
+// Validate kiểm tra mật khẩu theo chính sách, trả về lỗi mô tả các điều kiện chưa đạt.
+// Mật khẩu dài tối đa MaxPasswordBytes byte, không được nằm trong danh sách mật khẩu phổ biến và không được chứa username.
+func (p PasswordPolicy) Validate(password, username string) error {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if len(password) > MaxPasswordBytes {
+		return fmt.Errorf("Password must be at most %d bytes", MaxPasswordBytes)
+	}
+	var problems []string
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+	if len(problems) > 0 {
+		return errors.New("Password must contain " + strings.Join(problems, ", "))
+	}
+	normalized := strings.ToLower(password)
+	if commonPasswords()[normalized] {
+		return errors.New("Password is too common")
+	}
+	if username = strings.ToLower(strings.TrimSpace(username)); len(username) >= 3 && strings.Contains(normalized, username) {
+		return errors.New("Password must not contain the username")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id dùng tham số nhỏ để test chạy nhanh
+var testArgon2id = passwordHasher{algorithm: HashArgon2id, argonTime: 1, argonMemory: 8 * 1024, argonThreads: 1}
+
+var testBcrypt = passwordHasher{algorithm: HashBcrypt, bcryptCost: bcrypt.MinCost}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hash, err := testArgon2id.hash("Đúng-mật-khẩu 1")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Fatalf("hash = %s, want PHC string with configured parameters", hash)
+	}
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if params.argonTime != 1 || params.argonMemory != 8*1024 || params.argonThreads != 1 {
+		t.Fatalf("params = %+v, want t=1 m=8192 p=1", params)
+	}
+	if len(salt) != 16 || len(key) != 32 {
+		t.Fatalf("salt %d bytes, key %d bytes, want 16 and 32", len(salt), len(key))
+	}
+	if !CheckPasswordHash("Đúng-mật-khẩu 1", hash) {
+		t.Fatal("correct password rejected")
+	}
+	if CheckPasswordHash("Đúng-mật-khẩu 2", hash) {
+		t.Fatal("wrong password accepted")
+	}
+	other, err := testArgon2id.hash("Đúng-mật-khẩu 1")
+	if err != nil || other == hash {
+		t.Fatalf("second hash = %s, %v, want a different salt", other, err)
+	}
+}
+
+func TestParseArgon2idRejectsMalformedHash(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=8192,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdHNhbHRzYWx0$",
+	} {
+		if _, _, _, err := parseArgon2id(hash); err == nil {
+			t.Errorf("parseArgon2id(%q) succeeded, want error", hash)
+		}
+		if CheckPasswordHash("anything", hash) {
+			t.Errorf("CheckPasswordHash accepted malformed hash %q", hash)
+		}
+	}
+}
+
+func TestBcryptRoundTrip(t *testing.T) {
+	hash, err := testBcrypt.hash("Secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !CheckPasswordHash("Secret123", hash) || CheckPasswordHash("Secret124", hash) {
+		t.Fatal("bcrypt hash does not round-trip")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	argonHash, err := testArgon2id.hash("Secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	bcryptHash, err := testBcrypt.hash("Secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	stronger := testArgon2id
+	stronger.argonMemory *= 2
+	strongerBcrypt := testBcrypt
+	strongerBcrypt.bcryptCost++
+
+	for _, tc := range []struct {
+		name   string
+		hasher passwordHasher
+		hash   string
+		want   bool
+	}{
+		{"argon2id same params", testArgon2id, argonHash, false},
+		{"argon2id weaker params", stronger, argonHash, true},
+		{"argon2id -> bcrypt", testBcrypt, argonHash, true},
+		{"bcrypt same cost", testBcrypt, bcryptHash, false},
+		{"bcrypt lower cost", strongerBcrypt, bcryptHash, true},
+		{"bcrypt -> argon2id", testArgon2id, bcryptHash, true},
+		{"malformed argon2id", testArgon2id, "$argon2id$v=19$broken", true},
+		{"malformed bcrypt", testBcrypt, "not-a-hash", true},
+	} {
+		if got := tc.hasher.needsRehash(tc.hash); got != tc.want {
+			t.Errorf("%s: needsRehash = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireLower: true, RequireUpper: true, RequireDigit: true}
+	for _, tc := range []struct {
+		password, username string
+		wantErr            string
+	}{
+		{"Secret123", "thungan1", ""},
+		{"Mật-Khẩu-9", "thungan1", ""},
+		{"Sec1", "thungan1", "at least 8 characters"},
+		{"secret123", "thungan1", "an uppercase letter"},
+		{"SECRET123", "thungan1", "a lowercase letter"},
+		{"SecretABC", "thungan1", "a digit"},
+		{"Password1", "thungan1", "too common"},
+		{"Thungan1-x", "thungan1", "must not contain the username"},
+		{"Ab1" + strings.Repeat("x", MaxPasswordBytes-3), "thungan1", ""},
+		{"Ab1" + strings.Repeat("x", MaxPasswordBytes-2), "thungan1", "at most 72 bytes"},
+		// 24 ký tự nhưng 3 byte mỗi ký tự: vượt giới hạn byte của bcrypt
+		{"Aa1" + strings.Repeat("ệ", 24), "thungan1", "at most 72 bytes"},
+	} {
+		err := policy.Validate(tc.password, tc.username)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("Validate(%q) = %v, want nil", tc.password, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("Validate(%q) = %v, want error containing %q", tc.password, err, tc.wantErr)
+		}
+	}
+
+	symbol := PasswordPolicy{MinLength: 8, RequireSymbol: true}
+	if err := symbol.Validate("abcdefgh1", ""); err == nil || !strings.Contains(err.Error(), "a symbol") {
+		t.Errorf("Validate without symbol = %v, want symbol error", err)
+	}
+}
+
+func TestHashRejectsOverlongPasswordForBcrypt(t *testing.T) {
+	if _, err := testBcrypt.hash(strings.Repeat("x", MaxPasswordBytes+1)); err == nil {
+		t.Fatal("bcrypt accepted a password over 72 bytes; Validate must keep MaxPasswordBytes in sync")
+	}
+}
@@ -39,3 +39,12 @@ func RemoveVietnameseAccents(input string) string {
 	)
 	return replacer.Replace(input)
 }
+
+// SpreadsheetSafe chặn chèn công thức khi mở file CSV/XLSX bằng Excel: ô chữ bắt đầu bằng =, +, -, @, tab
+// hoặc CR được thêm dấu ' phía trước để luôn hiển thị như chữ
+func SpreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
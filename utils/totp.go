@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Tham số TOTP (RFC 6238) tương thích Google Authenticator, Microsoft Authenticator, Authy...
+const (
+	totpPeriod = 30 // giây
+	totpDigits = 6
+	totpSkew   = 1 // Chấp nhận mã của bước liền trước / liền sau để bù lệch đồng hồ điện thoại
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret sinh khoá bí mật TOTP 160 bit, mã hoá base32 (dạng nhập tay vào ứng dụng xác thực)
+func NewTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPURI tạo provisioning URI otpauth:// để client hiển thị thành mã QR cho ứng dụng xác thực quét
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// VerifyTOTP kiểm tra mã 6 số tại thời điểm now. Hợp lệ -> trả về bước thời gian (counter) của mã
+// để người gọi lưu lại và từ chối dùng lại mã cùng bước hoặc bước cũ hơn.
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode tính mã HOTP (RFC 4226) cho bước thời gian step
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// NewBackupCodes sinh n mã dự phòng dùng một lần (dạng xxxx-xxxx) và hash để lưu DB
+func NewBackupCodes(n int) (codes, hashes []string, err error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789" // Bỏ ký tự dễ nhầm: i, l, o, 0, 1
+	for i := 0; i < n; i++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		for j := range buf {
+			buf[j] = alphabet[int(buf[j])%len(alphabet)]
+		}
+		code := string(buf[:4]) + "-" + string(buf[4:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashBackupCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashBackupCode chuẩn hoá (bỏ dấu gạch, khoảng trắng, chữ hoa) rồi băm mã dự phòng
+func HashBackupCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashToken(code)
+}